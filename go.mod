@@ -6,12 +6,11 @@ require (
 	github.com/avast/retry-go/v4 v4.5.1
 	github.com/hashicorp/go-version v1.7.0
 	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.21.0
+	golang.org/x/sys v0.18.0
 	golang.org/x/term v0.18.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.18.0 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
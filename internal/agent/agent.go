@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/inject"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidDefinition = errors.New("invalid agent definition")
+	ErrMissingEnv        = errors.New("required environment variable is not set")
+
+	namePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Definition declares how to install, authenticate and run a coding agent in the VM
+type Definition struct {
+	// Name of the agent, which is also the name of the chamber subcommand
+	Name string `yaml:"name"`
+
+	// Description is shown in the help output
+	Description string `yaml:"description,omitempty"`
+
+	// Binary to execute in the guest's login shell
+	Binary string `yaml:"binary"`
+
+	// Flags are always prepended to the user-provided arguments,
+	// typically to put the agent into its non-interactive "YOLO" mode
+	Flags []string `yaml:"flags,omitempty"`
+
+	// Env lists the environment variables the agent requires, which
+	// must be set on the host and are forwarded to the guest
+	Env []string `yaml:"env,omitempty"`
+
+	// Install is a shell command that installs the agent in the seed VM
+	Install string `yaml:"install,omitempty"`
+
+	// Update is a shell command that upgrades the agent to the latest version,
+	// defaults to the install command
+	Update string `yaml:"update,omitempty"`
+
+	// Auth is an interactive shell command that logs the agent in
+	Auth string `yaml:"auth,omitempty"`
+
+	// Version is a shell command that prints the agent's version,
+	// defaults to running the binary with --version
+	Version string `yaml:"version,omitempty"`
+
+	// Files are copied into the VM before each run of the agent,
+	// relative sources are resolved against the definition's directory
+	Files []inject.File `yaml:"files,omitempty"`
+}
+
+// Validate checks that the definition is usable
+func (definition *Definition) Validate() error {
+	if !namePattern.MatchString(definition.Name) {
+		return fmt.Errorf("%w: name %q must consist of lowercase letters, digits and dashes",
+			ErrInvalidDefinition, definition.Name)
+	}
+
+	if definition.Binary == "" {
+		return fmt.Errorf("%w: agent %q has no binary", ErrInvalidDefinition, definition.Name)
+	}
+
+	for _, name := range definition.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: agent %q requires an invalid environment variable name %q",
+				ErrInvalidDefinition, definition.Name, name)
+		}
+	}
+
+	for _, file := range definition.Files {
+		if err := file.Validate(); err != nil {
+			return fmt.Errorf("%w: agent %q: %v", ErrInvalidDefinition, definition.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Command returns the argv to run in the guest for the given user-provided arguments
+func (definition *Definition) Command(args []string) []string {
+	command := append([]string{definition.Binary}, definition.Flags...)
+
+	return append(command, args...)
+}
+
+// VersionCommand returns the shell command that prints the agent's version
+func (definition *Definition) VersionCommand() string {
+	if definition.Version != "" {
+		return definition.Version
+	}
+
+	return definition.Binary + " --version"
+}
+
+// UpdateCommand returns the shell command that upgrades the agent, if any
+func (definition *Definition) UpdateCommand() string {
+	if definition.Update != "" {
+		return definition.Update
+	}
+
+	return definition.Install
+}
+
+// Environment collects the required environment variables from the host
+func (definition *Definition) Environment(lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	env := map[string]string{}
+
+	for _, name := range definition.Env {
+		value, ok := lookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: agent %q requires %s", ErrMissingEnv, definition.Name, name)
+		}
+
+		env[name] = value
+	}
+
+	return env, nil
+}
+
+// Builtins returns the agents chamber supports out of the box
+func Builtins() []Definition {
+	return []Definition{
+		{
+			Name:        "claude",
+			Description: "Claude Code",
+			Binary:      "claude",
+			Flags:       []string{"--dangerously-skip-permissions"},
+			Install:     "npm install -g @anthropic-ai/claude-code",
+			Update:      "npm install -g @anthropic-ai/claude-code@latest",
+			Auth:        "claude",
+		},
+		{
+			Name:        "codex",
+			Description: "OpenAI Codex CLI",
+			Binary:      "codex",
+			Flags:       []string{"--dangerously-bypass-approvals-and-sandbox"},
+			Install:     "npm install -g @openai/codex",
+			Update:      "npm install -g @openai/codex@latest",
+			Auth:        "codex login",
+		},
+	}
+}
+
+// Load reads agent definitions from the *.yaml and *.yml files in dir.
+// A missing directory is not an error.
+func Load(dir string) ([]Definition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read agent definitions: %w", err)
+	}
+
+	var definitions []Definition
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		definition, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func loadFile(path string) (Definition, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Definition{}, fmt.Errorf("failed to read agent definition: %w", err)
+	}
+
+	var definition Definition
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&definition); err != nil {
+		return Definition{}, fmt.Errorf("%w: %s: %v", ErrInvalidDefinition, path, err)
+	}
+
+	// Default the name to the file name, e.g. gemini.yaml defines "gemini"
+	if definition.Name == "" {
+		definition.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	if err := definition.Validate(); err != nil {
+		return Definition{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	definition.Files, err = inject.Resolve(definition.Files, filepath.Dir(path))
+	if err != nil {
+		return Definition{}, err
+	}
+
+	return definition, nil
+}
+
+// Registry is a set of agent definitions indexed by name
+type Registry struct {
+	definitions map[string]Definition
+}
+
+// NewRegistry creates a registry from the built-in agents, overridden
+// and extended by the user-provided definitions in dir
+func NewRegistry(dir string) (*Registry, error) {
+	userDefinitions, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &Registry{definitions: map[string]Definition{}}
+
+	for _, definition := range append(Builtins(), userDefinitions...) {
+		registry.definitions[definition.Name] = definition
+	}
+
+	return registry, nil
+}
+
+// Get returns the agent definition with the given name
+func (registry *Registry) Get(name string) (Definition, bool) {
+	definition, ok := registry.definitions[name]
+
+	return definition, ok
+}
+
+// All returns all agent definitions sorted by name
+func (registry *Registry) All() []Definition {
+	var result []Definition
+
+	for _, definition := range registry.definitions {
+		result = append(result, definition)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+
+	gemini := `binary: gemini
+flags: [--yolo]
+env: [GEMINI_API_KEY]
+install: npm install -g @google/gemini-cli
+`
+	if err := os.WriteFile(filepath.Join(dir, "gemini.yaml"), []byte(gemini), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// User definitions override the built-in ones
+	claude := `name: claude
+binary: claude
+flags: [--dangerously-skip-permissions, --verbose]
+`
+	if err := os.WriteFile(filepath.Join(dir, "my-claude.yml"), []byte(claude), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, definition := range registry.All() {
+		names = append(names, definition.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"claude", "codex", "gemini"}) {
+		t.Fatalf("unexpected agents: %v", names)
+	}
+
+	definition, ok := registry.Get("gemini")
+	if !ok {
+		t.Fatal("gemini agent is missing")
+	}
+	if command := definition.Command([]string{"-p", "hi"}); !reflect.DeepEqual(command,
+		[]string{"gemini", "--yolo", "-p", "hi"}) {
+		t.Errorf("unexpected command: %q", command)
+	}
+	if command := definition.UpdateCommand(); command != "npm install -g @google/gemini-cli" {
+		t.Errorf("the update command should default to the install command, got %q", command)
+	}
+
+	definition, _ = registry.Get("claude")
+	if !reflect.DeepEqual(definition.Flags, []string{"--dangerously-skip-permissions", "--verbose"}) {
+		t.Errorf("user definition didn't override the built-in one: %+v", definition)
+	}
+}
+
+func TestRegistryMissingDir(t *testing.T) {
+	registry, err := NewRegistry(filepath.Join(t.TempDir(), "nonexistent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(registry.All()) != len(Builtins()) {
+		t.Errorf("expected only built-in agents, got %d", len(registry.All()))
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no-binary.yaml":   "flags: [--yolo]\n",
+		"unknown.yaml":     "binary: x\nbinnary: y\n",
+		"Bad_Name.yaml":    "binary: x\n",
+		"bad-env.yaml":     "binary: x\nenv: [\"A B\"]\n",
+		"not-a-list.yaml":  "binary: x\nflags: --yolo\n",
+		"wrong-type.yaml":  "- binary: x\n",
+		"mismatched.yaml":  "name: UPPER\nbinary: x\n",
+		"also-broken.yaml": "binary: [x]\n",
+	}
+
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Load(dir); !errors.Is(err, ErrInvalidDefinition) {
+				t.Errorf("expected ErrInvalidDefinition, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	definition := Definition{Name: "gemini", Binary: "gemini", Env: []string{"GEMINI_API_KEY"}}
+
+	lookup := func(env map[string]string) func(string) (string, bool) {
+		return func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		}
+	}
+
+	if _, err := definition.Environment(lookup(nil)); !errors.Is(err, ErrMissingEnv) {
+		t.Fatalf("expected ErrMissingEnv, got %v", err)
+	}
+
+	env, err := definition.Environment(lookup(map[string]string{"GEMINI_API_KEY": "key", "OTHER": "x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, map[string]string{"GEMINI_API_KEY": "key"}) {
+		t.Errorf("unexpected environment: %v", env)
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Install runs the agent's install command in the guest, if it has one
+func Install(client *gossh.Client, definition Definition, stdout io.Writer, stderr io.Writer) error {
+	if definition.Install == "" {
+		return nil
+	}
+
+	if err := ssh.Run(client, executor.LoginShell(definition.Install), stdout, stderr); err != nil {
+		return fmt.Errorf("failed to install %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+// Update runs the agent's update command in the guest, if it has one
+func Update(client *gossh.Client, definition Definition, stdout io.Writer, stderr io.Writer) error {
+	command := definition.UpdateCommand()
+	if command == "" {
+		return fmt.Errorf("%s has neither an update nor an install command", definition.Name)
+	}
+
+	if err := ssh.Run(client, executor.LoginShell(command), stdout, stderr); err != nil {
+		return fmt.Errorf("failed to update %s: %w", definition.Name, err)
+	}
+
+	return nil
+}
+
+// DetectVersion runs the agent's version command in the guest, which also verifies that it's runnable
+func DetectVersion(client *gossh.Client, definition Definition) (string, error) {
+	output, err := ssh.Output(client, executor.LoginShell(definition.VersionCommand()))
+	if err != nil {
+		return "", fmt.Errorf("%s doesn't run in the VM (%q failed): %w",
+			definition.Name, definition.VersionCommand(), err)
+	}
+
+	return strings.TrimSpace(output), nil
+}
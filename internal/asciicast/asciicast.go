@@ -0,0 +1,344 @@
+// Package asciicast reads and writes terminal session recordings
+// in the asciinema v2 format: https://docs.asciinema.org/manual/asciicast/v2/
+package asciicast
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Version of the asciicast format written and read by this package
+	Version = 2
+
+	// Sizes used when the recorded command doesn't run in a terminal
+	DefaultWidth  = 80
+	DefaultHeight = 24
+)
+
+// Event types
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+var ErrInvalidRecording = errors.New("invalid asciicast recording")
+
+// Header is the first line of a recording
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a single line of a recording after the header
+type Event struct {
+	Time time.Duration
+	Type string
+	Data string
+}
+
+// MarshalJSON encodes the event as [time, type, data]
+func (event Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{json.Number(strconv.FormatFloat(event.Time.Seconds(), 'f', 6, 64)), event.Type, event.Data})
+}
+
+// UnmarshalJSON decodes the event from [time, type, data]
+func (event *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	var seconds float64
+
+	if err := json.Unmarshal(fields[0], &seconds); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &event.Type); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[2], &event.Data); err != nil {
+		return err
+	}
+
+	event.Time = time.Duration(seconds * float64(time.Second))
+
+	return nil
+}
+
+// Options configure a recording
+type Options struct {
+	// Title is stored in the header
+	Title string
+
+	// Input also records what's typed into the terminal, which might include passwords
+	Input bool
+}
+
+// Recorder writes an asciicast recording. The header is written once the terminal size
+// is known, i.e. when Start is called or, failing that, on the first event.
+type Recorder struct {
+	mtx     sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	options Options
+	started time.Time
+	now     func() time.Time
+	err     error
+
+	// Incomplete UTF-8 sequences at the end of the last write of each stream,
+	// since asciicast events carry strings
+	partial map[string][]byte
+}
+
+// Create creates the recording file at path, readable only by the user
+// since the session's output may contain secrets
+func Create(path string, options Options) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	// An existing file keeps its permissions when it's truncated
+	if err := file.Chmod(0o600); err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	return &Recorder{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		options: options,
+		now:     time.Now,
+		partial: map[string][]byte{},
+	}, nil
+}
+
+// Start writes the header with the initial terminal size, it's a no-op once the recording has started
+func (recorder *Recorder) Start(width int, height int) error {
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+
+	recorder.start(width, height)
+
+	return recorder.err
+}
+
+func (recorder *Recorder) start(width int, height int) {
+	if !recorder.started.IsZero() || recorder.err != nil {
+		return
+	}
+
+	recorder.started = recorder.now()
+
+	recorder.writeLine(Header{
+		Version:   Version,
+		Width:     width,
+		Height:    height,
+		Timestamp: recorder.started.Unix(),
+		Title:     recorder.options.Title,
+		Env:       map[string]string{"TERM": "xterm-256color", "SHELL": "/bin/zsh"},
+	})
+}
+
+// Resize records a change of the terminal size
+func (recorder *Recorder) Resize(width int, height int) {
+	recorder.event(EventResize, []byte(fmt.Sprintf("%dx%d", width, height)))
+}
+
+// RecordInput records what's typed into the terminal, if enabled
+func (recorder *Recorder) RecordInput(p []byte) {
+	if recorder.options.Input {
+		recorder.event(EventInput, p)
+	}
+}
+
+// Output returns a writer that records everything written to it as terminal output
+func (recorder *Recorder) Output() io.Writer {
+	return outputWriter{recorder}
+}
+
+type outputWriter struct {
+	recorder *Recorder
+}
+
+func (writer outputWriter) Write(p []byte) (int, error) {
+	writer.recorder.event(EventOutput, p)
+
+	// A failing recording shouldn't break the session, it's reported by Close
+	return len(p), nil
+}
+
+func (recorder *Recorder) event(eventType string, p []byte) {
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+
+	recorder.start(DefaultWidth, DefaultHeight)
+
+	// Hold back an incomplete UTF-8 sequence until the rest of it arrives
+	data := append(recorder.partial[eventType], p...)
+
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+
+			break
+		}
+	}
+
+	recorder.partial[eventType] = append([]byte(nil), data[cut:]...)
+
+	if cut == 0 {
+		return
+	}
+
+	recorder.writeLine(Event{
+		Time: recorder.now().Sub(recorder.started),
+		Type: eventType,
+		Data: string(data[:cut]),
+	})
+}
+
+func (recorder *Recorder) writeLine(value any) {
+	if recorder.err != nil {
+		return
+	}
+
+	line, err := json.Marshal(value)
+	if err != nil {
+		recorder.err = err
+
+		return
+	}
+
+	if _, err := recorder.writer.Write(append(line, '\n')); err != nil {
+		recorder.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// Close finishes the recording
+func (recorder *Recorder) Close() error {
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+
+	recorder.start(DefaultWidth, DefaultHeight)
+
+	for _, eventType := range []string{EventOutput, EventInput} {
+		if partial := recorder.partial[eventType]; len(partial) != 0 {
+			recorder.writeLine(Event{Time: recorder.now().Sub(recorder.started), Type: eventType, Data: string(partial)})
+		}
+	}
+
+	if err := recorder.writer.Flush(); err != nil && recorder.err == nil {
+		recorder.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	if err := recorder.file.Close(); err != nil && recorder.err == nil {
+		recorder.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	return recorder.err
+}
+
+// Read parses a recording
+func Read(r io.Reader) (*Header, []Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidRecording)
+	}
+
+	var header Header
+
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid header: %v", ErrInvalidRecording, err)
+	}
+	if header.Version != Version {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidRecording, header.Version)
+	}
+
+	var events []Event
+
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event Event
+
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, nil, fmt.Errorf("%w: line %d: %v", ErrInvalidRecording, line, err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return &header, events, nil
+}
+
+// Replay writes the recorded output to w with the original timing, sped up by speed.
+// Pauses longer than maxIdle are shortened to it, unless it's zero.
+func Replay(ctx context.Context, w io.Writer, events []Event, speed float64, maxIdle time.Duration) error {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var last time.Duration
+
+	for _, event := range events {
+		if event.Type != EventOutput {
+			continue
+		}
+
+		delay := event.Time - last
+		if maxIdle > 0 && delay > maxIdle {
+			delay = maxIdle
+		}
+		last = event.Time
+
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(float64(delay) / speed)):
+			}
+		}
+
+		if _, err := io.WriteString(w, event.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package asciicast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+
+	// Overwriting a readable file must not leave the recording readable
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := Create(path, Options{Title: "claude --model=opus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the recording to be private, got %v", info.Mode())
+	}
+
+	now := time.Unix(1700000000, 0)
+	recorder.now = func() time.Time {
+		return now
+	}
+
+	if err := recorder.Start(120, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	output := recorder.Output()
+
+	now = now.Add(500 * time.Millisecond)
+	_, _ = output.Write([]byte("hello\r\n"))
+
+	// A multi-byte character split across writes
+	now = now.Add(time.Second)
+	_, _ = output.Write([]byte("caf\xc3"))
+	_, _ = output.Write([]byte("\xa9\r\n"))
+
+	// Input isn't recorded unless asked for
+	recorder.RecordInput([]byte("secret\r"))
+
+	now = now.Add(time.Second)
+	recorder.Resize(100, 30)
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"version":2,"width":120,"height":40,"timestamp":1700000000,"title":"claude --model=opus","env":{"SHELL":"/bin/zsh","TERM":"xterm-256color"}}
+[0.500000,"o","hello\r\n"]
+[1.500000,"o","caf"]
+[1.500000,"o","é\r\n"]
+[2.500000,"r","100x30"]
+`
+	if string(contents) != expected {
+		t.Errorf("unexpected recording:\n%s\nwant:\n%s", contents, expected)
+	}
+
+	header, events, err := Read(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 120 || header.Height != 40 || header.Title != "claude --model=opus" {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if len(events) != 4 || events[3] != (Event{Time: 2500 * time.Millisecond, Type: EventResize, Data: "100x30"}) {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestRecorderInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+
+	recorder, err := Create(path, Options{Input: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a terminal, the recording starts with the default size on the first event
+	recorder.RecordInput([]byte("y\r"))
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	header, events, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != DefaultWidth || header.Height != DefaultHeight {
+		t.Errorf("unexpected size %dx%d", header.Width, header.Height)
+	}
+	if len(events) != 1 || events[0].Type != EventInput || events[0].Data != "y\r" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, contents := range []string{
+		"",
+		`{"version":1,"width":80,"height":24}`,
+		"{\"version\":2,\"width\":80,\"height\":24}\n[1.0,\"o\"]",
+	} {
+		if _, _, err := Read(strings.NewReader(contents)); !errors.Is(err, ErrInvalidRecording) {
+			t.Errorf("%q: expected ErrInvalidRecording, got %v", contents, err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	events := []Event{
+		{Time: 10 * time.Millisecond, Type: EventOutput, Data: "a"},
+		{Time: 20 * time.Millisecond, Type: EventInput, Data: "typed"},
+		{Time: time.Hour, Type: EventOutput, Data: "b"},
+		{Time: time.Hour, Type: EventResize, Data: "80x24"},
+	}
+
+	var buf bytes.Buffer
+
+	// The hour-long pause is shortened by the idle limit
+	started := time.Now()
+	if err := Replay(context.Background(), &buf, events, 10, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "ab" {
+		t.Errorf("only the output should be replayed, got %q", buf.String())
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("the replay took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Replay(ctx, &buf, events, 1, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the replay to be cancelled, got %v", err)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	event := Event{Time: 1234567 * time.Microsecond, Type: EventOutput, Data: "\x1b[31mred\x1b[0m"}
+
+	data, err := event.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Event
+	if err := decoded.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("got %+v, want %+v", decoded, event)
+	}
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// loadAgentRegistry loads the built-in and user-defined agents from ~/.config/chamber/agents
+func loadAgentRegistry() (*agent.Registry, error) {
+	dir, err := config.Path("agents")
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.NewRegistry(dir)
+}
+
+// NewAgentCmd generates a subcommand that runs the given agent in an ephemeral VM
+func NewAgentCmd(definition agent.Definition) *cobra.Command {
+	var vmImage string
+
+	title := definition.Name
+	if definition.Description != "" {
+		title = definition.Description
+	}
+
+	short := fmt.Sprintf("Run %s in an isolated Tart VM", title)
+	if len(definition.Flags) != 0 {
+		short += " with " + strings.Join(definition.Flags, " ")
+	}
+
+	long := fmt.Sprintf("Run %s inside an ephemeral Tart virtual machine with the current directory mounted.\n",
+		definition.Binary)
+	if len(definition.Flags) != 0 {
+		long += fmt.Sprintf("Automatically prepends %s to %s arguments for AI agent execution.\n",
+			strings.Join(definition.Flags, " "), definition.Binary)
+	}
+	if len(definition.Env) != 0 {
+		long += fmt.Sprintf("Requires %s to be set, which will be forwarded to the VM.\n",
+			strings.Join(definition.Env, ", "))
+	}
+	long += fmt.Sprintf(`
+Example:
+  chamber %[1]s
+  chamber %[1]s --vm=macos-xcode`, definition.Name)
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%[1]s [flags] [%[1]s-args...]", definition.Name),
+		Short: short,
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			args, help, err := parseLeadingFlags(cmd, args)
+			if err != nil {
+				return err
+			}
+			if help {
+				return cmd.Help()
+			}
+
+			env, err := definition.Environment(os.LookupEnv)
+			if err != nil {
+				return err
+			}
+
+			opts, err := defaultRunOptions(vmImage)
+			if err != nil {
+				return err
+			}
+			opts.env = env
+			opts.agent = definition.Name
+			opts.prompt = executor.ShellJoin(args)
+			opts.files = definition.Files
+
+			return runCommand(cmd.Context(), opts, definition.Command(args))
+		},
+	}
+
+	cmd.Flags().StringVar(&vmImage, "vm", "", "Seed VM to clone (default: the seed pinned in .chamber.yaml, then \"chamber seed default\")")
+
+	// Chamber's own flags are parsed by parseLeadingFlags, everything
+	// starting from the first unknown argument belongs to the agent
+	cmd.DisableFlagParsing = true
+
+	return cmd
+}
+
+// parseLeadingFlags consumes the command's own (and inherited) flags from the beginning of args
+// and returns the rest untouched, so that agent flags like --model=opus reach the agent.
+// This is needed because Cobra either drops unknown flags or fails on them.
+func parseLeadingFlags(cmd *cobra.Command, args []string) ([]string, bool, error) {
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.LocalFlags())
+	flags.AddFlagSet(cmd.InheritedFlags())
+
+	for len(args) != 0 {
+		arg := args[0]
+
+		if arg == "--" {
+			return args[1:], false, nil
+		}
+
+		if arg == "-h" || arg == "--help" {
+			return nil, true, nil
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = flags.Lookup(name)
+		} else if len(name) == 1 {
+			flag = flags.ShorthandLookup(name)
+		}
+
+		if flag == nil {
+			// Not ours, pass it to the agent along with everything else
+			break
+		}
+
+		args = args[1:]
+
+		if !hasValue {
+			switch {
+			case flag.NoOptDefVal != "":
+				value = flag.NoOptDefVal
+			case len(args) != 0:
+				value = args[0]
+				args = args[1:]
+			default:
+				return nil, false, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+		}
+
+		if err := flags.Set(flag.Name, value); err != nil {
+			return nil, false, fmt.Errorf("invalid argument %q for %s: %w", value, arg, err)
+		}
+	}
+
+	return args, false, nil
+}
+
+// resolveAgents looks up the named agents in the registry
+func resolveAgents(names []string) ([]agent.Definition, error) {
+	registry, err := loadAgentRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []agent.Definition
+
+	for _, name := range names {
+		definition, ok := registry.Get(name)
+		if !ok {
+			var available []string
+			for _, definition := range registry.All() {
+				available = append(available, definition.Name)
+			}
+
+			return nil, fmt.Errorf("unknown agent %q, available agents: %s", name, strings.Join(available, ", "))
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+// setUpAgent installs and authenticates the agent in the guest, then verifies that it runs
+func setUpAgent(ctx context.Context, sshClient *gossh.Client, definition agent.Definition) (seed.AgentInfo, error) {
+	if definition.Install != "" {
+		fmt.Fprintf(os.Stdout, "\nInstalling %s...\n", definition.Name)
+
+		if err := agent.Install(sshClient, definition, os.Stdout, os.Stderr); err != nil {
+			return seed.AgentInfo{}, err
+		}
+	}
+
+	if definition.Auth != "" {
+		fmt.Fprintf(os.Stdout, "\nConfiguring %s... Please follow the instructions below:\n", definition.Name)
+
+		terminal := ssh.NewTerminal(sshClient)
+		if err := terminal.RunInteractiveCommand(ctx, executor.LoginShell(definition.Auth)); err != nil {
+			return seed.AgentInfo{}, fmt.Errorf("failed to run %s for default configuration: %w", definition.Name, err)
+		}
+	}
+
+	version, err := agent.DetectVersion(sshClient, definition)
+	if err != nil {
+		return seed.AgentInfo{}, err
+	}
+
+	fmt.Fprintf(os.Stdout, "%s %s is ready\n", definition.Name, version)
+
+	return seed.AgentInfo{Name: definition.Name, Version: version}, nil
+}
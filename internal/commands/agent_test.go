@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+)
+
+func TestParseLeadingFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		vm       string
+		help     bool
+	}{
+		{
+			name:     "agent flags are passed through",
+			args:     []string{"--model=opus", "-p", "hello"},
+			expected: []string{"--model=opus", "-p", "hello"},
+			vm:       "",
+		},
+		{
+			name:     "own flags are consumed",
+			args:     []string{"--vm", "macos-xcode", "--model", "opus"},
+			expected: []string{"--model", "opus"},
+			vm:       "macos-xcode",
+		},
+		{
+			name:     "own flags with an equals sign",
+			args:     []string{"--vm=macos-xcode"},
+			expected: []string{},
+			vm:       "macos-xcode",
+		},
+		{
+			name:     "own flags after agent flags belong to the agent",
+			args:     []string{"--model", "opus", "--vm", "other"},
+			expected: []string{"--model", "opus", "--vm", "other"},
+			vm:       "",
+		},
+		{
+			name:     "double dash ends chamber flags",
+			args:     []string{"--", "--vm", "other"},
+			expected: []string{"--vm", "other"},
+			vm:       "",
+		},
+		{
+			name: "help",
+			args: []string{"--help"},
+			vm:   "",
+			help: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := NewRootCmd()
+			cmd := NewAgentCmd(agent.Definition{Name: "test-agent", Binary: "test-agent"})
+			root.AddCommand(cmd)
+
+			args, help, err := parseLeadingFlags(cmd, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if help != tt.help {
+				t.Errorf("help = %v, want %v", help, tt.help)
+			}
+			if !tt.help && !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("args = %q, want %q", args, tt.expected)
+			}
+			if vm, _ := cmd.Flags().GetString("vm"); vm != tt.vm {
+				t.Errorf("vm = %q, want %q", vm, tt.vm)
+			}
+		})
+	}
+}
+
+func TestParseLeadingFlagsInherited(t *testing.T) {
+	root := NewRootCmd()
+	cmd := NewAgentCmd(agent.Definition{Name: "test-agent", Binary: "test-agent"})
+	root.AddCommand(cmd)
+
+	defer func() {
+		dryRun = false
+	}()
+
+	args, _, err := parseLeadingFlags(cmd, []string{"--dry-run", "--resume"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !dryRun {
+		t.Error("expected the inherited --dry-run flag to be set")
+	}
+	if !reflect.DeepEqual(args, []string{"--resume"}) {
+		t.Errorf("args = %q, want [--resume]", args)
+	}
+}
+
+func TestDefaultRunOptions(t *testing.T) {
+	root := NewRootCmd()
+	cmd := NewAgentCmd(agent.Definition{Name: "test-agent", Binary: "test-agent"})
+	root.AddCommand(cmd)
+
+	defer func() {
+		cpuCount, memoryMB, sshUser, sshPass = 0, 0, "admin", "admin"
+	}()
+
+	if _, _, err := parseLeadingFlags(cmd, []string{"--cpu", "8", "--memory", "16384", "--ssh-user", "ci", "--ssh-pass", "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := defaultRunOptions("seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opts.events.Close()
+
+	if opts.vmImage != "seed" || opts.cpuCount != 8 || opts.memoryMB != 16384 || opts.sshUser != "ci" || opts.sshPass != "hunter2" {
+		t.Errorf("expected the global flags to be used, got %+v", opts)
+	}
+}
+
+func TestResolveAgents(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	definitions, err := resolveAgents([]string{"codex", "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definitions) != 2 || definitions[0].Name != "codex" || definitions[1].Name != "claude" {
+		t.Errorf("unexpected definitions: %+v", definitions)
+	}
+
+	if _, err := resolveAgents([]string{"claude", "nonexistent"}); err == nil {
+		t.Error("expected an error for an unknown agent")
+	}
+}
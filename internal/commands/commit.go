@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/git"
+)
+
+var ErrDirtyWorkingTree = errors.New("the working tree has uncommitted changes")
+
+// exportsChanges reports whether the agent's changes are committed or exported as a patch after the run
+func exportsChanges(opts runOptions) bool {
+	return opts.commit || opts.patch != ""
+}
+
+// takeExportSnapshot records the state of the working tree the changes will be compared to
+func takeExportSnapshot(ctx context.Context, opts runOptions, dir string) (*git.Snapshot, error) {
+	snapshot, err := git.TakeSnapshot(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("--commit and --patch require a git repository: %w", err)
+	}
+
+	// Committing everything would mix the agent's changes with ours, a patch only contains the agent's
+	if opts.commit && snapshot.Dirty() {
+		return nil, fmt.Errorf("%w in %s, commit or stash them before using --commit, or add --worktree",
+			ErrDirtyWorkingTree, snapshot.Dir)
+	}
+
+	return snapshot, nil
+}
+
+// commitMessage describes the changes made during a run
+func commitMessage(runID string, agent string, prompt string) string {
+	var message strings.Builder
+
+	if agent != "" {
+		fmt.Fprintf(&message, "chamber: changes by %s in run %s\n", agent, runID)
+	} else {
+		fmt.Fprintf(&message, "chamber: changes in run %s\n", runID)
+	}
+
+	if prompt != "" {
+		fmt.Fprintf(&message, "\nPrompt: %s\n", prompt)
+	}
+
+	fmt.Fprintf(&message, "\nChamber-Run-ID: %s\n", runID)
+	if agent != "" {
+		fmt.Fprintf(&message, "Chamber-Agent: %s\n", agent)
+	}
+
+	return message.String()
+}
+
+// runPrompt returns what the command was asked to do: the arguments passed to the agent,
+// or the whole command when not running an agent
+func runPrompt(opts runOptions, args []string) string {
+	if opts.agent != "" {
+		return opts.prompt
+	}
+
+	return executor.ShellJoin(args)
+}
+
+// exportChanges commits the changes made since the snapshot or moves them to a patch file,
+// which leaves the working tree as it was before the run
+func exportChanges(ctx context.Context, w io.Writer, opts runOptions, snapshot *git.Snapshot, message string) error {
+	if opts.commit {
+		commit, err := git.CommitAll(ctx, snapshot.Dir, message)
+		if err != nil {
+			return fmt.Errorf("failed to commit the changes: %w", err)
+		}
+
+		if commit == "" {
+			fmt.Fprintln(w, "No changes to commit")
+		} else {
+			fmt.Fprintf(w, "Committed the changes as %s\n", shortCommit(commit))
+		}
+
+		return nil
+	}
+
+	tree, err := git.WriteTree(ctx, snapshot.Dir, snapshot.Head)
+	if err != nil {
+		return fmt.Errorf("failed to export the changes: %w", err)
+	}
+
+	written, err := git.FormatPatch(ctx, snapshot, tree, message, opts.patch)
+	if err != nil {
+		return fmt.Errorf("failed to export the changes: %w", err)
+	}
+
+	if !written {
+		fmt.Fprintln(w, "No changes to export")
+
+		return nil
+	}
+
+	// Ignored files aren't part of the patch, so they're left alone too
+	if err := git.CheckoutTree(ctx, snapshot.Dir, tree, snapshot.Tree); err != nil {
+		return fmt.Errorf("wrote the changes to %s, but failed to remove them from the working tree: %w", opts.patch, err)
+	}
+
+	fmt.Fprintf(w, "Moved the changes to %s, apply them with: git am %s\n", opts.patch, opts.patch)
+
+	return nil
+}
+
+// exportCommands are the plan's counterpart of exportChanges
+func exportCommands(opts runOptions, dir string, message string) (string, []string) {
+	// The whole message doesn't fit on a line of the plan
+	message, _, _ = strings.Cut(message, "\n")
+
+	if opts.commit {
+		return "Commit changes", []string{
+			executor.ShellJoin([]string{"git", "-C", dir, "add", "--all", "--", ":/"}),
+			executor.ShellJoin([]string{"git", "-C", dir, "commit", "--no-verify", "--message", message}),
+		}
+	}
+
+	return "Move changes to a patch", []string{
+		executor.ShellJoin([]string{"git", "-C", dir, "format-patch", "--stdout", "-1", "<changes since the run started>"}) +
+			" > " + executor.ShellQuote(opts.patch),
+		executor.ShellJoin([]string{"git", "-C", dir, "read-tree", "--reset", "-u", "<working tree before the run>"}),
+	}
+}
@@ -0,0 +1,438 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Below this amount of free space even a copy-on-write clone will quickly run out of disk
+	doctorMinFreeDiskBytes = 5 << 30
+
+	doctorBootTimeout = 3 * time.Minute
+)
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+type checkResult struct {
+	name    string
+	status  checkStatus
+	message string
+	hint    string
+}
+
+type doctor struct {
+	out        io.Writer
+	seed       string
+	sshUser    string
+	sshPass    string
+	agents     []agent.Definition
+	skipBoot   bool
+	seedDiskGB int
+	results    []checkResult
+}
+
+func NewDoctorCmd() *cobra.Command {
+	var (
+		seed     string
+		skipBoot bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment chamber needs to run agents",
+		Long: `Check that Tart is installed and recent enough, that the seed VM exists,
+that there's enough free disk space for a clone, that no orphaned ephemeral VMs remain
+and that the seed boots, accepts SSH and has agents on the login shell PATH.
+
+Example:
+  chamber doctor
+  chamber doctor --skip-boot
+  chamber doctor --vm=macos-xcode`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if seed == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current directory: %w", err)
+				}
+
+				seed, err = resolveSeed(cwd)
+				if err != nil {
+					return err
+				}
+			}
+
+			registry, err := loadAgentRegistry()
+			if err != nil {
+				return err
+			}
+
+			d := &doctor{
+				out:      os.Stdout,
+				seed:     seed,
+				sshUser:  sshUser,
+				sshPass:  sshPass,
+				agents:   registry.All(),
+				skipBoot: skipBoot,
+			}
+
+			return d.run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVar(&seed, "vm", "", "Seed VM to check (default: the seed that would be used in the current directory)")
+	cmd.Flags().BoolVar(&skipBoot, "skip-boot", false, "Skip booting the seed VM")
+
+	return cmd
+}
+
+func (d *doctor) run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if d.checkTart(ctx) {
+		seedExists := d.checkSeed(ctx)
+		d.checkDiskSpace()
+		d.checkOrphanedVMs(ctx)
+
+		if seedExists && !d.skipBoot {
+			d.checkSeedBoots(ctx)
+		}
+	}
+
+	var failed int
+	for _, result := range d.results {
+		if result.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	fmt.Fprintln(d.out, "\nAll checks passed, chamber is ready to use.")
+
+	return nil
+}
+
+func (d *doctor) report(result checkResult) {
+	d.results = append(d.results, result)
+
+	fmt.Fprintf(d.out, "[%s] %s: %s\n", result.status, result.name, result.message)
+	if result.hint != "" && result.status != checkPass {
+		fmt.Fprintf(d.out, "       hint: %s\n", result.hint)
+	}
+}
+
+func (d *doctor) checkTart(ctx context.Context) bool {
+	const name = "Tart"
+
+	if !tart.Installed() {
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: "tart is not installed",
+			hint:    "install it with \"brew install cirruslabs/cli/tart\" or from https://github.com/cirruslabs/tart",
+		})
+
+		return false
+	}
+
+	capabilities, err := tart.DetectCapabilities(ctx)
+	if err != nil {
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: fmt.Sprintf("failed to determine the Tart version: %v", err),
+			hint:    "make sure \"tart --version\" works in your shell",
+		})
+
+		return false
+	}
+
+	if !tart.SupportedVersion(capabilities.Version) {
+		d.report(checkResult{
+			name:   name,
+			status: checkFail,
+			message: fmt.Sprintf("version %s is older than the minimum supported %s",
+				capabilities.Version, tart.MinimumVersion),
+			hint: "upgrade Tart with \"brew upgrade cirruslabs/cli/tart\"",
+		})
+
+		return false
+	}
+
+	d.report(checkResult{
+		name:    name,
+		status:  checkPass,
+		message: fmt.Sprintf("version %s (optional features: %s)", capabilities.Version, capabilities),
+	})
+
+	return true
+}
+
+func (d *doctor) checkSeed(ctx context.Context) bool {
+	const name = "Seed VM"
+
+	vms, err := tart.List(ctx)
+	if err != nil {
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: err.Error(),
+			hint:    "make sure \"tart list\" works in your shell",
+		})
+
+		return false
+	}
+
+	return d.reportSeed(vms)
+}
+
+func (d *doctor) reportSeed(vms []tart.VMInfo) bool {
+	const name = "Seed VM"
+
+	for _, vm := range vms {
+		if vm.Source == "local" && vm.Name == d.seed {
+			d.seedDiskGB = vm.Disk
+
+			d.report(checkResult{
+				name:    name,
+				status:  checkPass,
+				message: fmt.Sprintf("%q exists (%d GB disk)", d.seed, vm.Disk),
+			})
+
+			return true
+		}
+	}
+
+	d.report(checkResult{
+		name:    name,
+		status:  checkFail,
+		message: fmt.Sprintf("%q does not exist", d.seed),
+		hint: fmt.Sprintf("run \"chamber seed create %s ghcr.io/cirruslabs/macos-sequoia-base:latest\" to create it",
+			d.seed),
+	})
+
+	return false
+}
+
+func (d *doctor) checkDiskSpace() {
+	const name = "Disk space"
+
+	tartHome, err := tart.HomeDir()
+	if err != nil {
+		d.report(checkResult{name: name, status: checkWarn, message: err.Error()})
+
+		return
+	}
+
+	// Tart home might not exist yet on a fresh installation
+	path := tartHome
+	if _, err := os.Stat(path); err != nil {
+		path, _ = os.UserHomeDir()
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		d.report(checkResult{
+			name:    name,
+			status:  checkWarn,
+			message: fmt.Sprintf("failed to determine free space at %s: %v", path, err),
+		})
+
+		return
+	}
+
+	d.reportDiskSpace(path, uint64(stat.Bavail)*uint64(stat.Bsize))
+}
+
+func (d *doctor) reportDiskSpace(path string, free uint64) {
+	const name = "Disk space"
+
+	freeGB := free >> 30
+	hint := fmt.Sprintf("free up space on the volume containing %s, e.g. by running \"tart prune\"", path)
+
+	switch {
+	case free < doctorMinFreeDiskBytes:
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: fmt.Sprintf("only %d GB free", freeGB),
+			hint:    hint,
+		})
+	case d.seedDiskGB != 0 && freeGB < uint64(d.seedDiskGB):
+		d.report(checkResult{
+			name:    name,
+			status:  checkWarn,
+			message: fmt.Sprintf("%d GB free, which is less than the seed's %d GB disk", freeGB, d.seedDiskGB),
+			hint:    hint,
+		})
+	default:
+		d.report(checkResult{
+			name:    name,
+			status:  checkPass,
+			message: fmt.Sprintf("%d GB free", freeGB),
+		})
+	}
+}
+
+func (d *doctor) checkOrphanedVMs(ctx context.Context) {
+	const name = "Ephemeral VMs"
+
+	vms, err := tart.List(ctx)
+	if err != nil {
+		d.report(checkResult{name: name, status: checkWarn, message: err.Error()})
+
+		return
+	}
+
+	d.reportOrphanedVMs(vms)
+}
+
+func (d *doctor) reportOrphanedVMs(vms []tart.VMInfo) {
+	const name = "Ephemeral VMs"
+
+	var orphaned []string
+	for _, vm := range vms {
+		// Running ephemeral VMs most likely belong to other chamber sessions
+		if vm.Source == "local" && tart.IsEphemeral(vm.Name) && !vm.Running {
+			orphaned = append(orphaned, vm.Name)
+		}
+	}
+
+	if len(orphaned) == 0 {
+		d.report(checkResult{
+			name:    name,
+			status:  checkPass,
+			message: "no orphaned ephemeral VMs",
+		})
+
+		return
+	}
+
+	d.report(checkResult{
+		name:    name,
+		status:  checkWarn,
+		message: fmt.Sprintf("%d orphaned ephemeral VM(s): %s", len(orphaned), strings.Join(orphaned, ", ")),
+		hint:    fmt.Sprintf("remove them with \"tart delete %s\"", strings.Join(orphaned, " ")),
+	})
+}
+
+func (d *doctor) checkSeedBoots(ctx context.Context) {
+	const name = "Seed boot"
+
+	ctx, cancel := context.WithTimeout(ctx, doctorBootTimeout)
+	defer cancel()
+
+	fmt.Fprintf(d.out, "Booting an ephemeral clone of %s, this may take a minute...\n", d.seed)
+
+	vm, err := tart.NewVMClonedFrom(ctx, d.seed, nil)
+	if err != nil {
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: err.Error(),
+			hint:    "make sure the seed VM is not corrupted, e.g. by running \"tart run " + d.seed + "\"",
+		})
+
+		return
+	}
+	defer vm.Close()
+
+	if err := vm.Configure(ctx, 0, 0); err != nil {
+		d.report(checkResult{name: name, status: checkFail, message: err.Error()})
+
+		return
+	}
+
+	vm.Start(ctx, nil)
+
+	ip, err := vm.RetrieveIP(ctx)
+	if err != nil {
+		d.report(checkResult{
+			name:    name,
+			status:  checkFail,
+			message: fmt.Sprintf("VM didn't get an IP address: %v", err),
+			hint:    "run \"tart run " + d.seed + "\" and check that the guest boots to the desktop",
+		})
+
+		return
+	}
+
+	d.report(checkResult{
+		name:    name,
+		status:  checkPass,
+		message: fmt.Sprintf("booted with IP %s", ip),
+	})
+
+	sshClient, err := ssh.WaitForSSH(ctx, fmt.Sprintf("%s:22", ip), d.sshUser, d.sshPass)
+	if err != nil {
+		d.report(checkResult{
+			name:    "Seed SSH",
+			status:  checkFail,
+			message: err.Error(),
+			hint: fmt.Sprintf("enable Remote Login in the seed's System Settings and make sure %s can log in "+
+				"with the password passed with --ssh-pass", d.sshUser),
+		})
+
+		return
+	}
+	defer sshClient.Close()
+
+	d.report(checkResult{
+		name:    "Seed SSH",
+		status:  checkPass,
+		message: "accepts SSH connections",
+	})
+
+	d.reportAgents(func(binary string) (string, error) {
+		return ssh.Output(sshClient, executor.LoginShell("command -v "+executor.ShellQuote(binary)))
+	})
+}
+
+// reportAgents checks that the registered agents are on the login shell PATH, which lookup
+// returns the path of the binary in
+func (d *doctor) reportAgents(lookup func(binary string) (string, error)) {
+	for _, definition := range d.agents {
+		name := "Agent " + definition.Name
+
+		output, err := lookup(definition.Binary)
+		if err != nil {
+			d.report(checkResult{
+				name:    name,
+				status:  checkWarn,
+				message: "not found on the login shell PATH",
+				hint:    "run \"chamber init\" or install it in the seed with \"tart run " + d.seed + "\"",
+			})
+
+			continue
+		}
+
+		d.report(checkResult{
+			name:    name,
+			status:  checkPass,
+			message: strings.TrimSpace(output),
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+// statuses returns the status of each check the doctor reported
+func statuses(d *doctor) []checkStatus {
+	var result []checkStatus
+
+	for _, checked := range d.results {
+		result = append(result, checked.status)
+	}
+
+	return result
+}
+
+func TestDoctorSeed(t *testing.T) {
+	vms := []tart.VMInfo{
+		{Source: "oci", Name: "seed", Disk: 50},
+		{Source: "local", Name: "seed", Disk: 80},
+	}
+
+	d := &doctor{out: io.Discard, seed: "seed"}
+	if !d.reportSeed(vms) || d.seedDiskGB != 80 {
+		t.Errorf("expected the local seed to be found with its 80 GB disk, got %d GB", d.seedDiskGB)
+	}
+
+	// An image in Tart's OCI cache isn't a seed that can be cloned
+	d = &doctor{out: io.Discard, seed: "other"}
+	if d.reportSeed(vms[:1]) || !reflect.DeepEqual(statuses(d), []checkStatus{checkFail}) {
+		t.Errorf("expected the seed check to fail, got %v", statuses(d))
+	}
+}
+
+func TestDoctorDiskSpace(t *testing.T) {
+	for _, test := range []struct {
+		free       uint64
+		seedDiskGB int
+		expected   checkStatus
+	}{
+		{free: 1 << 30, expected: checkFail},
+		{free: 20 << 30, seedDiskGB: 50, expected: checkWarn},
+		{free: 20 << 30, expected: checkPass},
+		{free: 100 << 30, seedDiskGB: 50, expected: checkPass},
+	} {
+		d := &doctor{out: io.Discard, seedDiskGB: test.seedDiskGB}
+		d.reportDiskSpace("/", test.free)
+
+		if got := statuses(d); !reflect.DeepEqual(got, []checkStatus{test.expected}) {
+			t.Errorf("%d bytes free with a %d GB seed: expected %s, got %v", test.free, test.seedDiskGB, test.expected, got)
+		}
+	}
+}
+
+func TestDoctorOrphanedVMs(t *testing.T) {
+	// Running ephemeral VMs belong to other chamber sessions
+	d := &doctor{out: io.Discard}
+	d.reportOrphanedVMs([]tart.VMInfo{
+		{Source: "local", Name: "seed"},
+		{Source: "local", Name: "chamber-ephemeral-running", Running: true},
+	})
+
+	if !reflect.DeepEqual(statuses(d), []checkStatus{checkPass}) {
+		t.Errorf("expected no orphaned VMs, got %+v", d.results)
+	}
+
+	d = &doctor{out: io.Discard}
+	d.reportOrphanedVMs([]tart.VMInfo{{Source: "local", Name: "chamber-ephemeral-stopped"}})
+
+	if !reflect.DeepEqual(statuses(d), []checkStatus{checkWarn}) ||
+		d.results[0].hint != `remove them with "tart delete chamber-ephemeral-stopped"` {
+		t.Errorf("expected a warning about the stopped VM, got %+v", d.results)
+	}
+}
+
+func TestDoctorAgents(t *testing.T) {
+	d := &doctor{
+		out:  io.Discard,
+		seed: "seed",
+		agents: []agent.Definition{
+			{Name: "claude", Binary: "claude"},
+			{Name: "aider", Binary: "aider"},
+		},
+	}
+
+	var looked []string
+	d.reportAgents(func(binary string) (string, error) {
+		looked = append(looked, binary)
+
+		if binary == "aider" {
+			return "", errors.New("exit status 1")
+		}
+
+		return "/opt/homebrew/bin/" + binary + "\n", nil
+	})
+
+	// Agents from ~/.config/chamber/agents are checked like the built-in ones
+	if !reflect.DeepEqual(looked, []string{"claude", "aider"}) {
+		t.Errorf("expected both agents to be looked up, got %v", looked)
+	}
+	if !reflect.DeepEqual(statuses(d), []checkStatus{checkPass, checkWarn}) {
+		t.Errorf("expected claude to pass and aider to warn, got %+v", d.results)
+	}
+	if d.results[0].message != "/opt/homebrew/bin/claude" {
+		t.Errorf("expected the path of claude, got %q", d.results[0].message)
+	}
+}
@@ -0,0 +1,354 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/history"
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryCmd() *cobra.Command {
+	var filter historyFilter
+	var limit int
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List past runs",
+		Long: `List the runs chamber has recorded, the most recent first.
+
+Example:
+  chamber history
+  chamber history --here --agent claude
+  chamber history --since 2025-01-07 --until 2025-01-08
+  chamber history --since 7d --failed`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := filter.parse(time.Now())
+			if err != nil {
+				return err
+			}
+
+			return runHistory(os.Stdout, parsed, limit, asJSON)
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.agent, "agent", "", "Only show runs of this agent")
+	cmd.Flags().StringVar(&filter.seed, "seed", "", "Only show runs cloned from this seed")
+	cmd.Flags().StringVar(&filter.dir, "dir", "", "Only show runs in this directory or its subdirectories")
+	cmd.Flags().BoolVar(&filter.here, "here", false, "Only show runs in the current directory or its subdirectories")
+	cmd.Flags().StringVar(&filter.since, "since", "", "Only show runs started since a date (2025-01-07), time (RFC 3339) or a while ago (36h, 7d)")
+	cmd.Flags().StringVar(&filter.until, "until", "", "Only show runs started before a date, time or a while ago")
+	cmd.Flags().BoolVar(&filter.failed, "failed", false, "Only show runs that failed or exited with a non-zero status")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Show at most this many runs, 0 shows all")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the runs as JSON")
+
+	return cmd
+}
+
+func NewShowCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "show [run-id]",
+		Short: "Show the details of a run",
+		Long: `Show the details of a run: where and how it ran, how long each phase took, its outcome,
+and where its log and recording are. Any unique prefix of the run ID will do, without an ID
+the most recent run is shown.
+
+Example:
+  chamber show
+  chamber show 20250107-101502`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var id string
+			if len(args) != 0 {
+				id = args[0]
+			}
+
+			return runShow(os.Stdout, id, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the run as JSON")
+
+	return cmd
+}
+
+type historyFilter struct {
+	agent  string
+	seed   string
+	dir    string
+	here   bool
+	since  string
+	until  string
+	failed bool
+}
+
+func (filter historyFilter) parse(now time.Time) (history.Filter, error) {
+	result := history.Filter{
+		Agent:  filter.agent,
+		Seed:   filter.seed,
+		Failed: filter.failed,
+	}
+
+	dir := filter.dir
+	if filter.here {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return result, fmt.Errorf("failed to get current directory: %w", err)
+		}
+
+		dir = cwd
+	}
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return result, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+
+		result.Dir = abs
+	}
+
+	var err error
+
+	if result.Since, err = parseHistoryTime(filter.since, now); err != nil {
+		return result, err
+	}
+	if result.Until, err = parseHistoryTime(filter.until, now); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// parseHistoryTime parses a date in the local time zone, an RFC 3339 time
+// or how long ago, e.g. 36h or 7d. An empty value results in the zero time.
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date (2025-01-07), an RFC 3339 time or a duration (36h, 7d)", value)
+}
+
+func runHistory(w io.Writer, filter history.Filter, limit int, asJSON bool) error {
+	store, err := history.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	var matching []*history.Run
+	for _, run := range runs {
+		if filter.Match(run) && (limit == 0 || len(matching) < limit) {
+			matching = append(matching, run)
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		if matching == nil {
+			matching = []*history.Run{}
+		}
+
+		return encoder.Encode(matching)
+	}
+
+	printRuns(w, matching, time.Now())
+
+	return nil
+}
+
+func printRuns(w io.Writer, runs []*history.Run, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tSTATUS\tAGENT\tDIRECTORY")
+
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", run.ID, run.StartedAt.Local().Format("2006-01-02 15:04"),
+			formatDuration(run.Duration(now)), run.Status(), valueOrDash(run.Agent), shortenHome(run.Dir))
+	}
+
+	_ = tw.Flush()
+}
+
+func runShow(w io.Writer, id string, asJSON bool) error {
+	store, err := history.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	var run *history.Run
+
+	if id == "" {
+		runs, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			return fmt.Errorf("%w: no runs were recorded yet", history.ErrNotFound)
+		}
+
+		run = runs[0]
+	} else {
+		run, err = store.Load(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(run)
+	}
+
+	return printRunDetails(w, run, time.Now())
+}
+
+func printRunDetails(w io.Writer, run *history.Run, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Run:\t%s\n", run.ID)
+	fmt.Fprintf(tw, "Directory:\t%s\n", run.Dir)
+	fmt.Fprintf(tw, "Seed:\t%s\n", valueOrDash(run.Seed))
+	fmt.Fprintf(tw, "VM:\t%s\n", valueOrDash(run.VM))
+	fmt.Fprintf(tw, "Agent:\t%s\n", valueOrDash(run.Agent))
+	fmt.Fprintf(tw, "Command:\t%s\n", executor.ShellJoin(run.Command))
+	fmt.Fprintf(tw, "Started:\t%s\n", run.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	if !run.FinishedAt.IsZero() {
+		fmt.Fprintf(tw, "Finished:\t%s\n", run.FinishedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(tw, "Duration:\t%s\n", formatDuration(run.Duration(now)))
+	fmt.Fprintf(tw, "Status:\t%s\n", run.Status())
+	if run.Error != "" {
+		fmt.Fprintf(tw, "Error:\t%s\n", run.Error)
+	}
+	if run.Worktree != "" {
+		fmt.Fprintf(tw, "Worktree:\t%s\n", run.Worktree)
+		fmt.Fprintf(tw, "Branch:\t%s\n", run.Branch)
+	}
+	if run.LogPath != "" {
+		fmt.Fprintf(tw, "Log:\t%s\n", run.LogPath)
+	}
+	if run.RecordingPath != "" {
+		fmt.Fprintf(tw, "Recording:\t%s\n", run.RecordingPath)
+	}
+	if run.Before != nil {
+		fmt.Fprintf(tw, "Undo:\tchamber undo %s\n", run.ID)
+	}
+
+	if len(run.Phases) != 0 {
+		fmt.Fprintln(tw, "Phases:")
+
+		for _, phase := range run.Phases {
+			fmt.Fprintf(tw, "  %s:\t%s\n", phase.Name, formatDuration(phase.Duration))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// startRun records the beginning of a run in the history along with a log of its lifecycle events
+func startRun(id string, opts runOptions, cwd string, args []string, now time.Time) (*history.Run, *history.Store, *os.File, error) {
+	store, err := history.DefaultStore()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	run := history.NewRun(id, now)
+	run.Dir = cwd
+	run.Seed = opts.vmImage
+	run.Agent = opts.agent
+	run.Command = args
+
+	if opts.worktree != nil {
+		run.Worktree = opts.worktree.Path
+		run.Branch = opts.worktree.Branch
+	}
+
+	if opts.record != "" {
+		if run.RecordingPath, err = filepath.Abs(opts.record); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+	}
+
+	run.LogPath, err = config.Path("logs", "run-"+run.ID+".jsonl")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(run.LogPath), 0o700); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	logFile, err := os.OpenFile(run.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+
+	if err := store.Save(run); err != nil {
+		_ = logFile.Close()
+
+		return nil, nil, nil, err
+	}
+
+	return run, store, logFile, nil
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return duration.Round(time.Millisecond).String()
+	}
+
+	return duration.Round(time.Second).String()
+}
+
+// shortenHome replaces the home directory prefix with ~
+func shortenHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return filepath.Join("~", rest)
+	}
+
+	return path
+}
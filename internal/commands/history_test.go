@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"":                     {},
+		"7d":                   now.AddDate(0, 0, -7),
+		"36h":                  now.Add(-36 * time.Hour),
+		"2025-01-07":           time.Date(2025, 1, 7, 0, 0, 0, 0, time.Local),
+		"2025-01-07T10:00:00Z": time.Date(2025, 1, 7, 10, 0, 0, 0, time.UTC),
+	} {
+		parsed, err := parseHistoryTime(value, now)
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+
+			continue
+		}
+		if !parsed.Equal(expected) {
+			t.Errorf("%q: got %s, want %s", value, parsed, expected)
+		}
+	}
+
+	if _, err := parseHistoryTime("last tuesday", now); err == nil {
+		t.Error("expected an error for an unsupported time")
+	}
+}
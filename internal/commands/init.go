@@ -2,49 +2,102 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/registry"
+	"github.com/cirruslabs/chamber/internal/seed"
 	"github.com/cirruslabs/chamber/internal/ssh"
 	"github.com/cirruslabs/chamber/internal/vm/tart"
 	"github.com/spf13/cobra"
 )
 
 func NewInitCmd() *cobra.Command {
-	var remoteVM string
+	var (
+		remoteVM   string
+		name       string
+		agentNames []string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "init <remote-vm>",
-		Short: "Initialize chamber by cloning a remote VM and setting up Claude Code",
+		Short: "Initialize chamber by cloning a remote VM and setting up coding agents",
 		Long: `Initialize chamber by:
-1. Cloning a remote Tart VM to 'chamber-seed' local VM
-2. Installing @anthropic-ai/claude-code globally via npm
-3. Running claude setup-token with output redirected to current terminal
+1. Cloning a remote Tart VM to a local seed VM ('chamber-seed' by default)
+2. Installing each requested agent (Claude Code by default)
+3. Running each agent's login flow with output redirected to current terminal
+4. Verifying that the agents run and recording their versions in the seed metadata
+
+Seeds pushed with "chamber seed push" are used as is, only agents they don't have yet are set up.
 
 Example:
-  chamber init ghcr.io/cirruslabs/macos-sequoia-base:latest`,
+  chamber init ghcr.io/cirruslabs/macos-sequoia-base:latest
+  chamber init --agent claude,codex,gemini ghcr.io/cirruslabs/macos-sequoia-base:latest
+  chamber init --name xcode ghcr.io/cirruslabs/macos-sequoia-xcode:latest
+  chamber init ghcr.io/myteam/seed:latest`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			remoteVM = args[0]
-			return runInit(cmd.Context(), remoteVM)
+			return runInit(cmd.Context(), remoteVM, name, agentNames)
 		},
 	}
 
+	cmd.Flags().StringVar(&name, "name", seed.DefaultName, "Name of the seed VM to create")
+	cmd.Flags().StringSliceVar(&agentNames, "agent", []string{"claude"},
+		"Agents to install and authenticate in the seed VM (comma-separated)")
+
 	return cmd
 }
 
-func runInit(ctx context.Context, remoteVM string) error {
-	// Check if Tart is installed
-	if !tart.Installed() {
-		return fmt.Errorf("tart is not installed. Please install it from https://github.com/cirruslabs/tart")
+func runInit(ctx context.Context, remoteVM string, name string, agentNames []string) error {
+	// Resolve the agents first, so that a typo doesn't cost us a 20GB clone
+	definitions, err := resolveAgents(agentNames)
+	if err != nil {
+		return err
 	}
 
 	// Create context with cancellation if not provided
 	if ctx == nil {
 		ctx = context.Background()
 	}
+
+	// Check if a supported Tart version is installed
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("seed %q already exists, remove it first with \"chamber seed rm %s\"", name, name)
+	}
+
+	metadata, source := initialSeedMetadata(ctx, remoteVM, name)
+
+	// Seeds pushed with "chamber seed push" record their agents, which don't need to be set up again
+	var pending []agent.Definition
+	for _, definition := range definitions {
+		if metadata.HasAgent(definition.Name) {
+			fmt.Fprintf(os.Stdout, "%s is already set up in %s, skipping it\n", definition.Name, remoteVM)
+
+			continue
+		}
+
+		pending = append(pending, definition)
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -57,15 +110,25 @@ func runInit(ctx context.Context, remoteVM string) error {
 		cancel()
 	}()
 
-	// Clone the remote VM to chamber-seed
-	fmt.Fprintf(os.Stdout, "Cloning %s to chamber-seed...\n", remoteVM)
-	if err := tart.CloneVM(ctx, remoteVM, "chamber-seed"); err != nil {
+	// Clone the remote VM to the seed
+	fmt.Fprintf(os.Stdout, "Cloning %s to %s...\n", source, name)
+	if err := tart.CloneVM(ctx, source, name); err != nil {
 		return fmt.Errorf("failed to clone VM: %w", err)
 	}
 
-	// Start the chamber-seed VM
-	fmt.Fprintln(os.Stdout, "Starting chamber-seed VM...")
-	vm, err := tart.NewVM(ctx, "chamber-seed")
+	if len(pending) == 0 {
+		if err := store.Save(metadata); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "\nInitialization complete! %s VM is already set up and ready to use.\n", name)
+
+		return nil
+	}
+
+	// Start the seed VM
+	fmt.Fprintf(os.Stdout, "Starting %s VM...\n", name)
+	vm, err := tart.NewVM(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to create VM: %w", err)
 	}
@@ -96,30 +159,59 @@ func runInit(ctx context.Context, remoteVM string) error {
 	}
 	defer sshClient.Close()
 
-	// Install Claude Code
-	fmt.Fprintln(os.Stdout, "Installing @anthropic-ai/claude-code...")
-	session, err := sshClient.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create SSH session: %w", err)
+	// Install and authenticate each requested agent, recording what ended up in the seed
+	for _, definition := range pending {
+		info, err := setUpAgent(ctx, sshClient, definition)
+		if err != nil {
+			return err
+		}
+
+		metadata.SetAgent(info)
 	}
-	defer session.Close()
 
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
-	if err := session.Run("zsh -l -c 'npm install -g @anthropic-ai/claude-code'"); err != nil {
-		return fmt.Errorf("failed to install claude-code: %w", err)
+	tools, err := seed.DetectTools(sshClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
+	metadata.Tools = tools
 
-	// Run claude to configure defaults
-	fmt.Fprintln(os.Stdout, "\nConfiguring Claude... Please follow the instructions below:")
-	terminal := ssh.NewTerminal(sshClient)
-	if err := terminal.RunInteractiveCommand(ctx, "zsh -l -c 'claude'"); err != nil {
-		return fmt.Errorf("failed to run claude for default configuration: %w", err)
+	if err := store.Save(metadata); err != nil {
+		return err
 	}
 
-	fmt.Fprintln(os.Stdout, "\nInitialization complete! chamber-seed VM is ready to use.")
+	fmt.Fprintf(os.Stdout, "\nInitialization complete! %s VM is ready to use.\n", name)
 	fmt.Fprintln(os.Stdout, "\nYou can customize the seed VM by running:")
-	fmt.Fprintln(os.Stdout, "  tart run chamber-seed")
+	fmt.Fprintf(os.Stdout, "  tart run %s\n", name)
 	fmt.Fprintln(os.Stdout, "\nThis allows you to install dependencies like Go or any other specific packages.")
 	return nil
 }
+
+// initialSeedMetadata returns the metadata for a new seed cloned from remoteVM and the reference
+// to clone. Images pushed with "chamber seed push" carry their metadata in annotations and are
+// pinned to the digest the metadata was read from.
+func initialSeedMetadata(ctx context.Context, remoteVM string, name string) (*seed.Metadata, string) {
+	metadata := &seed.Metadata{
+		Name:      name,
+		BaseImage: remoteVM,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	ref, err := registry.ParseReference(remoteVM)
+	if err != nil || !tart.IsRemote(remoteVM) {
+		return metadata, remoteVM
+	}
+
+	pulled, source, err := fetchSeedMetadata(ctx, ref, false)
+	if err != nil {
+		// Tart might still be able to pull it, e.g. with credentials from the keychain
+		if !errors.Is(err, seed.ErrNoMetadata) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to check %s for chamber metadata: %v\n", remoteVM, err)
+		}
+
+		return metadata, remoteVM
+	}
+
+	pulled.Name = name
+
+	return pulled, source
+}
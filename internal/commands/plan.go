@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/environ"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/secret"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/cirruslabs/chamber/internal/workspace"
+)
+
+type planStep struct {
+	description string
+	commands    []string
+}
+
+// executionPlan describes everything runCommand would do, without doing it
+type executionPlan struct {
+	steps []planStep
+}
+
+// buildPlan is the dry-run counterpart of runCommand: it must be kept in sync with it,
+// but doesn't touch Tart or the network, which makes it easy to test. Without capabilities,
+// the "tart run" arguments that depend on the installed Tart are marked as detected at run time.
+func buildPlan(
+	opts runOptions,
+	cwd string,
+	args []string,
+	capabilities *tart.Capabilities,
+	now time.Time,
+) (*executionPlan, error) {
+	workDir := runWorkDir(opts, cwd)
+	vmName := tart.EphemeralVMName(now)
+	exec := executor.New(nil, workDir, filepath.Base(workDir))
+	// The plan is meant to be shared and logged, so it only names the forwarded variables
+	env := environ.Redact(opts.env)
+
+	var secretFiles []string
+	for _, declared := range opts.secrets {
+		if err := declared.Validate(); err != nil {
+			return nil, err
+		}
+
+		if declared.Env != "" {
+			env[declared.Env] = environ.Redacted
+		} else {
+			secretFiles = append(secretFiles, fmt.Sprintf("%s -> %s", declared.Ref, path.Join(secret.VolumePath, declared.File)))
+		}
+	}
+	if len(secretFiles) != 0 {
+		env[secret.EnvVar] = secret.VolumePath
+	}
+
+	exec.SetEnv(env)
+
+	plan := &executionPlan{}
+
+	if opts.worktree != nil {
+		plan.add("Create git worktree", executor.ShellJoin(append([]string{"git"}, opts.worktree.AddArgs()...)))
+	}
+
+	if opts.pin.Pinned() {
+		plan.add("Verify the seed was pulled from the pinned image", opts.pin.Name+"@"+opts.pin.Digest)
+	}
+
+	if !tart.IsRemote(opts.vmImage) {
+		// Pinned seeds aren't updated, as they would no longer match the pinned image
+		updates := opts.autoUpdateDays > 0 && !opts.pin.Pinned()
+		if updates {
+			plan.add(fmt.Sprintf("Update the seed's agents if it hasn't been updated in %d day(s)", opts.autoUpdateDays),
+				executor.ShellJoin([]string{"chamber", "seed", "update", opts.vmImage}))
+		}
+
+		if opts.requireAudit {
+			var commands []string
+			if updates {
+				commands = append(commands, executor.ShellJoin([]string{"chamber", "seed", "audit", opts.vmImage})+
+					" (if the seed was updated)")
+			}
+			commands = append(commands, "(refuse the seed unless it passed an audit since it last changed)")
+			plan.add("Check the seed's audit", commands...)
+		}
+	}
+
+	if !opts.noSnapshots {
+		plan.add("Snapshot working directory for chamber undo", workDir)
+	}
+
+	var excluded []string
+	if opts.workspaceCopy != "" {
+		var err error
+
+		excluded, err = workspace.Excluded(workDir, opts.ignore)
+		if err != nil {
+			return nil, err
+		}
+
+		copies := []string{fmt.Sprintf("%s -> %s", workDir, opts.workspaceCopy)}
+		for _, excludedPath := range excluded {
+			copies = append(copies, "exclude "+excludedPath)
+		}
+		if opts.ignore != nil {
+			plan.add("Copy working directory without the files excluded by "+workspace.IgnoreFile, copies...)
+		} else {
+			plan.add("Copy working directory", copies...)
+		}
+	}
+
+	plan.add("Clone VM", tartCommand("clone", opts.vmImage, vmName))
+
+	var setCommands []string
+	for _, args := range tart.ConfigureArgs(vmName, opts.cpuCount, opts.memoryMB) {
+		setCommands = append(setCommands, tartCommand(args...))
+	}
+	plan.add("Configure VM", setCommands...)
+
+	detected := capabilities == nil
+	if detected {
+		capabilities = &tart.Capabilities{NoClipboard: true, NoAudio: true, DirTag: true}
+	}
+
+	runArgs, err := tart.RunArgs(vmName, runMounts(opts, cwd), capabilities)
+	if err != nil {
+		return nil, err
+	}
+	if detected {
+		plan.add("Start VM", tartCommand(append([]string{"run"}, runArgs...)...),
+			"(whether Tart supports --no-clipboard and --no-audio is detected at run time)")
+	} else {
+		plan.add("Start VM", tartCommand(append([]string{"run"}, runArgs...)...))
+	}
+
+	plan.add("Wait for IP", tartCommand(append([]string{"ip"}, tart.IPArgs(vmName)...)...))
+	plan.add("Connect via SSH", fmt.Sprintf("%s@<vm-ip>:22", opts.sshUser))
+	if syncsWorkspace(opts) {
+		plan.add("Copy working directory to the VM", "tar -cf - -C "+executor.ShellQuote(opts.workspaceCopy)+" . | "+exec.CopyInCommand())
+	} else {
+		plan.add("Mount working directory", exec.MountCommand())
+	}
+	if len(excluded) != 0 {
+		plan.add("Verify the excluded files are hidden", exec.VisiblePathsCommand(excluded))
+	}
+
+	files, err := renderFiles(opts, cwd)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 0 {
+		var copies []string
+		for _, file := range files {
+			copies = append(copies, fmt.Sprintf("%s -> %s (%#o)", file.Source, file.Dst, uint32(file.Mode)))
+		}
+		plan.add("Inject configuration files", copies...)
+	}
+
+	if len(secretFiles) != 0 {
+		plan.add("Mount in-memory secrets volume", append([]string{secret.MountVolumeCommand()}, secretFiles...)...)
+	}
+
+	if opts.interactive {
+		plan.add("Run command (interactive)", exec.InteractiveCommand(args[0], args[1:]))
+	} else {
+		plan.add("Run command", strings.Split(strings.TrimSpace(exec.ShellScript(args[0], args[1:])), "\n")...)
+	}
+
+	if syncsWorkspace(opts) {
+		plan.add("Copy working directory out of the VM", exec.CopyOutCommand()+" | tar -xf - -C "+executor.ShellQuote(opts.workspaceCopy))
+	}
+
+	var cleanup []string
+	if len(secretFiles) != 0 {
+		cleanup = append(cleanup, secret.UnmountVolumeCommand("<secrets-device>"))
+	}
+	if !syncsWorkspace(opts) {
+		cleanup = append(cleanup, exec.UnmountCommand())
+	}
+	cleanup = append(cleanup,
+		tartCommand(append([]string{"stop"}, tart.StopArgs(vmName)...)...),
+		tartCommand("delete", vmName),
+	)
+	plan.add("Clean up", cleanup...)
+
+	if opts.workspaceCopy != "" {
+		plan.add("Sync changes back", fmt.Sprintf("%s -> %s", opts.workspaceCopy, workDir))
+	}
+
+	if exportsChanges(opts) {
+		description, commands := exportCommands(opts, workDir, commitMessage(opts.runID, opts.agent, runPrompt(opts, args)))
+		plan.add(description, commands...)
+	}
+
+	return plan, nil
+}
+
+func (plan *executionPlan) add(description string, commands ...string) {
+	plan.steps = append(plan.steps, planStep{
+		description: description,
+		commands:    commands,
+	})
+}
+
+func (plan *executionPlan) Print(w io.Writer) {
+	for i, step := range plan.steps {
+		fmt.Fprintf(w, "%d. %s\n", i+1, step.description)
+
+		for _, command := range step.commands {
+			fmt.Fprintf(w, "   %s\n", command)
+		}
+	}
+}
+
+// workspaceMounts returns the directory mounts that expose the working directory to the VM
+func workspaceMounts(cwd string) []tart.DirectoryMount {
+	return []tart.DirectoryMount{
+		{
+			Name:     filepath.Base(cwd),
+			Path:     cwd,
+			ReadOnly: false,
+		},
+	}
+}
+
+func tartCommand(args ...string) string {
+	return executor.ShellJoin(append([]string{"tart"}, args...))
+}
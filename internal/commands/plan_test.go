@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/secret"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+func TestBuildPlan(t *testing.T) {
+	opts := runOptions{
+		vmImage:     "chamber-seed",
+		cpuCount:    4,
+		sshUser:     "admin",
+		interactive: true,
+	}
+
+	plan, err := buildPlan(opts, "/Users/fedor/my project", []string{"claude", "--model=opus"},
+		&tart.Capabilities{NoClipboard: true}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	plan.Print(&buf)
+
+	expected := `1. Snapshot working directory for chamber undo
+   /Users/fedor/my project
+2. Clone VM
+   tart clone chamber-seed chamber-ephemeral-20250102-030405
+3. Configure VM
+   tart set chamber-ephemeral-20250102-030405 --random-mac
+   tart set chamber-ephemeral-20250102-030405 --cpu 4
+4. Start VM
+   tart run --no-graphics --no-clipboard --dir 'my project:/Users/fedor/my project' chamber-ephemeral-20250102-030405
+5. Wait for IP
+   tart ip --wait 30 chamber-ephemeral-20250102-030405
+6. Connect via SSH
+   admin@<vm-ip>:22
+7. Mount working directory
+   sudo umount "/Volumes/My Shared Files" && mkdir -p ~/workspace && mount_virtiofs com.apple.virtio-fs.automount ~/workspace
+8. Run command (interactive)
+   zsh -l -c 'cd "$HOME"/workspace/'\''my project'\'' && claude --model=opus'
+9. Clean up
+   umount "$HOME"/workspace/'my project'
+   tart stop --timeout 5 chamber-ephemeral-20250102-030405
+   tart delete chamber-ephemeral-20250102-030405
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected plan:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestBuildPlanWithoutCapabilities(t *testing.T) {
+	opts := runOptions{
+		vmImage: "chamber-seed",
+		sshUser: "admin",
+	}
+
+	plan, err := buildPlan(opts, "/Users/fedor/project", []string{"claude"}, nil,
+		time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	plan.Print(&buf)
+
+	expected := `4. Start VM
+   tart run --no-graphics --no-clipboard --no-audio --dir project:/Users/fedor/project chamber-ephemeral-20250102-030405
+   (whether Tart supports --no-clipboard and --no-audio is detected at run time)
+`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected the plan to contain:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestBuildPlanRedactsEnv(t *testing.T) {
+	opts := runOptions{
+		vmImage:     "chamber-seed",
+		sshUser:     "admin",
+		interactive: true,
+		env:         map[string]string{"ANTHROPIC_API_KEY": "sk-ant-secret"},
+	}
+
+	plan, err := buildPlan(opts, "/Users/fedor/app", []string{"claude"},
+		&tart.Capabilities{}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	plan.Print(&buf)
+
+	if strings.Contains(buf.String(), "sk-ant-secret") {
+		t.Errorf("the plan leaks the variable's value:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `export ANTHROPIC_API_KEY='\''<redacted>'\''`) {
+		t.Errorf("the plan should name the forwarded variable:\n%s", buf.String())
+	}
+}
+
+func TestBuildPlanSecrets(t *testing.T) {
+	opts := runOptions{
+		vmImage:     "chamber-seed",
+		sshUser:     "admin",
+		interactive: true,
+		env:         map[string]string{"GH_TOKEN": "secret://keychain/github"},
+		secrets: []secret.Secret{
+			{Ref: "secret://cmd/op read op://dev/gcloud", File: "gcloud.json"},
+			{Ref: "secret://env/OPENAI_API_KEY", Env: "OPENAI_API_KEY"},
+		},
+	}
+
+	plan, err := buildPlan(opts, "/Users/fedor/app", []string{"codex"},
+		&tart.Capabilities{}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	plan.Print(&buf)
+
+	for _, expected := range []string{
+		"secret://cmd/op read op://dev/gcloud -> /Volumes/chamber-secrets/gcloud.json",
+		"export CHAMBER_SECRETS=",
+		"export GH_TOKEN=",
+		"export OPENAI_API_KEY=",
+		"hdiutil detach -force <secrets-device>",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("the plan doesn't contain %q:\n%s", expected, buf.String())
+		}
+	}
+
+	if strings.Contains(buf.String(), "keychain/github") {
+		t.Errorf("the plan shows environment variable values:\n%s", buf.String())
+	}
+}
+
+func TestBuildPlanSeedChecks(t *testing.T) {
+	opts := runOptions{
+		vmImage:        "chamber-seed",
+		sshUser:        "admin",
+		autoUpdateDays: 7,
+		requireAudit:   true,
+	}
+
+	plan, err := buildPlan(opts, "/Users/fedor/app", []string{"codex"},
+		&tart.Capabilities{}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	plan.Print(&buf)
+
+	expected := `1. Update the seed's agents if it hasn't been updated in 7 day(s)
+   chamber seed update chamber-seed
+2. Check the seed's audit
+   chamber seed audit chamber-seed (if the seed was updated)
+   (refuse the seed unless it passed an audit since it last changed)
+3. Snapshot working directory for chamber undo
+`
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("unexpected plan:\n%s\nwant it to start with:\n%s", buf.String(), expected)
+	}
+
+	// A pinned seed is verified instead of updated
+	opts.pin = seed.Pin{Name: "chamber-seed", Digest: "sha256:abcd"}
+
+	plan, err = buildPlan(opts, "/Users/fedor/app", []string{"codex"},
+		&tart.Capabilities{}, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	plan.Print(&buf)
+
+	expected = `1. Verify the seed was pulled from the pinned image
+   chamber-seed@sha256:abcd
+2. Check the seed's audit
+   (refuse the seed unless it passed an audit since it last changed)
+3. Snapshot working directory for chamber undo
+`
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("unexpected plan:\n%s\nwant it to start with:\n%s", buf.String(), expected)
+	}
+}
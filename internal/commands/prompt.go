@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// canPrompt reports whether there's a user at the terminal to answer questions,
+// which is not the case in CI or when chamber's input is redirected
+func canPrompt() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+func NewReplayCmd() *cobra.Command {
+	var speed float64
+	var idleLimit time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "replay <file.cast>",
+		Short: "Play back a session recorded with --record",
+		Long: `Play back an asciinema v2 recording of a run in the terminal, with the original timing.
+
+The recordings can also be played with asciinema itself or embedded with asciinema-player.
+
+Example:
+  chamber --record session.cast claude
+  chamber replay session.cast
+  chamber replay --speed 4 --idle-limit 1s session.cast`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			return runReplay(ctx, os.Stdout, args[0], speed, idleLimit)
+		},
+	}
+
+	cmd.Flags().Float64Var(&speed, "speed", 1, "Playback speed multiplier")
+	cmd.Flags().DurationVar(&idleLimit, "idle-limit", 2*time.Second, "Shorten pauses longer than this, 0 keeps the original pauses")
+
+	return cmd
+}
+
+func runReplay(ctx context.Context, w io.Writer, path string, speed float64, idleLimit time.Duration) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	header, events, err := asciicast.Read(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if header.Title != "" {
+		fmt.Fprintf(os.Stderr, "Replaying %s (%dx%d)\n", header.Title, header.Width, header.Height)
+	}
+
+	return asciicast.Replay(ctx, w, events, speed, idleLimit)
+}
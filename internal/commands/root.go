@@ -3,7 +3,9 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/cirruslabs/chamber/internal/agent"
 	"github.com/cirruslabs/chamber/internal/version"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +17,18 @@ var (
 	sshUser                    string
 	sshPass                    string
 	dangerouslySkipPermissions bool
+	eventsFormat               string
+	eventsFile                 string
+	dryRun                     bool
+	envVars                    []string
+	envFile                    string
+	redactOutput               bool
+	recordPath                 string
+	recordInput                bool
+	useWorktree                bool
+	commitChanges              bool
+	patchPath                  string
+	workspaceMode              string
 )
 
 func NewRootCmd() *cobra.Command {
@@ -32,7 +46,8 @@ Example:
   chamber claude                                              # Run Claude AI in VM
   chamber claude --model opus .                               # Run Claude with specific model
   chamber codex                                               # Run Codex in VM
-  chamber init ghcr.io/cirruslabs/macos-sequoia-base:latest   # Initialize chamber-seed VM
+  chamber init ghcr.io/cirruslabs/macos-sequoia-base:latest   # Initialize the default seed VM
+  chamber doctor                                              # Diagnose why chamber doesn't start
 `,
 		Version:       version.FullVersion,
 		SilenceUsage:  true,
@@ -51,17 +66,33 @@ Example:
 			}
 
 			// Backward compatibility: run command directly
-			// Use interactive mode for better terminal support
-			return runCommand(context.Background(), vmImage, cpuCount, memoryMB, sshUser, sshPass, true, args)
+			opts, err := defaultRunOptions(vmImage)
+			if err != nil {
+				return err
+			}
+
+			return runCommand(context.Background(), opts, args)
 		},
 	}
 
 	// Add global flags for backward compatibility
-	cmd.PersistentFlags().StringVar(&vmImage, "vm", "chamber-seed", "Tart VM image to use (default: chamber-seed)")
+	cmd.PersistentFlags().StringVar(&vmImage, "vm", "", "Seed VM to clone (default: the seed pinned in .chamber.yaml, then \"chamber seed default\")")
 	cmd.PersistentFlags().Uint32Var(&cpuCount, "cpu", 0, "Number of CPUs (0 = default)")
 	cmd.PersistentFlags().Uint32Var(&memoryMB, "memory", 0, "Memory in MB (0 = default)")
 	cmd.PersistentFlags().StringVar(&sshUser, "ssh-user", "admin", "SSH username")
 	cmd.PersistentFlags().StringVar(&sshPass, "ssh-pass", "admin", "SSH password")
+	cmd.PersistentFlags().StringVar(&eventsFormat, "events", "", "Emit machine-readable lifecycle events in the given format (supported: jsonl)")
+	cmd.PersistentFlags().StringVar(&eventsFile, "events-file", "-", "Where to write events: a file path, fd:N for an inherited file descriptor or - for stderr")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the execution plan without creating a VM")
+	cmd.PersistentFlags().StringArrayVarP(&envVars, "env", "e", nil, "Forward an environment variable to the VM: KEY=VALUE, or KEY to copy it from the host (can be repeated)")
+	cmd.PersistentFlags().StringVar(&envFile, "env-file", "", "Forward the environment variables from a dotenv file, e.g. .env.chamber")
+	cmd.PersistentFlags().BoolVar(&redactOutput, "redact", false, "Mask secret values and common token patterns in the command's output")
+	cmd.PersistentFlags().StringVar(&recordPath, "record", "", "Record the session to an asciinema v2 file, e.g. session.cast")
+	cmd.PersistentFlags().BoolVar(&recordInput, "record-input", false, "Also record what's typed into the terminal, which might include passwords")
+	cmd.PersistentFlags().BoolVar(&useWorktree, "worktree", false, "Run in a new git worktree on a chamber/<run-id> branch instead of the working directory")
+	cmd.PersistentFlags().BoolVar(&commitChanges, "commit", false, "Commit the changes made during the run, with the run ID, agent and prompt in the message")
+	cmd.PersistentFlags().StringVar(&patchPath, "patch", "", "Move the changes made during the run to a git format-patch file instead of leaving them in the working tree")
+	cmd.PersistentFlags().StringVar(&workspaceMode, "workspace", workspaceMount, "How the VM gets the working directory: mount it over virtiofs, or sync a copy over SSH")
 	cmd.PersistentFlags().BoolVar(&dangerouslySkipPermissions, "dangerously-skip-permissions", false, "Skip permission checks (use with caution)")
 
 	// Stop parsing flags after the first non-flag argument
@@ -69,8 +100,30 @@ Example:
 
 	// Add subcommands
 	cmd.AddCommand(NewInitCmd())
-	cmd.AddCommand(NewClaudeCmd())
-	cmd.AddCommand(NewCodexCmd())
+	cmd.AddCommand(NewDoctorCmd())
+	cmd.AddCommand(NewSeedCmd())
+	cmd.AddCommand(NewReplayCmd())
+	cmd.AddCommand(NewHistoryCmd())
+	cmd.AddCommand(NewShowCmd())
+	cmd.AddCommand(NewUndoCmd())
+
+	// Add a subcommand for each registered agent
+	registry, err := loadAgentRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, only built-in agents are available\n", err)
+		registry, _ = agent.NewRegistry("")
+	}
+
+	for _, definition := range registry.All() {
+		if existing, _, err := cmd.Find([]string{definition.Name}); err == nil && existing != cmd {
+			fmt.Fprintf(os.Stderr, "Warning: agent %q conflicts with a built-in command, ignoring it\n",
+				definition.Name)
+
+			continue
+		}
+
+		cmd.AddCommand(NewAgentCmd(definition))
+	}
 
 	return cmd
 }
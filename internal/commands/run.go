@@ -0,0 +1,696 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/asciicast"
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/environ"
+	"github.com/cirruslabs/chamber/internal/events"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/git"
+	"github.com/cirruslabs/chamber/internal/history"
+	"github.com/cirruslabs/chamber/internal/inject"
+	"github.com/cirruslabs/chamber/internal/redact"
+	"github.com/cirruslabs/chamber/internal/secret"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/snapshot"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/cirruslabs/chamber/internal/workspace"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// runOptions describe a single ephemeral VM run
+type runOptions struct {
+	vmImage        string
+	pin            seed.Pin
+	cpuCount       uint32
+	memoryMB       uint32
+	sshUser        string
+	sshPass        string
+	interactive    bool
+	dryRun         bool
+	env            map[string]string
+	envVars        []string
+	envFile        string
+	redact         bool
+	noSnapshots    bool
+	autoUpdateDays int
+	requireAudit   bool
+	record         string
+	recordInput    bool
+	useWorktree    bool
+	worktree       *git.Worktree
+	ignore         *workspace.Ignore
+	workspaceCopy  string
+	workspace      string
+	commit         bool
+	patch          string
+	runID          string
+	agent          string
+	prompt         string
+	files          []inject.File
+	secrets        []secret.Secret
+	events         *events.Emitter
+}
+
+// defaultRunOptions returns the run options set by the global flags, with the given VM image
+// since agent subcommands have a --vm flag of their own
+func defaultRunOptions(vmImage string) (runOptions, error) {
+	emitter, err := events.Open(eventsFormat, eventsFile)
+	if err != nil {
+		return runOptions{}, err
+	}
+
+	return runOptions{
+		vmImage:     vmImage,
+		cpuCount:    cpuCount,
+		memoryMB:    memoryMB,
+		sshUser:     sshUser,
+		sshPass:     sshPass,
+		interactive: true,
+		dryRun:      dryRun,
+		envVars:     envVars,
+		envFile:     envFile,
+		redact:      redactOutput,
+		record:      recordPath,
+		recordInput: recordInput,
+		useWorktree: useWorktree,
+		commit:      commitChanges,
+		patch:       patchPath,
+		workspace:   workspaceMode,
+		events:      emitter,
+	}, nil
+}
+
+func runCommand(ctx context.Context, opts runOptions, args []string) (err error) {
+	emitter := opts.events
+	defer emitter.Close()
+
+	// Get current working directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	cwd, err = filepath.Abs(cwd)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Create context with cancellation
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	settings, err := config.Resolve(cwd)
+	if err != nil {
+		return err
+	}
+
+	if opts.vmImage == "" {
+		opts.pin, err = settingsSeedPin(settings)
+		if err != nil {
+			return err
+		}
+
+		opts.vmImage = opts.pin.Name
+	}
+
+	opts.files = append(opts.files, settings.Files...)
+
+	opts.env, err = forwardedEnv(opts, settings, os.LookupEnv, os.Environ())
+	if err != nil {
+		return err
+	}
+
+	opts.secrets = append(opts.secrets, settings.Secrets...)
+	opts.noSnapshots = opts.noSnapshots || settings.DisableSnapshots
+	opts.autoUpdateDays = settings.AutoUpdateDays
+	opts.requireAudit = settings.RequireAudit
+
+	if opts.commit && opts.patch != "" {
+		return fmt.Errorf("--commit and --patch can't be used together")
+	}
+	if err := checkWorkspaceMode(opts); err != nil {
+		return err
+	}
+	if opts.patch != "" && !filepath.IsAbs(opts.patch) {
+		opts.patch = filepath.Join(cwd, opts.patch)
+	}
+
+	runID := history.NewID(time.Now())
+	opts.runID = runID
+
+	if opts.useWorktree {
+		opts.worktree, err = planWorktree(ctx, cwd, runID)
+		if err != nil {
+			return err
+		}
+
+		// Only a guess until the worktree is created, but good enough for the plan
+		gitEnv, err := worktreeEnv(opts.worktree, opts.sshUser)
+		if err != nil {
+			return err
+		}
+		for name, value := range gitEnv {
+			opts.env[name] = value
+		}
+	}
+
+	opts.ignore, opts.workspaceCopy, err = planWorkspaceCopy(opts, cwd, runID)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		return printPlan(os.Stdout, opts, cwd, args)
+	}
+
+	// Record the run in the history whatever its outcome, with the phase timings taken from the lifecycle events
+	run, runs, logFile, err := startRun(runID, opts, cwd, args, time.Now())
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	defer func() {
+		run.Finish(time.Now(), err)
+		if err := runs.Save(run); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}()
+	emitter = emitter.Observe(run.Observe).Observe(events.New(logFile).Emit)
+	fmt.Fprintf(os.Stdout, "Run ID: %s\n", run.ID)
+
+	// Give the agent a worktree of its own, which outlives the VM. It's created before anything
+	// is derived from the environment, which points git in the guest to the worktree.
+	if opts.worktree != nil {
+		fmt.Fprintf(os.Stdout, "Creating worktree on branch %s...\n", opts.worktree.Branch)
+		if err := createWorktree(ctx, opts); err != nil {
+			return err
+		}
+		defer func() {
+			// The run might have been interrupted, but the worktree still needs to be dealt with
+			if err := finishWorktree(context.Background(), os.Stdin, os.Stdout, opts.worktree, canPrompt()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	// Render the files to inject before creating the VM, so that mistakes fail fast
+	files, err := renderFiles(opts, cwd)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are resolved on the host for this run only and are never written to the seed
+	// or the working directory: they reach the guest as environment variables of the command
+	// or as files on an in-memory volume that's detached once the command exits
+	secrets, err := secret.Resolve(ctx, secret.DefaultResolvers(cwd), opts.env, opts.secrets)
+	if err != nil {
+		return err
+	}
+	if len(secrets.Files) != 0 {
+		secrets.Env[secret.EnvVar] = secret.VolumePath
+	}
+
+	var recorder *asciicast.Recorder
+	if opts.record != "" {
+		recorder, err = asciicast.Create(opts.record, asciicast.Options{
+			Title: executor.ShellJoin(args),
+			Input: opts.recordInput,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	// The cancellation cause lets us tell an interrupt apart from a VM that exited on its own
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Handle interrupts before the seed is pulled or booted to be updated or audited,
+	// so that none of the VMs involved outlive the run
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up...")
+		cancel(nil)
+	}()
+
+	// Check if a supported Tart version is installed
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return emitter.Fail(events.PhaseClone, err)
+	}
+
+	// Make sure we're about to clone exactly the seed the project is pinned to
+	if err := ensurePinnedSeed(ctx, opts.pin); err != nil {
+		return emitter.Fail(events.PhaseClone, err)
+	}
+
+	if !tart.IsRemote(opts.vmImage) {
+		updated, err := autoUpdateSeed(ctx, opts.vmImage, opts.autoUpdateDays, opts.pin)
+		if err != nil {
+			return emitter.Fail(events.PhaseClone, err)
+		}
+
+		if opts.requireAudit {
+			// We've just changed the seed, so it's on us to re-audit it
+			if updated {
+				if err := runSeedAudit(ctx, os.Stdout, opts.vmImage, false); err != nil {
+					return emitter.Fail(events.PhaseClone, err)
+				}
+			}
+
+			if err := ensureAuditedSeed(opts.vmImage); err != nil {
+				return emitter.Fail(events.PhaseClone, err)
+			}
+		}
+	}
+
+	// Extract directory name for dynamic mounting
+	workDir := runWorkDir(opts, cwd)
+	dirName := filepath.Base(workDir)
+
+	// Snapshot the working directory around the run, so that "chamber undo" can revert it
+	if !opts.noSnapshots {
+		snapshots, err := snapshot.DefaultStore()
+		if err != nil {
+			return err
+		}
+		if err := pruneSnapshots(ctx, runs, snapshots, keptSnapshots-1); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		run.Before, err = snapshots.Take(ctx, workDir, run.ID+"-before", lastSnapshot(runs, workDir))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v, the run can't be undone\n", err)
+		} else {
+			defer func() {
+				// Taken after the changes are committed, which is part of the run
+				after, err := snapshots.Take(context.Background(), workDir, run.ID+"-after", run.Before)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+				run.After = after
+			}()
+		}
+	}
+
+	// Compare the working tree after the run to its state before the run, so that
+	// the changes that were already there don't end up in the commit or the patch
+	if exportsChanges(opts) {
+		snapshot, err := takeExportSnapshot(ctx, opts, workDir)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			message := commitMessage(runID, opts.agent, runPrompt(opts, args))
+			if err := exportChanges(context.Background(), os.Stdout, opts, snapshot, message); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	// The VM only sees a copy of the working directory without the ignored files,
+	// the changes made to it are brought back once the VM is gone
+	var staged *workspace.Copy
+	// A partial copy out of the VM can't tell the removed files from the ones tar couldn't read
+	copiedOut := true
+	if opts.workspaceCopy != "" {
+		if opts.ignore != nil {
+			fmt.Fprintf(os.Stdout, "Copying the working directory without the files excluded by %s...\n", workspace.IgnoreFile)
+		} else {
+			fmt.Fprintln(os.Stdout, "Copying the working directory...")
+		}
+		staged, err = workspace.Stage(ctx, workDir, opts.workspaceCopy, opts.ignore)
+		if err != nil {
+			_ = os.RemoveAll(filepath.Dir(opts.workspaceCopy))
+
+			return err
+		}
+		defer func() {
+			if !copiedOut {
+				fmt.Fprintf(os.Stderr, "Warning: the changes made in the VM weren't synced back, what could be copied out is in %s\n",
+					staged.Dir)
+
+				return
+			}
+
+			if err := syncWorkspace(os.Stdout, staged); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	// Create VM
+	fmt.Fprintf(os.Stdout, "Creating ephemeral VM from %s...\n", opts.vmImage)
+	vm, err := tart.NewVMClonedFrom(ctx, opts.vmImage, nil)
+	if err != nil {
+		return emitter.Fail(events.PhaseClone, err)
+	}
+	emitter.Emit(events.Event{Type: events.VMCloned, VM: vm.Ident(), Source: opts.vmImage})
+	defer func() {
+		fmt.Fprintln(os.Stdout, "Cleaning up VM...")
+		if err := vm.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to clean up VM: %v\n", err)
+			_ = emitter.Fail(events.PhaseCleanup, err)
+		}
+		emitter.Emit(events.Event{Type: events.CleanupDone, VM: vm.Ident()})
+	}()
+
+	// Configure VM
+	fmt.Fprintln(os.Stdout, "Configuring VM...")
+	if err := vm.Configure(ctx, opts.cpuCount, opts.memoryMB); err != nil {
+		return emitter.Fail(events.PhaseConfigure, err)
+	}
+
+	// Start VM with directory mount
+	fmt.Fprintln(os.Stdout, "Starting VM...")
+	vm.Start(ctx, runMounts(opts, cwd))
+
+	// Watch the VM for the whole run, so that a guest crash interrupts
+	// whatever we're doing instead of leaving us waiting on a dead VM
+	stopMonitoring := vm.Monitor(cancel)
+	defer stopMonitoring()
+
+	vmFailure := func(err error) error {
+		if cause := context.Cause(ctx); errors.Is(cause, tart.ErrVMExited) {
+			return cause
+		}
+
+		return err
+	}
+
+	// Wait for VM to get IP
+	fmt.Fprintln(os.Stdout, "Waiting for VM to boot...")
+	ip, err := vm.RetrieveIP(ctx)
+	if err != nil {
+		return emitter.Fail(events.PhaseBoot, vmFailure(fmt.Errorf("failed to get VM IP: %w", err)))
+	}
+	fmt.Fprintf(os.Stdout, "VM IP: %s\n", ip)
+	emitter.Emit(events.Event{Type: events.VMBooted, VM: vm.Ident(), IP: ip})
+
+	// Connect via SSH
+	fmt.Fprintln(os.Stdout, "Connecting to VM via SSH...")
+	sshAddr := fmt.Sprintf("%s:22", ip)
+	sshClient, err := ssh.WaitForSSH(ctx, sshAddr, opts.sshUser, opts.sshPass)
+	if err != nil {
+		return emitter.Fail(events.PhaseSSH, vmFailure(fmt.Errorf("failed to connect via SSH: %w", err)))
+	}
+	defer sshClient.Close()
+	emitter.Emit(events.Event{Type: events.SSHConnected, VM: vm.Ident(), IP: ip})
+
+	// Create executor
+	exec := executor.New(sshClient, workDir, dirName)
+	exec.SetEnv(secrets.Env)
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+
+	if recorder != nil {
+		stdout = io.MultiWriter(stdout, recorder.Output())
+		stderr = io.MultiWriter(stderr, recorder.Output())
+		exec.SetRecorder(recorder)
+	}
+
+	// Redact before recording, so that secrets don't end up in the recording either
+	if opts.redact || settings.Redact {
+		values := redactedValues(opts, secrets)
+		redactedStdout := redact.NewWriter(stdout, values)
+		defer redactedStdout.Close()
+		redactedStderr := redact.NewWriter(stderr, values)
+		defer redactedStderr.Close()
+
+		stdout, stderr = redactedStdout, redactedStderr
+	}
+
+	exec.SetOutput(stdout, stderr)
+
+	if syncsWorkspace(opts) {
+		// The VM works on its own disk, and its changes are copied out before it's destroyed
+		fmt.Fprintln(os.Stdout, "Copying working directory to the VM...")
+		if err := copyIn(exec, staged); err != nil {
+			return emitter.Fail(events.PhaseMount, vmFailure(err))
+		}
+		defer func() {
+			// Even when the run is interrupted, the VM is only stopped afterwards
+			fmt.Fprintln(os.Stdout, "Copying working directory out of the VM...")
+			if err := copyOut(sshAddr, opts, dirName, staged); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				copiedOut = false
+			}
+		}()
+	} else {
+		// Mount working directory
+		fmt.Fprintln(os.Stdout, "Mounting working directory...")
+		if err := exec.MountWorkingDirectory(ctx); err != nil {
+			return emitter.Fail(events.PhaseMount, vmFailure(err))
+		}
+		defer func() {
+			client, done, err := cleanupClient(ctx, sshClient, sshAddr, opts)
+			if err != nil {
+				return
+			}
+			defer done()
+
+			_ = executor.New(client, workDir, dirName).UnmountWorkingDirectory(context.Background())
+		}()
+	}
+
+	// Make sure the excluded files really are out of the VM's reach before anything runs there
+	if staged != nil {
+		visible, err := exec.VisiblePaths(staged.Excluded)
+		if err != nil {
+			return emitter.Fail(events.PhaseMount, err)
+		}
+		if len(visible) != 0 {
+			return emitter.Fail(events.PhaseMount, fmt.Errorf("%w: %s", ErrExposedFiles, strings.Join(visible, ", ")))
+		}
+	}
+
+	emitter.Emit(events.Event{Type: events.MountReady, VM: vm.Ident()})
+
+	// Inject agent configuration
+	if len(files) != 0 {
+		fmt.Fprintln(os.Stdout, "Injecting configuration files...")
+		if err := inject.Upload(sshClient, files); err != nil {
+			return emitter.Fail(events.PhaseInject, vmFailure(err))
+		}
+	}
+
+	if len(secrets.Files) != 0 {
+		fmt.Fprintln(os.Stdout, "Mounting in-memory secrets volume...")
+		volume, err := secret.MountVolume(sshClient)
+		if err != nil {
+			return emitter.Fail(events.PhaseInject, vmFailure(err))
+		}
+		defer func() {
+			client, done, err := cleanupClient(ctx, sshClient, sshAddr, opts)
+			if err == nil {
+				defer done()
+
+				err = volume.Unmount(client)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+
+		if err := volume.Write(secrets.Files); err != nil {
+			return emitter.Fail(events.PhaseInject, vmFailure(err))
+		}
+	}
+
+	// Execute command
+	fmt.Fprintf(os.Stdout, "Executing command: %s %v\n", args[0], args[1:])
+	fmt.Fprintln(os.Stdout, strings.Repeat("-", 80))
+	if len(secrets.Env) != 0 {
+		fmt.Fprintf(os.Stdout, "Forwarding environment variables: %s\n", strings.Join(environ.Names(secrets.Env), ", "))
+	}
+	emitter.Emit(events.Event{Type: events.CommandStarted, VM: vm.Ident(), Command: args, Env: environ.Names(secrets.Env)})
+
+	// Use interactive or non-interactive execution based on the parameter
+	if opts.interactive {
+		err = exec.ExecuteInteractive(ctx, args[0], args[1:])
+	} else {
+		err = exec.Execute(ctx, args[0], args[1:])
+	}
+
+	code, exited := exitCode(err)
+	if exited {
+		emitter.Emit(events.Event{Type: events.CommandExited, VM: vm.Ident(), ExitCode: events.Int(code)})
+	}
+	if err != nil {
+		if !exited {
+			return emitter.Fail(events.PhaseCommand, vmFailure(err))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// forwardedEnv collects the environment variables to forward to the VM. Later sources take precedence:
+// the agent's required variables, the configured allowlist, the --env-file and finally the -e flags.
+// Only the -e flags may reference secrets: the --env-file usually sits in the working directory,
+// where the agent could plant a reference that runs a command or reads a file on the host.
+func forwardedEnv(
+	opts runOptions,
+	settings *config.Settings,
+	lookup environ.LookupFunc,
+	hostEnv []string,
+) (map[string]string, error) {
+	env, err := environ.Allowed(settings.Env, hostEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range opts.env {
+		env[name] = value
+	}
+
+	if opts.envFile != "" {
+		fileEnv, err := environ.ReadFile(opts.envFile, lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range fileEnv {
+			env[name] = value
+		}
+	}
+
+	for _, name := range environ.Names(env) {
+		if secret.IsReference(env[name]) {
+			return nil, fmt.Errorf("%w: %s", secret.ErrUntrusted, name)
+		}
+	}
+
+	for _, arg := range opts.envVars {
+		name, value, err := environ.ParseAssignment(arg, lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		env[name] = value
+	}
+
+	return env, nil
+}
+
+// redactedValues returns the values to mask in the command's output: those of all the variables
+// the command gets, whichever way they were forwarded, and of the secret files. Only the variables
+// chamber sets itself, which point to places in the guest, are left alone.
+func redactedValues(opts runOptions, secrets *secret.Resolved) []string {
+	own := map[string]bool{secret.EnvVar: true}
+	if opts.worktree != nil {
+		own["GIT_DIR"] = true
+		own["GIT_WORK_TREE"] = true
+	}
+
+	var values []string
+	for _, name := range environ.Names(secrets.Env) {
+		if !own[name] {
+			values = append(values, secrets.Env[name])
+		}
+	}
+
+	return append(values, secrets.Values...)
+}
+
+// renderFiles renders the configuration files to inject into the VM for this run
+func renderFiles(opts runOptions, cwd string) ([]inject.Rendered, error) {
+	home := path.Join("/Users", opts.sshUser)
+	// The VM works in the worktree when there's one, not in the current directory
+	workDir := runWorkDir(opts, cwd)
+
+	return inject.Render(opts.files, inject.Data{
+		Agent:     opts.agent,
+		Seed:      opts.vmImage,
+		Project:   workDir,
+		Workspace: path.Join(home, "workspace", filepath.Base(workDir)),
+		Home:      home,
+		Env:       opts.env,
+	})
+}
+
+// printPlan prints what runCommand would do for the given options without doing it
+func printPlan(w io.Writer, opts runOptions, cwd string, args []string) error {
+	// The plan only depends on the configuration and the flags, Tart isn't even run
+	plan, err := buildPlan(opts, cwd, args, nil, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Dry run, the following steps would be performed:")
+	plan.Print(w)
+
+	return nil
+}
+
+// reconnectTimeout bounds the wait for the VM when connecting to it again to clean up
+const reconnectTimeout = 30 * time.Second
+
+// reconnect opens a connection to the VM of its own, waiting for at most reconnectTimeout
+func reconnect(addr string, opts runOptions) (*gossh.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reconnectTimeout)
+	defer cancel()
+
+	return ssh.WaitForSSH(ctx, addr, opts.sshUser, opts.sshPass)
+}
+
+// cleanupClient returns the connection to clean up the VM over after the command and a function
+// that closes it: the run's connection, unless it was closed because the run was interrupted
+func cleanupClient(
+	ctx context.Context,
+	sshClient *gossh.Client,
+	addr string,
+	opts runOptions,
+) (*gossh.Client, func(), error) {
+	if ctx.Err() == nil {
+		return sshClient, func() {}, nil
+	}
+
+	// There's nothing left to clean up in a VM that's gone
+	if cause := context.Cause(ctx); errors.Is(cause, tart.ErrVMExited) {
+		return nil, nil, cause
+	}
+
+	client, err := reconnect(addr, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, func() { _ = client.Close() }, nil
+}
+
+// exitCode extracts the guest command's exit status from the execution result.
+// The second return value is false when the command didn't run to completion.
+func exitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/spf13/cobra"
+)
+
+func NewSeedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Manage seed VMs that ephemeral VMs are cloned from",
+	}
+
+	cmd.AddCommand(newSeedListCmd())
+	cmd.AddCommand(newSeedCreateCmd())
+	cmd.AddCommand(newSeedRmCmd())
+	cmd.AddCommand(newSeedDefaultCmd())
+	cmd.AddCommand(newSeedInspectCmd())
+	cmd.AddCommand(newSeedUpdateCmd())
+	cmd.AddCommand(newSeedAuditCmd())
+	cmd.AddCommand(newSeedPushCmd())
+	cmd.AddCommand(newSeedPullCmd())
+	cmd.AddCommand(newSeedBuildCmd())
+	cmd.AddCommand(newSeedPruneCmd())
+
+	return cmd
+}
+
+// resolveSeed picks the seed for the project in dir when none is given on the command line:
+// the one pinned in the project's .chamber.yaml, then the user's default, then chamber-seed
+func resolveSeed(dir string) (string, error) {
+	pin, err := resolveSeedPin(dir)
+	if err != nil {
+		return "", err
+	}
+
+	return pin.Name, nil
+}
+
+// resolveSeedPin is like resolveSeed, but also returns the digest the seed is pinned to, if any
+func resolveSeedPin(dir string) (seed.Pin, error) {
+	settings, err := config.Resolve(dir)
+	if err != nil {
+		return seed.Pin{}, err
+	}
+
+	return settingsSeedPin(settings)
+}
+
+func settingsSeedPin(settings *config.Settings) (seed.Pin, error) {
+	if settings.Seed != "" {
+		return seed.ParsePin(settings.Seed)
+	}
+
+	return seed.Pin{Name: seed.DefaultName}, nil
+}
+
+// userDefaultSeed returns the seed set with "chamber seed default"
+func userDefaultSeed() (string, error) {
+	settings, err := config.LoadUserSettings()
+	if err != nil {
+		return "", err
+	}
+
+	if settings.Seed != "" {
+		return settings.Seed, nil
+	}
+
+	return seed.DefaultName, nil
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedAuditCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "audit [name]",
+		Short: "Scan a seed VM for credentials that every agent run would be exposed to",
+		Long: `Boot an ephemeral clone of the seed VM and scan it over SSH for credentials in well-known
+locations (SSH keys, .netrc, cloud provider credentials, browser cookies and more) and for
+configuration files containing tokens or other high-entropy strings.
+
+The seed fails the audit if there are high severity findings. Set require_audit: true in
+~/.config/chamber/config.yaml or .chamber.yaml to refuse running agents in seeds that
+haven't passed an audit since they were last changed.
+
+Example:
+  chamber seed audit
+  chamber seed audit xcode --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var name string
+
+			if len(args) != 0 {
+				name = args[0]
+			} else {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current directory: %w", err)
+				}
+
+				name, err = resolveSeed(cwd)
+				if err != nil {
+					return err
+				}
+			}
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			// Handle interrupts, runs that audit the seed handle them on their own
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigChan)
+			go func() {
+				select {
+				case <-sigChan:
+					fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up...")
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			return runSeedAudit(ctx, os.Stdout, name, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the audit report as JSON")
+
+	return cmd
+}
+
+func runSeedAudit(ctx context.Context, w io.Writer, name string, asJSON bool) error {
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := store.Load(name)
+	if err != nil {
+		if !errors.Is(err, seed.ErrNoMetadata) {
+			return err
+		}
+
+		metadata = &seed.Metadata{Name: name}
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("seed %q does not exist, see \"chamber seed list\"", name)
+	}
+
+	// Progress goes to stderr so that the JSON report can be piped
+	booted, err := seed.BootEphemeral(ctx, name, "admin", "admin", os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer booted.Shutdown()
+
+	fmt.Fprintf(os.Stderr, "Scanning %s for secrets...\n", name)
+
+	report, err := seed.Audit(booted.Client, time.Now())
+	if err != nil {
+		return booted.Failure(err)
+	}
+
+	booted.Shutdown()
+
+	metadata.Audit = report
+	if err := store.Save(metadata); err != nil {
+		return err
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprint(w, seed.FormatReport(report))
+	}
+
+	if !report.Passed {
+		return fmt.Errorf("seed %s failed the audit, remove the credentials from it and audit it again", name)
+	}
+
+	if !asJSON {
+		fmt.Fprintf(w, "Seed %s passed the audit\n", name)
+	}
+
+	return nil
+}
+
+// ensureAuditedSeed refuses seeds that haven't passed an audit since they were last changed
+func ensureAuditedSeed(name string) error {
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := store.Load(name)
+	if err != nil {
+		if errors.Is(err, seed.ErrNoMetadata) {
+			return fmt.Errorf("seed %s has not been audited, run \"chamber seed audit %s\" first", name, name)
+		}
+
+		return err
+	}
+
+	if err := metadata.AuditCurrent(); err != nil {
+		return fmt.Errorf("%w, run \"chamber seed audit %s\" first", err, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedBuildCmd() *cobra.Command {
+	var (
+		chamberfilePath string
+		name            string
+		noCache         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build a seed VM from a Chamberfile",
+		Long: `Build a seed VM by cloning the Chamberfile's base image and applying its steps over SSH in order.
+
+A Chamberfile looks like this:
+
+  base: ghcr.io/cirruslabs/macos-sequoia-base:latest
+  name: chamber-seed
+  steps:
+    - brew: [go, jq]
+    - npm: [typescript]
+    - copy:
+        src: dotfiles/.gitconfig
+        dst: ~/.gitconfig
+    - run: |
+        defaults write com.apple.dock autohide -bool true
+    - agent: claude
+
+The result of each step is cached as an intermediate Tart VM, keyed by the base image digest
+and all preceding steps, so a rebuild starts from the deepest unchanged layer. Use
+"chamber seed prune" to remove layers that are no longer used.
+
+The build output is also written to a log file in ~/.config/chamber/logs.
+
+Example:
+  chamber seed build
+  chamber seed build -f ios.Chamberfile --name ios-seed`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedBuild(cmd.Context(), chamberfilePath, name, noCache)
+		},
+	}
+
+	cmd.Flags().StringVarP(&chamberfilePath, "file", "f", "Chamberfile", "Path to the Chamberfile")
+	cmd.Flags().StringVar(&name, "name", "", "Name of the resulting seed (default: the Chamberfile's name or chamber-seed)")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "Apply all steps from scratch without using or creating cached layers")
+
+	return cmd
+}
+
+func runSeedBuild(ctx context.Context, chamberfilePath string, name string, noCache bool) error {
+	chamberfile, err := seed.LoadChamberfile(chamberfilePath)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = chamberfile.Name
+	}
+	if name == "" {
+		name = seed.DefaultName
+	}
+
+	registry, err := loadAgentRegistry()
+	if err != nil {
+		return err
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Handle interrupts
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up...")
+		cancel()
+	}()
+
+	logFile, err := openLogFile(fmt.Sprintf("seed-build-%s-%s.log", name, time.Now().Format("20060102-150405")))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	fmt.Fprintf(os.Stdout, "Building %s from %s, logging to %s\n", name, chamberfilePath, logFile.Name())
+
+	absChamberfilePath, err := filepath.Abs(chamberfilePath)
+	if err != nil {
+		return err
+	}
+
+	builder := seed.NewBuilder(chamberfile, filepath.Dir(absChamberfilePath), name, registry, store,
+		io.MultiWriter(os.Stdout, logFile))
+
+	if !noCache {
+		layers, err := loadLayerIndex()
+		if err != nil {
+			return err
+		}
+
+		builder.UseLayerCache(layers)
+	}
+
+	_, err = builder.Build(ctx)
+
+	return err
+}
+
+// openLogFile creates a log file in ~/.config/chamber/logs
+func openLogFile(name string) (*os.File, error) {
+	dir, err := config.Path("logs")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+
+	return file, nil
+}
+
+func loadLayerIndex() (*seed.LayerIndex, error) {
+	path, err := config.Path("layers.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return seed.LoadLayerIndex(path)
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newSeedCreateCmd() *cobra.Command {
+	var agentNames []string
+
+	cmd := &cobra.Command{
+		Use:   "create <name> <remote-vm>",
+		Short: "Create a named seed VM from a remote VM and set up coding agents",
+		Long: `Create a named seed VM the same way "chamber init" creates the default one.
+Pin it in a project's .chamber.yaml with "seed: <name>" or make it the default
+with "chamber seed default <name>".
+
+Example:
+  chamber seed create xcode ghcr.io/cirruslabs/macos-sequoia-xcode:latest
+  chamber seed create slim --agent claude,codex ghcr.io/cirruslabs/macos-sequoia-base:latest`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit(cmd.Context(), args[1], args[0], agentNames)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&agentNames, "agent", []string{"claude"},
+		"Agents to install and authenticate in the seed VM (comma-separated)")
+
+	return cmd
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedDefaultCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "default [name]",
+		Short: "Show or set the default seed VM",
+		Long: `Show or set the seed VM used when neither --vm nor the project's .chamber.yaml names one.
+
+Example:
+  chamber seed default
+  chamber seed default slim`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				name, err := userDefaultSeed()
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintln(os.Stdout, name)
+
+				return nil
+			}
+
+			return runSeedDefault(cmd.Context(), args[0])
+		},
+	}
+}
+
+func runSeedDefault(ctx context.Context, name string) error {
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("seed %q does not exist, see \"chamber seed list\"", name)
+	}
+
+	settings, err := config.LoadUserSettings()
+	if err != nil {
+		return err
+	}
+
+	settings.Seed = name
+	if err := config.SaveUserSettings(settings); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "%s is the default seed now\n", name)
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedInspectCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "inspect [name]",
+		Short: "Show the details of a seed VM",
+		Long: `Show how a seed VM was created and what it contains: the base image, creation date,
+installed agents and tool versions. Without a name, inspects the seed that would be
+used in the current directory.
+
+Example:
+  chamber seed inspect
+  chamber seed inspect xcode --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var name string
+
+			if len(args) != 0 {
+				name = args[0]
+			} else {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current directory: %w", err)
+				}
+
+				name, err = resolveSeed(cwd)
+				if err != nil {
+					return err
+				}
+			}
+
+			return runSeedInspect(cmd.Context(), os.Stdout, name, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the seed metadata as JSON")
+
+	return cmd
+}
+
+func runSeedInspect(ctx context.Context, w io.Writer, name string, asJSON bool) error {
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := store.Load(name)
+	if err != nil && !errors.Is(err, seed.ErrNoMetadata) {
+		return err
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	vms, err := tart.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var vm *tart.VMInfo
+	for i := range vms {
+		if vms[i].Source == "local" && vms[i].Name == name {
+			vm = &vms[i]
+		}
+	}
+
+	if vm == nil && metadata == nil {
+		return fmt.Errorf("seed %q does not exist, see \"chamber seed list\"", name)
+	}
+
+	if metadata == nil {
+		metadata = &seed.Metadata{Name: name}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(metadata)
+	}
+
+	return printSeedDetails(w, metadata, vm)
+}
+
+func printSeedDetails(w io.Writer, metadata *seed.Metadata, vm *tart.VMInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Name:\t%s\n", metadata.Name)
+
+	switch {
+	case vm == nil:
+		fmt.Fprintf(tw, "VM:\tmissing\n")
+	case vm.Running:
+		fmt.Fprintf(tw, "VM:\trunning, %d GB disk\n", vm.Disk)
+	default:
+		fmt.Fprintf(tw, "VM:\t%d GB disk\n", vm.Disk)
+	}
+
+	fmt.Fprintf(tw, "Base image:\t%s\n", valueOrDash(metadata.BaseImage))
+
+	if !metadata.CreatedAt.IsZero() {
+		fmt.Fprintf(tw, "Created:\t%s\n", metadata.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !metadata.UpdatedAt.IsZero() {
+		fmt.Fprintf(tw, "Updated:\t%s\n", metadata.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	if metadata.ChamberfileHash != "" {
+		fmt.Fprintf(tw, "Chamberfile:\t%s\n", metadata.ChamberfileHash)
+	}
+
+	fmt.Fprintf(tw, "Agents:\t%s\n", valueOrDash(agentList(metadata.Agents)))
+
+	if len(metadata.Tools) != 0 {
+		fmt.Fprintln(tw, "Tools:")
+
+		var names []string
+		for name := range metadata.Tools {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(tw, "  %s:\t%s\n", name, metadata.Tools[name])
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+func TestPrintSeedDetails(t *testing.T) {
+	metadata := &seed.Metadata{
+		Name:      "xcode",
+		BaseImage: "ghcr.io/cirruslabs/macos-sequoia-xcode:latest",
+		Agents: []seed.AgentInfo{
+			{Name: "claude", Version: "1.0.0"},
+			{Name: "codex"},
+		},
+		Tools: map[string]string{
+			"xcode": "Xcode 16.1",
+			"git":   "git version 2.39.5",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printSeedDetails(&buf, metadata, &tart.VMInfo{Name: "xcode", Disk: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Name:        xcode
+VM:          100 GB disk
+Base image:  ghcr.io/cirruslabs/macos-sequoia-xcode:latest
+Agents:      claude (1.0.0), codex
+Tools:
+  git:    git version 2.39.5
+  xcode:  Xcode 16.1
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := printSeedDetails(&buf, &seed.Metadata{Name: "old"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `Name:        old
+VM:          missing
+Base image:  -
+Agents:      -
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
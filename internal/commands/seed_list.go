@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List seed VMs",
+		Long: `List the seed VMs created by chamber along with their base images and agents.
+The default seed is marked with an asterisk.
+
+Example:
+  chamber seed list`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedList(cmd.Context(), os.Stdout)
+		},
+	}
+}
+
+func runSeedList(ctx context.Context, w io.Writer) error {
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	seeds, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	vms, err := tart.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	local := map[string]bool{}
+	for _, vm := range vms {
+		if vm.Source == "local" {
+			local[vm.Name] = true
+		}
+	}
+
+	// Seeds created by older chamber versions have no metadata
+	known := map[string]bool{}
+	for _, metadata := range seeds {
+		known[metadata.Name] = true
+	}
+	if !known[seed.DefaultName] && local[seed.DefaultName] {
+		seeds = append([]*seed.Metadata{{Name: seed.DefaultName}}, seeds...)
+	}
+
+	defaultSeed, err := userDefaultSeed()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tBASE IMAGE\tCREATED\tAGENTS")
+
+	for _, metadata := range seeds {
+		name := metadata.Name
+		if name == defaultSeed {
+			name += " *"
+		}
+		if !local[metadata.Name] {
+			name += " (missing)"
+		}
+
+		created := "-"
+		if !metadata.CreatedAt.IsZero() {
+			created = metadata.CreatedAt.Local().Format("2006-01-02 15:04")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, valueOrDash(metadata.BaseImage), created,
+			valueOrDash(agentList(metadata.Agents)))
+	}
+
+	return tw.Flush()
+}
+
+func agentList(agents []seed.AgentInfo) string {
+	var result []string
+
+	for _, info := range agents {
+		if info.Version != "" {
+			result = append(result, fmt.Sprintf("%s (%s)", info.Name, info.Version))
+		} else {
+			result = append(result, info.Name)
+		}
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
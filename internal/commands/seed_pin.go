@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+// ensurePinnedSeed verifies that the local seed matches the digest pinned in .chamber.yaml
+// and offers to pull the pinned image otherwise, so that all runs of a project use the same toolchain
+func ensurePinnedSeed(ctx context.Context, pin seed.Pin) error {
+	if !pin.Pinned() {
+		return nil
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := store.Load(pin.Name)
+	if err != nil && !errors.Is(err, seed.ErrNoMetadata) {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, pin.Name)
+	if err != nil {
+		return err
+	}
+
+	var verifyErr error
+	if exists {
+		verifyErr = verifyPinnedSeed(ctx, pin, metadata)
+	} else {
+		verifyErr = fmt.Errorf("%w: %s does not exist", seed.ErrDigestMismatch, pin.Name)
+	}
+	if verifyErr == nil {
+		return nil
+	}
+
+	ref, ok := pin.PullReference(metadata)
+	if !ok {
+		return fmt.Errorf("%w, and it's unknown which registry to pull it from, "+
+			"pin a full reference like ghcr.io/org/seed@%s in .chamber.yaml", verifyErr, pin.Digest)
+	}
+
+	hint := fmt.Sprintf("chamber seed pull %s --name %s", ref, pin.Name)
+	if exists {
+		hint = fmt.Sprintf("chamber seed rm %s && %s", pin.Name, hint)
+	}
+
+	if !canPrompt() {
+		return fmt.Errorf("%w, pull the pinned seed with \"%s\"", verifyErr, hint)
+	}
+
+	fmt.Fprintf(os.Stderr, "%v\n", verifyErr)
+	if !confirm(os.Stdin, os.Stderr, fmt.Sprintf("Pull %s to %s?", ref, pin.Name)) {
+		return verifyErr
+	}
+
+	if exists {
+		if err := tart.DeleteVM(ctx, pin.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := runSeedPull(ctx, ref, pin.Name, false); err != nil {
+		return err
+	}
+
+	metadata, err = store.Load(pin.Name)
+	if err != nil {
+		return err
+	}
+
+	return verifyPinnedSeed(ctx, pin, metadata)
+}
+
+// verifyPinnedSeed checks the local seed against the pin, including the fingerprint of its disk
+func verifyPinnedSeed(ctx context.Context, pin seed.Pin, metadata *seed.Metadata) error {
+	disk, err := tart.ResolveDigest(ctx, pin.Name)
+	if err != nil {
+		return fmt.Errorf("%w: %v", seed.ErrDigestMismatch, err)
+	}
+
+	return pin.Verify(metadata, disk)
+}
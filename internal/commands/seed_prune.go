@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedPruneCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove cached seed build layers",
+		Long: `Remove cached seed build layers that are not used by the latest build of any seed,
+as well as layer VMs that chamber no longer tracks.
+
+Example:
+  chamber seed prune
+  chamber seed prune --all`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedPrune(cmd.Context(), all)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Remove all cached layers, including the ones used by the latest builds")
+
+	return cmd
+}
+
+func runSeedPrune(ctx context.Context, all bool) error {
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	layers, err := loadLayerIndex()
+	if err != nil {
+		return err
+	}
+
+	stale := layers.Stale()
+	if all {
+		stale = nil
+		for _, layer := range layers.Layers {
+			stale = append(stale, layer)
+		}
+		layers.Builds = map[string][]string{}
+	}
+
+	vms, err := tart.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, vm := range vms {
+		if vm.Source == "local" && seed.IsLayerVM(vm.Name) {
+			existing[vm.Name] = true
+		}
+	}
+
+	var removed int
+
+	for _, layer := range stale {
+		if existing[layer.VMName()] {
+			fmt.Fprintf(os.Stdout, "Removing layer %s (%s)...\n", layer.VMName(), layer.Step)
+
+			if err := tart.DeleteVM(ctx, layer.VMName()); err != nil {
+				return err
+			}
+
+			removed++
+		}
+
+		layers.Remove(layer.Key)
+		delete(existing, layer.VMName())
+	}
+
+	// Layer VMs that are not in the index, e.g. left over from an interrupted build
+	tracked := map[string]bool{}
+	for _, layer := range layers.Layers {
+		tracked[layer.VMName()] = true
+	}
+
+	for name := range existing {
+		if tracked[name] {
+			continue
+		}
+
+		fmt.Fprintf(os.Stdout, "Removing untracked layer %s...\n", name)
+
+		if err := tart.DeleteVM(ctx, name); err != nil {
+			return err
+		}
+
+		removed++
+	}
+
+	if err := layers.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Removed %d layer(s)\n", removed)
+
+	return nil
+}
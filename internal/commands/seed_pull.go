@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/registry"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedPullCmd() *cobra.Command {
+	var (
+		name     string
+		insecure bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pull <registry-ref>",
+		Short: "Pull a seed VM pushed with \"chamber seed push\"",
+		Long: `Pull a seed VM from an OCI registry and restore the chamber metadata from its annotations.
+The seed is named after the last component of the repository unless --name is given.
+
+Example:
+  chamber seed pull ghcr.io/myteam/seed:latest
+  chamber seed pull ghcr.io/myteam/seed:latest --name team-seed`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedPull(cmd.Context(), args[0], name, insecure)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name of the local seed VM")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Connect to the registry over plain HTTP")
+
+	return cmd
+}
+
+func runSeedPull(ctx context.Context, ref string, name string, insecure bool) error {
+	parsedRef, err := registry.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = path.Base(parsedRef.Repository)
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("seed %q already exists, remove it first with \"chamber seed rm %s\" or use --name",
+			name, name)
+	}
+
+	metadata, source, err := fetchSeedMetadata(ctx, parsedRef, insecure)
+	if err != nil {
+		if !errors.Is(err, seed.ErrNoMetadata) {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s was not pushed by chamber, its agents are unknown\n", ref)
+	}
+	metadata.Name = name
+
+	fmt.Fprintf(os.Stdout, "Pulling %s to %s...\n", source, name)
+	if err := tart.PullVM(ctx, source, name, insecure); err != nil {
+		return err
+	}
+
+	// Tells whether the seed is changed later on, which a pin must notice
+	metadata.Disk, err = tart.ResolveDigest(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Save(metadata); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Seed %s is ready (agents: %s)\n", name, valueOrDash(agentList(metadata.Agents)))
+
+	return nil
+}
+
+// fetchSeedMetadata reads the chamber metadata from the image's annotations and returns it along
+// with the digest-pinned reference to pull, so that the pulled image matches the metadata.
+// For images not pushed by chamber, it returns basic metadata and an ErrNoMetadata-wrapped error.
+func fetchSeedMetadata(ctx context.Context, ref registry.Reference, insecure bool) (*seed.Metadata, string, error) {
+	annotations, digest, err := registry.New(insecure).Annotations(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	metadata, err := seed.MetadataFromAnnotations(annotations)
+	if err != nil && !errors.Is(err, seed.ErrNoMetadata) {
+		return nil, "", err
+	}
+	if metadata == nil {
+		metadata = &seed.Metadata{BaseImage: ref.String(), CreatedAt: time.Now().UTC()}
+	}
+
+	metadata.Source = ref.String()
+	metadata.Digest = digest
+
+	return metadata, ref.WithDigest(digest).String(), err
+}
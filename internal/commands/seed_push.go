@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cirruslabs/chamber/internal/registry"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedPushCmd() *cobra.Command {
+	var insecure bool
+
+	cmd := &cobra.Command{
+		Use:   "push <name> <registry-ref>",
+		Short: "Push a seed VM to an OCI registry to share it with the team",
+		Long: `Push a seed VM to an OCI registry with "tart push". The seed metadata, such as the
+installed agents and the Chamberfile hash, is stored in the image's annotations, so that
+"chamber seed pull" and "chamber init" know how the seed is set up.
+
+Registry credentials are taken from TART_REGISTRY_USERNAME and TART_REGISTRY_PASSWORD,
+~/.docker/config.json and its credential helpers, or "tart login".
+
+Example:
+  chamber seed push team-seed ghcr.io/myteam/seed:latest`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedPush(cmd.Context(), args[0], args[1], insecure)
+		},
+	}
+
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Connect to the registry over plain HTTP")
+
+	return cmd
+}
+
+func runSeedPush(ctx context.Context, name string, ref string, insecure bool) error {
+	parsedRef, err := registry.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := store.Load(name)
+	if err != nil {
+		if !errors.Is(err, seed.ErrNoMetadata) {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s has no chamber metadata, agents will be set up again on init\n", name)
+		metadata = &seed.Metadata{Name: name}
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("seed %q does not exist, see \"chamber seed list\"", name)
+	}
+
+	fmt.Fprintf(os.Stdout, "Pushing %s to %s...\n", name, ref)
+	if err := tart.PushVM(ctx, name, ref, insecure); err != nil {
+		return err
+	}
+
+	annotations, err := metadata.Annotations()
+	if err != nil {
+		return err
+	}
+
+	digest, err := registry.New(insecure).SetAnnotations(ctx, parsedRef, annotations)
+	if err != nil {
+		return fmt.Errorf("pushed the seed, but failed to annotate it with chamber metadata: %w", err)
+	}
+
+	metadata.Source = parsedRef.String()
+	metadata.Digest = digest
+	metadata.Disk, err = tart.ResolveDigest(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(metadata); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Pushed %s\n", parsedRef.WithDigest(digest))
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedRmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <name>...",
+		Aliases: []string{"remove"},
+		Short:   "Remove seed VMs and their metadata",
+		Long: `Remove seed VMs along with the metadata chamber keeps about them.
+Cached build layers used by the seeds can then be removed with "chamber seed prune".
+
+Example:
+  chamber seed rm xcode`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSeedRm(cmd.Context(), args)
+		},
+	}
+}
+
+func runSeedRm(ctx context.Context, names []string) error {
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return err
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	layers, err := loadLayerIndex()
+	if err != nil {
+		return err
+	}
+
+	settings, err := config.LoadUserSettings()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		exists, err := tart.Exists(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = store.Load(name)
+		hasMetadata := err == nil
+
+		if !exists && !hasMetadata {
+			return fmt.Errorf("seed %q does not exist", name)
+		}
+
+		if exists {
+			fmt.Fprintf(os.Stdout, "Removing %s...\n", name)
+
+			if err := tart.DeleteVM(ctx, name); err != nil {
+				return err
+			}
+		}
+
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+
+		layers.ForgetBuild(name)
+
+		if settings.Seed == name {
+			fmt.Fprintf(os.Stdout, "%s was the default seed, %s is the default now\n", name, seed.DefaultName)
+
+			settings.Seed = ""
+			if err := config.SaveUserSettings(settings); err != nil {
+				return err
+			}
+		}
+	}
+
+	return layers.Save()
+}
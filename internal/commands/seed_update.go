@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/seed"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	"github.com/spf13/cobra"
+)
+
+func newSeedUpdateCmd() *cobra.Command {
+	var agentNames []string
+
+	cmd := &cobra.Command{
+		Use:   "update [name]",
+		Short: "Upgrade the agents installed in a seed VM to their latest versions",
+		Long: `Boot the seed VM, upgrade each of its agents with the agent's update command
+and report the old and new versions. Without a name, updates the seed that would be
+used in the current directory.
+
+Set auto_update_days in ~/.config/chamber/config.yaml or .chamber.yaml to update
+the seed automatically before a run once it's older than that many days.
+
+Example:
+  chamber seed update
+  chamber seed update xcode --agent claude`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var name string
+
+			if len(args) != 0 {
+				name = args[0]
+			} else {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current directory: %w", err)
+				}
+
+				name, err = resolveSeed(cwd)
+				if err != nil {
+					return err
+				}
+			}
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			// Handle interrupts, runs that update the seed handle them on their own
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigChan)
+			go func() {
+				select {
+				case <-sigChan:
+					fmt.Fprintln(os.Stderr, "\nInterrupted, shutting down the seed...")
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			_, err := runSeedUpdate(ctx, name, agentNames)
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&agentNames, "agent", nil,
+		"Agents to update (comma-separated, default: all agents installed in the seed)")
+
+	return cmd
+}
+
+func runSeedUpdate(ctx context.Context, name string, agentNames []string) ([]seed.AgentUpdate, error) {
+	registry, err := loadAgentRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, err := tart.EnsureSupported(ctx); err != nil {
+		return nil, err
+	}
+
+	exists, err := tart.Exists(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("seed %q does not exist, see \"chamber seed list\"", name)
+	}
+
+	updates, err := seed.NewUpdater(name, registry, store, os.Stdout).Update(ctx, agentNames)
+
+	if len(updates) != 0 {
+		fmt.Fprintf(os.Stdout, "\nUpdated %s:\n", name)
+		for _, update := range updates {
+			fmt.Fprintf(os.Stdout, "  %s\n", update)
+		}
+	}
+
+	return updates, err
+}
+
+// autoUpdateSeed updates the seed before a run if it's older than the configured number of days
+// and reports whether that changed the seed. Failing to update, for example when offline,
+// doesn't prevent the run: the seed is used as is.
+func autoUpdateSeed(ctx context.Context, name string, days int, pin seed.Pin) (bool, error) {
+	if days <= 0 {
+		return false, nil
+	}
+
+	// Updating would make the seed diverge from the pinned image
+	if pin.Pinned() {
+		return false, nil
+	}
+
+	store, err := seed.DefaultStore()
+	if err != nil {
+		return false, err
+	}
+
+	metadata, err := store.Load(name)
+	if err != nil {
+		// Without metadata there's no telling how old the seed is
+		return false, nil
+	}
+
+	maxAge := time.Duration(days) * 24 * time.Hour
+	if age := metadata.Age(time.Now()); age < maxAge {
+		return false, nil
+	}
+
+	fmt.Fprintf(os.Stdout, "Seed %s hasn't been updated in over %d day(s), updating it first...\n", name, days)
+
+	updates, err := runSeedUpdate(ctx, name, nil)
+	if ctx.Err() != nil {
+		// Interrupted, rather than failed to update
+		return false, ctx.Err()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update seed %s, running with it as is: %v\n", name, err)
+	}
+
+	return !seed.Unchanged(updates), nil
+}
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cirruslabs/chamber/internal/history"
+	"github.com/cirruslabs/chamber/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+// keptSnapshots is the number of runs whose snapshots are kept for chamber undo
+const keptSnapshots = 20
+
+func NewUndoCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "undo [run-id]",
+		Short: "Revert the changes a run made to the working directory",
+		Long: `Restore the working directory to its state before a run, including the files the run deleted
+and the untracked and ignored files it changed, and remove the files it created. A git branch
+is only reset if it's still checked out. Without an ID, the most recent run in the current
+directory is undone. The snapshots of the last 20 runs are kept.
+
+If the directory has changed since the run finished, those changes are listed before
+asking for confirmation, as undoing the run discards them too.
+
+Example:
+  chamber undo
+  chamber undo 20250107-101502
+  chamber undo --dry-run`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var id string
+			if len(args) != 0 {
+				id = args[0]
+			}
+
+			return runUndo(cmd.Context(), os.Stdin, os.Stdout, id, force)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Don't ask for confirmation")
+
+	return cmd
+}
+
+func runUndo(ctx context.Context, in io.Reader, w io.Writer, id string, force bool) error {
+	runs, err := history.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	run, err := undoableRun(runs, id)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshot.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	if err := snapshots.Check(ctx, run.Before); err != nil {
+		return err
+	}
+
+	// Undoing the run would also discard what was changed after it
+	if run.After == nil {
+		fmt.Fprintf(w, "Warning: run %s didn't finish cleanly, so the changes made since can't be told apart from its own\n", run.ID)
+	} else {
+		edits, err := snapshots.Changes(ctx, run.After)
+		if err != nil {
+			return err
+		}
+
+		if len(edits) != 0 {
+			fmt.Fprintf(w, "Warning: %s has changed since run %s finished, undoing it discards these changes too:\n",
+				run.After.Dir, run.ID)
+			printPaths(w, edits)
+		}
+	}
+
+	changes, err := snapshots.Changes(ctx, run.Before)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "Nothing to undo, %s is as it was before run %s\n", run.Before.Dir, run.ID)
+
+		return nil
+	}
+
+	fmt.Fprintf(w, "Undoing run %s restores %d path(s) in %s:\n", run.ID, len(changes), run.Before.Dir)
+	printPaths(w, changes)
+
+	if dryRun {
+		return nil
+	}
+
+	if !force {
+		if !canPrompt() {
+			return fmt.Errorf("refusing to undo run %s without confirmation, use --force", run.ID)
+		}
+
+		if !confirm(in, w, "Restore these paths?") {
+			return nil
+		}
+	}
+
+	restored, err := snapshots.Restore(ctx, run.Before)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Restored %d path(s), run %s is undone\n", len(restored), run.ID)
+
+	return nil
+}
+
+// undoableRun returns the run with the given ID, or the most recent run in the current directory
+func undoableRun(runs *history.Store, id string) (*history.Run, error) {
+	if id != "" {
+		run, err := runs.Load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if run.Before == nil {
+			return nil, fmt.Errorf("run %s has no snapshot to restore", run.ID)
+		}
+
+		return run, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	cwd, err = filepath.Abs(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	all, err := runs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := history.Filter{Dir: cwd}
+
+	for _, run := range all {
+		if filter.Match(run) && run.Before != nil {
+			return run, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no run in %s can be undone", history.ErrNotFound, cwd)
+}
+
+// pruneSnapshots removes the snapshots of all but the keep most recent runs that have them
+func pruneSnapshots(ctx context.Context, runs *history.Store, snapshots *snapshot.Store, keep int) error {
+	all, err := runs.List()
+	if err != nil {
+		return err
+	}
+
+	var kept int
+
+	for _, run := range all {
+		if run.Before == nil && run.After == nil {
+			continue
+		}
+		if kept < keep {
+			kept++
+
+			continue
+		}
+
+		for _, taken := range []*snapshot.Snapshot{run.Before, run.After} {
+			if taken == nil {
+				continue
+			}
+
+			if err := snapshots.Remove(ctx, taken); err != nil {
+				return fmt.Errorf("failed to remove the snapshots of run %s: %w", run.ID, err)
+			}
+		}
+
+		run.Before, run.After = nil, nil
+		if err := runs.Save(run); err != nil {
+			return err
+		}
+	}
+
+	return snapshots.CollectGarbage()
+}
+
+// lastSnapshot returns the snapshot taken after the most recent run in dir, if it's still kept,
+// which spares the next snapshot of dir from hashing the files that haven't changed since
+func lastSnapshot(runs *history.Store, dir string) *snapshot.Snapshot {
+	all, err := runs.List()
+	if err != nil {
+		return nil
+	}
+
+	for _, run := range all {
+		if run.After == nil {
+			continue
+		}
+
+		// Git snapshots are of the whole working tree, dir may be below its root
+		if rel, err := filepath.Rel(run.After.Dir, dir); err == nil && filepath.IsLocal(rel) {
+			return run.After
+		}
+	}
+
+	return nil
+}
+
+func printPaths(w io.Writer, paths []string) {
+	for _, path := range paths {
+		fmt.Fprintf(w, "  %s\n", path)
+	}
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/history"
+	"github.com/cirruslabs/chamber/internal/snapshot"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+	runs := history.NewStore(t.TempDir())
+	snapshots := snapshot.NewStore(t.TempDir())
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 7, 10, 0, 0, 0, time.UTC)
+
+	var ids []string
+
+	for i := 0; i < 3; i++ {
+		run := history.NewRun(history.NewID(start.Add(time.Duration(i)*time.Minute)), start.Add(time.Duration(i)*time.Minute))
+
+		var err error
+
+		run.Before, err = snapshots.Take(ctx, dir, run.ID+"-before", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := runs.Save(run); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, run.ID)
+	}
+
+	if err := pruneSnapshots(ctx, runs, snapshots, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range ids {
+		run, err := runs.Load(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the oldest run loses its snapshot
+		if kept := run.Before != nil; kept != (i != 0) {
+			t.Errorf("run %d: expected the snapshot to be kept: %t, got %+v", i, i != 0, run.Before)
+		}
+	}
+}
+
+func TestLastSnapshot(t *testing.T) {
+	runs := history.NewStore(t.TempDir())
+	start := time.Date(2025, 1, 7, 10, 0, 0, 0, time.UTC)
+
+	for i, dir := range []string{"/src/project", "/src/other"} {
+		run := history.NewRun(history.NewID(start.Add(time.Duration(i)*time.Minute)), start.Add(time.Duration(i)*time.Minute))
+		run.After = &snapshot.Snapshot{Dir: dir, Manifest: run.ID + "-after"}
+
+		if err := runs.Save(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for dir, expected := range map[string]string{
+		"/src/project":     "/src/project",
+		"/src/project/cmd": "/src/project",
+		"/src/other":       "/src/other",
+		"/src":             "",
+	} {
+		var got string
+		if last := lastSnapshot(runs, dir); last != nil {
+			got = last.Dir
+		}
+
+		if got != expected {
+			t.Errorf("%s: expected the snapshot of %q, got %q", dir, expected, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/workspace"
+)
+
+// Workspace modes, i.e. how the VM gets the working directory
+const (
+	workspaceMount = "mount"
+	workspaceSync  = "sync"
+)
+
+var (
+	ErrExposedFiles     = errors.New("files excluded by " + workspace.IgnoreFile + " are visible in the VM")
+	ErrInvalidWorkspace = errors.New("invalid workspace mode")
+)
+
+// checkWorkspaceMode validates the --workspace flag
+func checkWorkspaceMode(opts runOptions) error {
+	switch opts.workspace {
+	case "", workspaceMount:
+		return nil
+	case workspaceSync:
+		if opts.useWorktree {
+			return fmt.Errorf("--workspace=%s and --worktree can't be used together", workspaceSync)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected %q or %q", ErrInvalidWorkspace, opts.workspace, workspaceMount, workspaceSync)
+	}
+}
+
+// syncsWorkspace reports whether the working directory is copied to the VM over SSH instead of mounted
+func syncsWorkspace(opts runOptions) bool {
+	return opts.workspace == workspaceSync
+}
+
+// planWorkspaceCopy loads the .chamberignore of the working directory and, if there's one or the working
+// directory is synced, returns where the copy of the working directory goes:
+// ~/.config/chamber/workspaces/<run-id>/<directory>
+func planWorkspaceCopy(opts runOptions, cwd string, runID string) (*workspace.Ignore, string, error) {
+	ignore, err := workspace.LoadIgnore(cwd)
+	if err != nil {
+		return nil, "", err
+	}
+	if ignore == nil && !syncsWorkspace(opts) {
+		return nil, "", nil
+	}
+
+	dir, err := config.Path("workspaces", runID, filepath.Base(runWorkDir(opts, cwd)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ignore, dir, nil
+}
+
+// runMountDir returns the host directory that's shared with the VM
+func runMountDir(opts runOptions, cwd string) string {
+	if opts.workspaceCopy != "" {
+		return opts.workspaceCopy
+	}
+
+	return runWorkDir(opts, cwd)
+}
+
+// copyIn copies the working directory to the VM
+func copyIn(exec *executor.Executor, staged *workspace.Copy) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(staged.WriteTar(writer))
+	}()
+
+	err := exec.CopyIn(reader)
+	// Unblocks the archive writer if the copy stopped early
+	reader.Close()
+
+	return err
+}
+
+// copyOut copies the working directory back out of the VM into the staged copy, the changes
+// are then synced back to the working directory like for a mounted copy, unless it fails. It uses a connection
+// of its own, since the run's connection is closed when the run is interrupted.
+func copyOut(addr string, opts runOptions, dirName string, staged *workspace.Copy) error {
+	sshClient, err := reconnect(addr, opts)
+	if err != nil {
+		return fmt.Errorf("failed to copy the working directory out of the VM: %w", err)
+	}
+	defer sshClient.Close()
+
+	exec := executor.New(sshClient, staged.Source, dirName)
+
+	reader, writer := io.Pipe()
+	copied := make(chan error, 1)
+	go func() {
+		err := exec.CopyOut(writer)
+		writer.CloseWithError(err)
+		copied <- err
+	}()
+
+	err = staged.ReadTar(reader)
+	if err == nil {
+		// tar pads the archive past its end marker
+		_, err = io.Copy(io.Discard, reader)
+	}
+	reader.CloseWithError(err)
+
+	if copyErr := <-copied; err == nil {
+		err = copyErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only a complete archive tells which files were removed in the VM
+	return staged.RemoveMissing()
+}
+
+// syncWorkspace brings the changes made in the VM back to the working directory, the copy
+// is only kept when some changes couldn't be brought back
+func syncWorkspace(w io.Writer, staged *workspace.Copy) error {
+	result, err := staged.SyncBack()
+	if err != nil {
+		return fmt.Errorf("failed to sync the changes back, they're still in %s: %w", staged.Dir, err)
+	}
+
+	if len(result.Changed) != 0 {
+		fmt.Fprintf(w, "Synced %d changed path(s) back to %s:\n", len(result.Changed), staged.Source)
+		printPaths(w, result.Changed)
+	}
+
+	if len(result.Rejected) != 0 {
+		fmt.Fprintf(w, "Ignored the changes to the paths the VM isn't allowed to change:\n")
+		printPaths(w, result.Rejected)
+	}
+
+	if len(result.Conflicts) != 0 {
+		fmt.Fprintf(w, "These paths were also changed in %s, kept them as they are there, the VM's version is in %s:\n",
+			staged.Source, staged.Dir)
+		printPaths(w, result.Conflicts)
+
+		return nil
+	}
+
+	return staged.Remove()
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/git"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+// gitDirMountName is the name the repository's .git directory is shared with the VM under
+// when running in a worktree: the worktree's .git file points to it by its host path,
+// which doesn't exist in the guest
+const gitDirMountName = "chamber-git"
+
+// planWorktree describes the worktree for the run, which is checked out
+// to ~/.config/chamber/worktrees/<run-id>/<repository> on the chamber/<run-id> branch
+func planWorktree(ctx context.Context, cwd string, runID string) (*git.Worktree, error) {
+	repo, err := git.TopLevel(ctx, cwd)
+	if err != nil {
+		return nil, fmt.Errorf("--worktree requires a git repository: %w", err)
+	}
+
+	worktreePath, err := config.Path("worktrees", runID, filepath.Base(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	return git.PlanWorktree(ctx, repo, worktreePath, git.BranchPrefix+runID)
+}
+
+// createWorktree checks out the worktree and points git in the guest to it. Git names the worktree's
+// private directory differently than planned when a kept worktree already has the name.
+func createWorktree(ctx context.Context, opts runOptions) error {
+	if err := opts.worktree.Create(ctx); err != nil {
+		return err
+	}
+
+	gitEnv, err := worktreeEnv(opts.worktree, opts.sshUser)
+	if err != nil {
+		return err
+	}
+	for name, value := range gitEnv {
+		opts.env[name] = value
+	}
+
+	return nil
+}
+
+// runWorkDir returns the host directory that's mounted as the command's working directory
+func runWorkDir(opts runOptions, cwd string) string {
+	if opts.worktree != nil {
+		return opts.worktree.Path
+	}
+
+	return cwd
+}
+
+// runMounts returns the directory mounts for the run
+func runMounts(opts runOptions, cwd string) []tart.DirectoryMount {
+	var mounts []tart.DirectoryMount
+
+	// A synced working directory is copied over SSH instead
+	if !syncsWorkspace(opts) {
+		mounts = workspaceMounts(runMountDir(opts, cwd))
+	}
+
+	if opts.worktree != nil {
+		mounts = append(mounts, tart.DirectoryMount{
+			Name: gitDirMountName,
+			Path: opts.worktree.CommonDir,
+		})
+	}
+
+	return mounts
+}
+
+// worktreeEnv points git in the guest to the worktree and the shared .git directory
+func worktreeEnv(worktree *git.Worktree, sshUser string) (map[string]string, error) {
+	gitDir, err := filepath.Rel(worktree.CommonDir, worktree.GitDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the worktree's git directory: %w", err)
+	}
+
+	workspace := path.Join("/Users", sshUser, "workspace")
+
+	return map[string]string{
+		"GIT_DIR":       path.Join(workspace, gitDirMountName, filepath.ToSlash(gitDir)),
+		"GIT_WORK_TREE": path.Join(workspace, filepath.Base(worktree.Path)),
+	}, nil
+}
+
+// finishWorktree summarizes what the agent did in the worktree and lets the user decide whether to keep it
+func finishWorktree(ctx context.Context, in io.Reader, w io.Writer, worktree *git.Worktree, interactive bool) error {
+	summary, err := worktree.Summarize(ctx)
+	if err != nil {
+		return err
+	}
+
+	if summary.Empty() {
+		fmt.Fprintf(w, "No changes were made in the worktree, removing it and branch %s\n", worktree.Branch)
+
+		return worktree.Remove(ctx)
+	}
+
+	printWorktreeSummary(w, worktree, summary)
+
+	if interactive && confirm(in, w, "Remove the worktree and the branch, discarding these changes?") {
+		return worktree.Remove(ctx)
+	}
+
+	fmt.Fprintf(w, "Kept the worktree at %s on branch %s, remove it with:\n  git worktree remove %s && git branch -D %s\n",
+		worktree.Path, worktree.Branch, worktree.Path, worktree.Branch)
+
+	return nil
+}
+
+func printWorktreeSummary(w io.Writer, worktree *git.Worktree, summary *git.Summary) {
+	fmt.Fprintf(w, "\nChanges on branch %s:\n", worktree.Branch)
+
+	if len(summary.Commits) != 0 {
+		fmt.Fprintf(w, "\n%d commit(s):\n", len(summary.Commits))
+		for _, commit := range summary.Commits {
+			fmt.Fprintf(w, "  %s\n", commit)
+		}
+	}
+
+	if summary.DiffStat != "" {
+		fmt.Fprintf(w, "\nDiff to %s:\n%s\n", shortCommit(worktree.Base), summary.DiffStat)
+	}
+
+	if len(summary.Untracked) != 0 {
+		fmt.Fprintln(w, "\nUntracked files:")
+		for _, file := range summary.Untracked {
+			fmt.Fprintf(w, "  %s\n", file)
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+
+	return commit
+}
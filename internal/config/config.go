@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Dir returns chamber's configuration directory, which is
+// $XDG_CONFIG_HOME/chamber or ~/.config/chamber by default
+func Dir() (string, error) {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "chamber"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "chamber"), nil
+}
+
+// Path returns a path inside chamber's configuration directory
+func Path(elem ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(append([]string{dir}, elem...)...), nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// WriteJSON writes value to path as indented JSON, creating the directory if needed.
+// The file is replaced atomically, so that an interrupted write doesn't leave a corrupted file behind.
+func WriteJSON(path string, value any) error {
+	contents, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// Hidden, so that listing the directory doesn't pick it up
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(append(contents, '\n')); err != nil {
+		_ = tmpFile.Close()
+
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runs")
+	path := filepath.Join(dir, "run.json")
+
+	for _, value := range []map[string]string{{"id": "first"}, {"id": "second"}} {
+		if err := WriteJSON(path, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "{\n  \"id\": \"second\"\n}\n" {
+		t.Errorf("expected the file to be replaced, got %q", contents)
+	}
+
+	// Nothing is left behind but the file itself
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only run.json, got %v", entries)
+	}
+
+	if err := WriteJSON(path, func() {}); err == nil {
+		t.Error("expected an error for a value that can't be encoded")
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cirruslabs/chamber/internal/inject"
+	"github.com/cirruslabs/chamber/internal/secret"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the name of the per-project configuration file,
+// which is looked up in the working directory and its parents
+const ProjectFileName = ".chamber.yaml"
+
+var (
+	ErrInvalidSettings   = errors.New("invalid chamber configuration")
+	ErrUntrustedSettings = errors.New("not allowed in " + ProjectFileName)
+)
+
+// Settings can be set per-user in ~/.config/chamber/config.yaml and
+// per-project in .chamber.yaml, with the project taking precedence
+type Settings struct {
+	// Seed is the name of the seed VM to clone ephemeral VMs from
+	Seed string `yaml:"seed,omitempty"`
+
+	// AutoUpdateDays makes chamber update the seed's agents before a run
+	// once the seed is older than this many days, 0 disables auto-updates
+	AutoUpdateDays int `yaml:"auto_update_days,omitempty"`
+
+	// RequireAudit refuses to run agents in seeds that haven't passed "chamber seed audit"
+	RequireAudit bool `yaml:"require_audit,omitempty"`
+
+	// Files are copied into the VM before each run, relative sources
+	// are resolved against the directory of the configuration file
+	Files []inject.File `yaml:"files,omitempty"`
+
+	// Env lists the host environment variables forwarded to the VM,
+	// either exact names or globs like AWS_*. It's only accepted
+	// in the per-user configuration.
+	Env []string `yaml:"env,omitempty"`
+
+	// Secrets are resolved on the host at run time and exposed to
+	// the command as environment variables or in-memory files.
+	// They're only accepted in the per-user configuration.
+	Secrets []secret.Secret `yaml:"secrets,omitempty"`
+
+	// Redact masks secret values and common token patterns in the command's output
+	Redact bool `yaml:"redact,omitempty"`
+
+	// DisableSnapshots skips snapshotting the working directory around runs,
+	// which then can't be undone
+	DisableSnapshots bool `yaml:"disable_snapshots,omitempty"`
+}
+
+// Merge overrides the settings with the ones that are set in other
+func (settings *Settings) Merge(other *Settings) {
+	if other.Seed != "" {
+		settings.Seed = other.Seed
+	}
+	if other.AutoUpdateDays != 0 {
+		settings.AutoUpdateDays = other.AutoUpdateDays
+	}
+	if other.RequireAudit {
+		settings.RequireAudit = true
+	}
+	if other.Redact {
+		settings.Redact = true
+	}
+	if other.DisableSnapshots {
+		settings.DisableSnapshots = true
+	}
+
+	// Files are additive, the project's files are written after and thus win over the user's
+	settings.Files = append(settings.Files, other.Files...)
+	settings.Env = append(settings.Env, other.Env...)
+	settings.Secrets = append(settings.Secrets, other.Secrets...)
+}
+
+// UserSettingsPath returns the path of the per-user configuration file
+func UserSettingsPath() (string, error) {
+	return Path("config.yaml")
+}
+
+// LoadUserSettings reads the per-user configuration, a missing file results in empty settings
+func LoadUserSettings() (*Settings, error) {
+	path, err := UserSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadSettings(path)
+}
+
+// SaveUserSettings writes the per-user configuration
+func SaveUserSettings(settings *Settings) error {
+	path, err := UserSettingsPath()
+	if err != nil {
+		return err
+	}
+
+	contents, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSettings reads the configuration file at path, a missing file results in empty settings
+func LoadSettings(path string) (*Settings, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Settings{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	var settings Settings
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	// An empty file is a valid configuration
+	if err := decoder.Decode(&settings); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSettings, path, err)
+	}
+
+	return &settings, nil
+}
+
+// FindProjectFile looks for .chamber.yaml in dir and its parents
+func FindProjectFile(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
+
+// Resolve returns the effective settings for the project in dir:
+// the per-user configuration overridden by the project's .chamber.yaml
+func Resolve(dir string) (*Settings, error) {
+	path, err := UserSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := loadResolvedSettings(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if path, ok := FindProjectFile(dir); ok {
+		project, err := loadResolvedSettings(path, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkProjectSettings(path, project); err != nil {
+			return nil, err
+		}
+
+		settings.Merge(project)
+	}
+
+	return settings, nil
+}
+
+// checkProjectSettings refuses the settings a project file can't be trusted with: it's in the working
+// directory, so the agent can write to it and mustn't be able to reach the host through the next run
+func checkProjectSettings(path string, settings *Settings) error {
+	// Resolving a secret runs commands and reads files on the host
+	if len(settings.Secrets) != 0 {
+		return fmt.Errorf("%w: %s declares secrets, which are only accepted in the per-user configuration",
+			ErrUntrustedSettings, path)
+	}
+
+	// Otherwise listing GITHUB_TOKEN, or "*", would hand the host's values to the next run
+	if len(settings.Env) != 0 {
+		return fmt.Errorf("%w: %s forwards host environment variables, which is only accepted "+
+			"in the per-user configuration", ErrUntrustedSettings, path)
+	}
+
+	return nil
+}
+
+// loadResolvedSettings reads the configuration file at path and resolves the files it declares
+// against the configuration file's directory, which a project's files must stay in
+func loadResolvedSettings(path string, project bool) (*Settings, error) {
+	settings, err := LoadSettings(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resolve := inject.Resolve
+	if project {
+		resolve = inject.ResolveProject
+	}
+
+	settings.Files, err = resolve(settings.Files, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cirruslabs/chamber/internal/secret"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	project := t.TempDir()
+	nested := filepath.Join(project, "ios", "App")
+	if err := os.MkdirAll(nested, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is configured
+	settings, err := Resolve(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Seed != "" {
+		t.Errorf("expected no seed, got %q", settings.Seed)
+	}
+
+	// The per-user default applies everywhere
+	if err := SaveUserSettings(&Settings{Seed: "slim", AutoUpdateDays: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = Resolve(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Seed != "slim" {
+		t.Errorf("expected the user's seed, got %q", settings.Seed)
+	}
+
+	// The project configuration is found in a parent directory and takes precedence
+	if err := os.WriteFile(filepath.Join(project, ProjectFileName), []byte("seed: xcode\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = Resolve(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Seed != "xcode" {
+		t.Errorf("expected the project's seed, got %q", settings.Seed)
+	}
+	if settings.AutoUpdateDays != 7 {
+		t.Errorf("settings the project doesn't set should be inherited, got %d", settings.AutoUpdateDays)
+	}
+}
+
+func TestResolveProjectSecrets(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	project := t.TempDir()
+	contents := "secrets:\n  - ref: secret://cmd/cat ~/.ssh/id_ed25519\n    env: KEY\n"
+	if err := os.WriteFile(filepath.Join(project, ProjectFileName), []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Resolve(project); !errors.Is(err, ErrUntrustedSettings) {
+		t.Errorf("expected ErrUntrustedSettings for a secret in the project file, got %v", err)
+	}
+
+	// The same secret is fine in the user's own configuration
+	if err := os.Remove(filepath.Join(project, ProjectFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveUserSettings(&Settings{Secrets: []secret.Secret{{Ref: "secret://cmd/cat token", Env: "KEY"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := Resolve(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.Secrets) != 1 {
+		t.Errorf("expected the user's secret, got %+v", settings.Secrets)
+	}
+}
+
+func TestResolveProjectEnv(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	project := t.TempDir()
+	path := filepath.Join(project, ProjectFileName)
+
+	for _, env := range []string{"GITHUB_TOKEN", `"*"`, "AWS_*"} {
+		if err := os.WriteFile(path, []byte("env: ["+env+"]\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Resolve(project); !errors.Is(err, ErrUntrustedSettings) {
+			t.Errorf("%s: expected ErrUntrustedSettings, got %v", env, err)
+		}
+	}
+}
+
+func TestLoadSettingsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ProjectFileName)
+
+	if err := os.WriteFile(path, []byte("sead: xcode\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadSettings(path); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("expected ErrInvalidSettings for an unknown field, got %v", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadSettings(path); err != nil {
+		t.Errorf("an empty file should be valid, got %v", err)
+	}
+}
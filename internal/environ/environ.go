@@ -0,0 +1,160 @@
+package environ
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidVariable = errors.New("invalid environment variable")
+	ErrMissingVariable = errors.New("environment variable is not set on the host")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Redacted replaces the values of forwarded variables wherever they would be shown
+const Redacted = "<redacted>"
+
+// LookupFunc looks up a variable in the host environment, e.g. os.LookupEnv
+type LookupFunc func(name string) (string, bool)
+
+// ParseAssignment parses a "-e" argument: either KEY=VALUE, or KEY to copy the variable from the host
+func ParseAssignment(arg string, lookup LookupFunc) (string, string, error) {
+	name, value, hasValue := strings.Cut(arg, "=")
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("%w: %q is not a valid name", ErrInvalidVariable, name)
+	}
+
+	if hasValue {
+		return name, value, nil
+	}
+
+	value, ok := lookup(name)
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrMissingVariable, name)
+	}
+
+	return name, value, nil
+}
+
+// ReadFile reads variables from a dotenv-style file: KEY=VALUE lines with optional
+// "export " prefixes, quoted values and # comments. A bare KEY copies the variable from the host.
+func ReadFile(filePath string, lookup LookupFunc) (map[string]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer file.Close()
+
+	result := map[string]string{}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, hasValue := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+
+		if hasValue {
+			value, err = unquote(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s:%d: %v", ErrInvalidVariable, filePath, lineNumber, err)
+			}
+
+			line = name + "=" + value
+		}
+
+		name, value, err = ParseAssignment(line, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filePath, lineNumber, err)
+		}
+
+		result[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	return result, nil
+}
+
+func unquote(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`):
+		return "", errors.New("unterminated quote")
+	}
+
+	// Unquoted values may carry a trailing comment
+	if before, _, ok := strings.Cut(value, " #"); ok {
+		value = strings.TrimSpace(before)
+	}
+
+	return value, nil
+}
+
+// Allowed collects the host variables whose names match any of the patterns,
+// which are either exact names or globs like AWS_*
+func Allowed(patterns []string, environ []string) (map[string]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: invalid pattern %q", ErrInvalidVariable, pattern)
+		}
+	}
+
+	result := map[string]string{}
+
+	for _, variable := range environ {
+		name, value, ok := strings.Cut(variable, "=")
+		if !ok {
+			continue
+		}
+
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				result[name] = value
+
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Names returns the sorted names of the variables, which is what's safe to log
+func Names(env map[string]string) []string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Redact returns a copy of env with all values replaced by Redacted
+func Redact(env map[string]string) map[string]string {
+	result := map[string]string{}
+	for name := range env {
+		result[name] = Redacted
+	}
+
+	return result
+}
@@ -0,0 +1,82 @@
+package environ
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env.chamber")
+
+	contents := `# Forwarded to the agent
+export MODEL=opus
+GREETING="hello\nworld"
+SINGLE='it''s raw $HOME'
+PLAIN=value # comment
+FROM_HOST
+
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := ReadFile(path, func(name string) (string, bool) {
+		return "host-" + name, name == "FROM_HOST"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"MODEL":     "opus",
+		"GREETING":  "hello\nworld",
+		"SINGLE":    "it''s raw $HOME",
+		"PLAIN":     "value",
+		"FROM_HOST": "host-FROM_HOST",
+		"EMPTY":     "",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("got %q, want %q", env, expected)
+	}
+
+	for _, invalid := range []string{"1KEY=value\n", "KEY=\"unterminated\n", "MISSING\n"} {
+		if err := os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadFile(path, os.LookupEnv); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	name, value, err := ParseAssignment("TOKEN=a=b", nil)
+	if err != nil || name != "TOKEN" || value != "a=b" {
+		t.Errorf("unexpected assignment %s=%s, %v", name, value, err)
+	}
+
+	if _, _, err := ParseAssignment("NOT SET", nil); !errors.Is(err, ErrInvalidVariable) {
+		t.Errorf("expected ErrInvalidVariable, got %v", err)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	env, err := Allowed([]string{"AWS_*", "GITHUB_TOKEN"},
+		[]string{"AWS_REGION=eu-west-1", "AWS_PROFILE=dev", "GITHUB_TOKEN=ghp", "HOME=/Users/fedor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := Names(env); !reflect.DeepEqual(names, []string{"AWS_PROFILE", "AWS_REGION", "GITHUB_TOKEN"}) {
+		t.Errorf("unexpected variables %v", names)
+	}
+
+	if _, err := Allowed([]string{"AWS_["}, nil); !errors.Is(err, ErrInvalidVariable) {
+		t.Errorf("expected ErrInvalidVariable for a malformed pattern, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type identifies a lifecycle event
+type Type string
+
+const (
+	VMCloned       Type = "vm.cloned"
+	VMBooted       Type = "vm.booted"
+	SSHConnected   Type = "ssh.connected"
+	MountReady     Type = "mount.ready"
+	CommandStarted Type = "command.started"
+	CommandExited  Type = "command.exited"
+	CleanupDone    Type = "cleanup.done"
+	Error          Type = "error"
+)
+
+// Phases reported alongside error events
+const (
+	PhaseClone     = "clone"
+	PhaseConfigure = "configure"
+	PhaseBoot      = "boot"
+	PhaseSSH       = "ssh"
+	PhaseMount     = "mount"
+	PhaseInject    = "inject"
+	PhaseCommand   = "command"
+	PhaseCleanup   = "cleanup"
+)
+
+const FormatJSONL = "jsonl"
+
+var ErrUnsupportedFormat = errors.New("unsupported events format")
+
+// Event is a single machine-readable lifecycle event
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	Phase    string    `json:"phase,omitempty"`
+	VM       string    `json:"vm,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Command  []string  `json:"command,omitempty"`
+	Env      []string  `json:"env,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Emitter writes events as JSON lines. A nil *Emitter is valid and discards all events,
+// so callers don't need to check whether the event stream was requested.
+type Emitter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	closer    io.Closer
+	now       func() time.Time
+	observers []func(Event)
+}
+
+// New creates an emitter writing JSON lines to w
+func New(w io.Writer) *Emitter {
+	return &Emitter{
+		w:   w,
+		now: time.Now,
+	}
+}
+
+// Open creates an emitter for the given format and output target.
+//
+// An empty format disables the event stream and returns a nil emitter. The target
+// can be "-" or empty for stderr, "fd:N" for an inherited file descriptor or a file path.
+func Open(format string, target string) (*Emitter, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case FormatJSONL:
+	default:
+		return nil, fmt.Errorf("%w: %q (only %q is supported)", ErrUnsupportedFormat, format, FormatJSONL)
+	}
+
+	switch {
+	case target == "" || target == "-":
+		return New(os.Stderr), nil
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid events file descriptor %q", target)
+		}
+
+		file := os.NewFile(uintptr(fd), target)
+		if file == nil {
+			return nil, fmt.Errorf("invalid events file descriptor %q", target)
+		}
+
+		emitter := New(file)
+		emitter.closer = file
+
+		return emitter, nil
+	default:
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open events file: %w", err)
+		}
+
+		emitter := New(file)
+		emitter.closer = file
+
+		return emitter, nil
+	}
+}
+
+// Observe passes every emitted event to fn as well. Since the event stream might be disabled,
+// it's valid to call it on a nil emitter, in which case a new emitter without an output is returned.
+func (e *Emitter) Observe(fn func(Event)) *Emitter {
+	if e == nil {
+		e = &Emitter{now: time.Now}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.observers = append(e.observers, fn)
+
+	return e
+}
+
+// Emit writes the event, filling in the timestamp if it's not set
+func (e *Emitter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = e.now().UTC()
+	}
+
+	for _, observer := range e.observers {
+		observer(event)
+	}
+
+	if e.w == nil {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	// The event stream is best-effort and should never break the run itself
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+// Fail emits an error event for the given phase and returns err unchanged
+func (e *Emitter) Fail(phase string, err error) error {
+	if err != nil {
+		e.Emit(Event{Type: Error, Phase: phase, Error: err.Error()})
+	}
+
+	return err
+}
+
+// Close releases the underlying output, if the emitter owns it
+func (e *Emitter) Close() error {
+	if e == nil || e.closer == nil {
+		return nil
+	}
+
+	return e.closer.Close()
+}
+
+// Int is a helper for populating optional integer fields
+func Int(value int) *int {
+	return &value
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitJSONL(t *testing.T) {
+	var buf bytes.Buffer
+
+	emitter := New(&buf)
+	emitter.now = func() time.Time {
+		return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+
+	emitter.Emit(Event{Type: VMBooted, VM: "chamber-ephemeral-1", IP: "192.168.64.2"})
+	emitter.Emit(Event{Type: CommandExited, ExitCode: Int(0)})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	expected := `{"type":"vm.booted","time":"2025-01-02T03:04:05Z","vm":"chamber-ephemeral-1","ip":"192.168.64.2"}`
+	if lines[0] != expected {
+		t.Errorf("got %s, want %s", lines[0], expected)
+	}
+
+	var exited Event
+	if err := json.Unmarshal([]byte(lines[1]), &exited); err != nil {
+		t.Fatal(err)
+	}
+	if exited.ExitCode == nil || *exited.ExitCode != 0 {
+		t.Errorf("expected exit code 0 to be present, got %v", exited.ExitCode)
+	}
+}
+
+func TestFail(t *testing.T) {
+	var buf bytes.Buffer
+
+	emitter := New(&buf)
+	failure := errors.New("boom")
+
+	if err := emitter.Fail(PhaseSSH, failure); !errors.Is(err, failure) {
+		t.Fatalf("Fail should return the original error, got %v", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != Error || event.Phase != PhaseSSH || event.Error != "boom" {
+		t.Errorf("unexpected error event: %+v", event)
+	}
+}
+
+func TestNilEmitter(t *testing.T) {
+	var emitter *Emitter
+
+	emitter.Emit(Event{Type: VMCloned})
+
+	if err := emitter.Fail(PhaseClone, errors.New("boom")); err == nil {
+		t.Fatal("Fail should return the original error on a nil emitter")
+	}
+	if err := emitter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	emitter, err := Open("", "-")
+	if err != nil || emitter != nil {
+		t.Fatalf("expected a disabled emitter, got %v, %v", emitter, err)
+	}
+
+	if _, err := Open("xml", "-"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+
+	if _, err := Open(FormatJSONL, "fd:nope"); err == nil {
+		t.Fatal("expected an error for an invalid file descriptor")
+	}
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	emitter, err = Open(FormatJSONL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emitter.Emit(Event{Type: CleanupDone})
+	if err := emitter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), `"type":"cleanup.done"`) {
+		t.Errorf("unexpected events file contents: %q", contents)
+	}
+}
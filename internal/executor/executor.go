@@ -2,10 +2,12 @@ package executor
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/cirruslabs/chamber/internal/ssh"
@@ -13,21 +15,44 @@ import (
 )
 
 type Executor struct {
-	sshClient      *gossh.Client
-	workingDir     string
+	sshClient  *gossh.Client
+	workingDir string
+	// mountedWorkDir is a shell word that expands to the working directory in the guest
 	mountedWorkDir string
 	dirName        string
+	env            map[string]string
+	stdout         io.Writer
+	stderr         io.Writer
+	recorder       ssh.Recorder
 }
 
 func New(sshClient *gossh.Client, workingDir string, dirName string) *Executor {
 	return &Executor{
 		sshClient:      sshClient,
 		workingDir:     workingDir,
-		mountedWorkDir: fmt.Sprintf("$HOME/workspace/%s", dirName),
+		mountedWorkDir: `"$HOME"/workspace/` + ShellQuote(dirName),
 		dirName:        dirName,
+		stdout:         os.Stdout,
+		stderr:         os.Stderr,
 	}
 }
 
+// SetEnv sets environment variables exported to the executed commands
+func (e *Executor) SetEnv(env map[string]string) {
+	e.env = env
+}
+
+// SetOutput overrides where the executed commands' output is written, e.g. to redact it
+func (e *Executor) SetOutput(stdout io.Writer, stderr io.Writer) {
+	e.stdout = stdout
+	e.stderr = stderr
+}
+
+// SetRecorder records the terminal size and input of interactive commands
+func (e *Executor) SetRecorder(recorder ssh.Recorder) {
+	e.recorder = recorder
+}
+
 func (e *Executor) MountWorkingDirectory(ctx context.Context) error {
 	session, err := e.sshClient.NewSession()
 	if err != nil {
@@ -35,6 +60,15 @@ func (e *Executor) MountWorkingDirectory(ctx context.Context) error {
 	}
 	defer session.Close()
 
+	if err := session.Run(e.MountCommand()); err != nil {
+		return fmt.Errorf("failed to mount working directory: %w", err)
+	}
+
+	return nil
+}
+
+// MountCommand returns the guest command that mounts the shared working directory
+func (e *Executor) MountCommand() string {
 	// Unmount any existing shared files and create workspace directory
 	// Then mount virtiofs with the automount tag
 	commands := []string{
@@ -43,13 +77,7 @@ func (e *Executor) MountWorkingDirectory(ctx context.Context) error {
 		`mount_virtiofs com.apple.virtio-fs.automount ~/workspace`,
 	}
 
-	command := strings.Join(commands, " && ")
-
-	if err := session.Run(command); err != nil {
-		return fmt.Errorf("failed to mount working directory: %w", err)
-	}
-
-	return nil
+	return strings.Join(commands, " && ")
 }
 
 func (e *Executor) UnmountWorkingDirectory(ctx context.Context) error {
@@ -59,14 +87,79 @@ func (e *Executor) UnmountWorkingDirectory(ctx context.Context) error {
 	}
 	defer session.Close()
 
-	command := fmt.Sprintf("umount %q", e.mountedWorkDir)
-
 	// Ignore errors on unmount as it might have been unmounted already
-	_ = session.Run(command)
+	_ = session.Run(e.UnmountCommand())
+
+	return nil
+}
+
+// UnmountCommand returns the guest command that unmounts the shared working directory
+func (e *Executor) UnmountCommand() string {
+	return "umount " + e.mountedWorkDir
+}
+
+// CopyIn extracts the tar archive of the working directory to where the command runs,
+// as an alternative to mounting it
+func (e *Executor) CopyIn(archive io.Reader) error {
+	if err := ssh.RunWithInput(e.sshClient, e.CopyInCommand(), archive); err != nil {
+		return fmt.Errorf("failed to copy the working directory to the VM: %w", err)
+	}
+
+	return nil
+}
+
+// CopyInCommand returns the guest command CopyIn runs
+func (e *Executor) CopyInCommand() string {
+	return fmt.Sprintf("mkdir -p %s && tar -xpf - -C %s", e.mountedWorkDir, e.mountedWorkDir)
+}
+
+// CopyOut writes the tar archive of the directory the command ran in
+func (e *Executor) CopyOut(archive io.Writer) error {
+	var stderr bytes.Buffer
+
+	if err := ssh.Run(e.sshClient, e.CopyOutCommand(), archive, &stderr); err != nil {
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			err = fmt.Errorf("%w: %s", err, output)
+		}
+
+		return fmt.Errorf("failed to copy the working directory out of the VM: %w", err)
+	}
 
 	return nil
 }
 
+// CopyOutCommand returns the guest command CopyOut runs
+func (e *Executor) CopyOutCommand() string {
+	return fmt.Sprintf("tar -cf - -C %s .", e.mountedWorkDir)
+}
+
+// VisiblePaths returns which of the paths, relative to the working directory, exist in the guest
+func (e *Executor) VisiblePaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	output, err := ssh.Output(e.sshClient, e.VisiblePathsCommand(paths))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the working directory: %w", err)
+	}
+
+	var visible []string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" {
+			visible = append(visible, line)
+		}
+	}
+
+	return visible, nil
+}
+
+// VisiblePathsCommand returns the guest command VisiblePaths runs
+func (e *Executor) VisiblePathsCommand(paths []string) string {
+	return fmt.Sprintf(`cd %s && for p in %s; do if [ -e "$p" ] || [ -L "$p" ]; then printf '%%s\n' "$p"; fi; done`,
+		e.mountedWorkDir, ShellJoin(paths))
+}
+
 func (e *Executor) Execute(ctx context.Context, command string, args []string) error {
 	session, err := e.sshClient.NewSession()
 	if err != nil {
@@ -91,23 +184,16 @@ func (e *Executor) Execute(ctx context.Context, command string, args []string) e
 	}
 
 	// Start output readers
-	go e.streamOutput(stdout, os.Stdout)
-	go e.streamOutput(stderr, os.Stderr)
+	go e.streamOutput(stdout, e.stdout)
+	go e.streamOutput(stderr, e.stderr)
 
 	// Start a shell
 	if err := session.Shell(); err != nil {
 		return fmt.Errorf("failed to start shell: %w", err)
 	}
 
-	// Change to mounted working directory
-	_, err = stdin.Write([]byte(fmt.Sprintf("cd %s\n", e.mountedWorkDir)))
-	if err != nil {
-		return fmt.Errorf("failed to change directory: %w", err)
-	}
-
-	// Execute the command
-	fullCommand := fmt.Sprintf("%s %s", command, strings.Join(args, " "))
-	_, err = stdin.Write([]byte(fullCommand + "\nexit $?\n"))
+	// Change to mounted working directory and execute the command
+	_, err = stdin.Write([]byte(e.ShellScript(command, args)))
 	if err != nil {
 		return fmt.Errorf("failed to execute command: %w", err)
 	}
@@ -129,8 +215,8 @@ func (e *Executor) Execute(ctx context.Context, command string, args []string) e
 		}
 		// Check if it's an exit error, which means the command ran but returned non-zero
 		if exitErr, ok := err.(*gossh.ExitError); ok {
-			// Return a more descriptive error
-			return fmt.Errorf("command exited with status %d", exitErr.ExitStatus())
+			// Return a more descriptive error, but keep the exit details accessible to callers
+			return fmt.Errorf("command exited with status %d: %w", exitErr.ExitStatus(), exitErr)
 		}
 		return fmt.Errorf("failed to run command: %w", err)
 	}
@@ -138,18 +224,58 @@ func (e *Executor) Execute(ctx context.Context, command string, args []string) e
 	return nil
 }
 
+// ShellScript returns the script Execute feeds to the guest shell
+func (e *Executor) ShellScript(command string, args []string) string {
+	var lines []string
+
+	lines = append(lines, e.exports()...)
+	lines = append(lines, "cd "+e.mountedWorkDir)
+	lines = append(lines, ShellJoin(append([]string{command}, args...)))
+	lines = append(lines, "exit $?")
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
 // ExecuteInteractive executes a command with full terminal proxying
 func (e *Executor) ExecuteInteractive(ctx context.Context, command string, args []string) error {
 	// Create terminal proxy
 	terminal := ssh.NewTerminal(e.sshClient)
+	terminal.SetOutput(e.stdout, e.stderr)
+	if e.recorder != nil {
+		terminal.SetRecorder(e.recorder)
+	}
+
+	// Execute with full terminal proxying
+	return terminal.RunInteractiveCommand(ctx, e.InteractiveCommand(command, args))
+}
 
+// InteractiveCommand returns the guest command line ExecuteInteractive runs
+func (e *Executor) InteractiveCommand(command string, args []string) string {
 	// Build the full command with working directory change and login shell
 	// Use zsh -l -c to ensure the user's profile is loaded (similar to init.go)
-	innerCommand := fmt.Sprintf("cd %s && %s %s", e.mountedWorkDir, command, strings.Join(args, " "))
-	fullCommand := fmt.Sprintf("zsh -l -c %q", innerCommand)
+	var steps []string
 
-	// Execute with full terminal proxying
-	return terminal.RunInteractiveCommand(ctx, fullCommand)
+	steps = append(steps, e.exports()...)
+	steps = append(steps, "cd "+e.mountedWorkDir)
+	steps = append(steps, ShellJoin(append([]string{command}, args...)))
+
+	return LoginShell(strings.Join(steps, " && "))
+}
+
+// exports returns the shell statements that export the configured environment variables
+func (e *Executor) exports() []string {
+	var names []string
+	for name := range e.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []string
+	for _, name := range names {
+		result = append(result, fmt.Sprintf("export %s=%s", name, ShellQuote(e.env[name])))
+	}
+
+	return result
 }
 
 func (e *Executor) streamOutput(reader io.Reader, writer io.Writer) {
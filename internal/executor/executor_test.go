@@ -0,0 +1,35 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellScriptQuotesWorkDir(t *testing.T) {
+	home := t.TempDir()
+	dirName := `it's $(touch pwned) "quoted"`
+
+	workDir := filepath.Join(home, "workspace", dirName)
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "-c", New(nil, "/host", dirName).ShellScript("pwd", nil))
+	cmd.Dir = home
+	cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dir := strings.TrimSpace(string(output)); dir != workDir {
+		t.Errorf("expected the command to run in %q, got %q", workDir, dir)
+	}
+	if _, err := os.Stat(filepath.Join(home, "pwned")); !os.IsNotExist(err) {
+		t.Error("expected the directory name not to be evaluated")
+	}
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"regexp"
+	"strings"
+)
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=,@%+-]+$`)
+
+// ShellQuote quotes s so that a POSIX shell treats it as a single literal word
+func ShellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellJoin renders an argv in a form that can be passed to a POSIX shell
+func ShellJoin(argv []string) string {
+	quoted := make([]string, 0, len(argv))
+
+	for _, arg := range argv {
+		quoted = append(quoted, ShellQuote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// LoginShell wraps a shell command so that it runs in the guest user's login shell,
+// which makes tools installed via Homebrew, npm and similar available on PATH
+func LoginShell(command string) string {
+	return "zsh -l -c " + ShellQuote(command)
+}
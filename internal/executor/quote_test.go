@@ -0,0 +1,24 @@
+package executor
+
+import (
+	"testing"
+)
+
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		argv     []string
+		expected string
+	}{
+		{argv: []string{"tart", "run", "vm"}, expected: "tart run vm"},
+		{argv: []string{"echo", "hello world"}, expected: "echo 'hello world'"},
+		{argv: []string{"echo", "it's"}, expected: `echo 'it'\''s'`},
+		{argv: []string{"echo", "$HOME"}, expected: `echo '$HOME'`},
+		{argv: []string{"echo", ""}, expected: `echo ''`},
+	}
+
+	for _, tt := range tests {
+		if result := ShellJoin(tt.argv); result != tt.expected {
+			t.Errorf("ShellJoin(%q) = %s, want %s", tt.argv, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BranchPrefix namespaces the branches chamber creates for runs
+const BranchPrefix = "chamber/"
+
+var ErrNotRepository = errors.New("not a git repository")
+
+// Run runs git in dir and returns its trimmed standard output
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	return run(ctx, dir, nil, args...)
+}
+
+// run runs git in dir with the extra environment variables
+func run(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	if len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// TopLevel returns the root of the working tree dir belongs to
+func TopLevel(ctx context.Context, dir string) (string, error) {
+	topLevel, err := Run(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotRepository, dir)
+	}
+
+	return topLevel, nil
+}
+
+// Worktree is a git worktree created for a single run, so that the agent
+// doesn't collide with the changes in progress in the main working tree
+type Worktree struct {
+	// Repo is the root of the main working tree
+	Repo string
+
+	// Path is where the worktree is checked out
+	Path string
+
+	// Branch is the branch the worktree is on
+	Branch string
+
+	// Base is the commit the branch starts at
+	Base string
+
+	// CommonDir is the repository's .git directory shared by all worktrees
+	CommonDir string
+
+	// GitDir is the worktree's private directory inside CommonDir
+	GitDir string
+}
+
+// PlanWorktree describes a worktree of the repository dir belongs to, checked out
+// to path on a new branch starting at the current HEAD, without creating it yet
+func PlanWorktree(ctx context.Context, dir string, path string, branch string) (*Worktree, error) {
+	repo, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := Run(ctx, repo, "rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("%s has no commits to create a worktree from: %w", repo, err)
+	}
+
+	commonDir, err := Run(ctx, repo, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(repo, commonDir)
+	}
+
+	return &Worktree{
+		Repo:      repo,
+		Path:      path,
+		Branch:    branch,
+		Base:      base,
+		CommonDir: commonDir,
+		GitDir:    filepath.Join(commonDir, "worktrees", filepath.Base(path)),
+	}, nil
+}
+
+// AddArgs returns the git arguments that create the worktree
+func (worktree *Worktree) AddArgs() []string {
+	return []string{"-C", worktree.Repo, "worktree", "add", "-b", worktree.Branch, worktree.Path, worktree.Base}
+}
+
+// Create checks out the worktree
+func (worktree *Worktree) Create(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(worktree.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+
+	if _, err := Run(ctx, worktree.Repo, worktree.AddArgs()[2:]...); err != nil {
+		return err
+	}
+
+	// Git picks another name for the private directory when the planned one is taken
+	gitDir, err := Run(ctx, worktree.Path, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return err
+	}
+
+	worktree.GitDir = gitDir
+
+	return nil
+}
+
+// Summary describes what happened in a worktree since it was created
+type Summary struct {
+	// Commits are the one-line descriptions of the new commits, the most recent first
+	Commits []string
+
+	// DiffStat summarizes the changes to the base, including uncommitted ones
+	DiffStat string
+
+	// Untracked are the new files that haven't been added
+	Untracked []string
+}
+
+// Empty reports whether nothing has changed
+func (summary *Summary) Empty() bool {
+	return len(summary.Commits) == 0 && summary.DiffStat == "" && len(summary.Untracked) == 0
+}
+
+// Summarize collects the commits and changes made in the worktree
+func (worktree *Worktree) Summarize(ctx context.Context) (*Summary, error) {
+	summary := &Summary{}
+
+	commits, err := Run(ctx, worktree.Path, "log", "--oneline", worktree.Base+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	summary.Commits = lines(commits)
+
+	summary.DiffStat, err = Run(ctx, worktree.Path, "diff", "--stat", worktree.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := Run(ctx, worktree.Path, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	summary.Untracked = lines(untracked)
+
+	return summary, nil
+}
+
+// Remove deletes the worktree and its branch
+func (worktree *Worktree) Remove(ctx context.Context) error {
+	if _, err := Run(ctx, worktree.Repo, "worktree", "remove", "--force", worktree.Path); err != nil {
+		return err
+	}
+
+	if _, err := Run(ctx, worktree.Repo, "branch", "-D", worktree.Branch); err != nil {
+		return err
+	}
+
+	// Clean up the per-run directory the worktree was created in
+	_ = os.Remove(filepath.Dir(worktree.Path))
+
+	return nil
+}
+
+func lines(output string) []string {
+	if output == "" {
+		return nil
+	}
+
+	return strings.Split(output, "\n")
+}
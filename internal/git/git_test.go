@@ -0,0 +1,194 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a repository with a single commit
+func initRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		if _, err := Run(context.Background(), repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Initial commit"}} {
+		if _, err := Run(context.Background(), repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return repo
+}
+
+func TestWorktree(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	worktree, err := PlanWorktree(ctx, repo, filepath.Join(t.TempDir(), "run", "repo"), BranchPrefix+"run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worktree.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := worktree.Summarize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.Empty() {
+		t.Fatalf("expected a fresh worktree to have no changes, got %+v", summary)
+	}
+
+	// The main working tree isn't affected by the changes in the worktree
+	if err := os.WriteFile(filepath.Join(worktree.Path, "README.md"), []byte("changed\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(ctx, worktree.Path, "commit", "-q", "-am", "Change README"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree.Path, "new.txt"), []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err = worktree.Summarize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Commits) != 1 || summary.DiffStat == "" {
+		t.Errorf("expected one commit and a diff, got %+v", summary)
+	}
+	if len(summary.Untracked) != 1 || summary.Untracked[0] != "new.txt" {
+		t.Errorf("expected new.txt to be untracked, got %v", summary.Untracked)
+	}
+
+	if contents, err := os.ReadFile(filepath.Join(repo, "README.md")); err != nil || string(contents) != "hello\n" {
+		t.Errorf("expected the main working tree to be unchanged, got %q (%v)", contents, err)
+	}
+
+	if err := worktree.Remove(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(worktree.Path); !os.IsNotExist(err) {
+		t.Errorf("expected the worktree to be removed, got %v", err)
+	}
+	if _, err := Run(ctx, repo, "rev-parse", "--verify", worktree.Branch); err == nil {
+		t.Errorf("expected the branch %s to be deleted", worktree.Branch)
+	}
+}
+
+func TestPlanWorktreeOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	_, err := PlanWorktree(context.Background(), t.TempDir(), t.TempDir(), BranchPrefix+"run")
+	if err == nil {
+		t.Fatal("expected an error outside of a repository")
+	}
+}
+
+func TestFormatPatch(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	// Changes made before the snapshot aren't part of the patch
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("ours\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := TakeSnapshot(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.Dirty() {
+		t.Error("expected the snapshot to be dirty")
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "agent.txt"), []byte("theirs\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := WriteTree(ctx, repo, snapshot.Head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patchPath := filepath.Join(t.TempDir(), "out.patch")
+
+	written, err := FormatPatch(ctx, snapshot, tree, "Add agent.txt", patchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !written {
+		t.Fatal("expected a patch to be written")
+	}
+
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(patch), "Subject: [PATCH] Add agent.txt") || !strings.Contains(string(patch), "+theirs") {
+		t.Errorf("expected the patch to contain the new file, got:\n%s", patch)
+	}
+	if strings.Contains(string(patch), "README.md") {
+		t.Errorf("expected the patch to leave out the changes made before the snapshot, got:\n%s", patch)
+	}
+
+	// Neither the index nor the branch were touched
+	if status, err := Run(ctx, repo, "status", "--porcelain"); err != nil || status != " M README.md\n?? agent.txt" {
+		t.Errorf("unexpected status %q (%v)", status, err)
+	}
+	if head, err := Run(ctx, repo, "rev-parse", "HEAD"); err != nil || head != snapshot.Head {
+		t.Errorf("expected HEAD to stay at %s, got %s (%v)", snapshot.Head, head, err)
+	}
+
+	if written, err := FormatPatch(ctx, snapshot, snapshot.Tree, "Nothing", patchPath); err != nil || written {
+		t.Errorf("expected no patch without changes, got %v (%v)", written, err)
+	}
+}
+
+func TestCommitAll(t *testing.T) {
+	ctx := context.Background()
+	repo := initRepo(t)
+
+	if commit, err := CommitAll(ctx, repo, "Nothing"); err != nil || commit != "" {
+		t.Fatalf("expected nothing to commit, got %q (%v)", commit, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "agent.txt"), []byte("theirs\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := CommitAll(ctx, repo, "Add agent.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, err := Run(ctx, repo, "log", "-1", "--format=%s", commit); err != nil || subject != "Add agent.txt" {
+		t.Errorf("unexpected commit subject %q (%v)", subject, err)
+	}
+}
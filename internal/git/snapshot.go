@@ -0,0 +1,249 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot is the state of a working tree at some point, stored as a git tree object
+// so that it can be compared to a later state without touching the index or any branch
+type Snapshot struct {
+	// Dir is the root of the working tree
+	Dir string
+
+	// Head is the commit checked out when the snapshot was taken
+	Head string
+
+	// Tree is the tree object with the contents of the working tree, untracked files included
+	// and ignored files excluded
+	Tree string
+
+	// HeadTree is the tree of Head, it differs from Tree when there were uncommitted changes
+	HeadTree string
+
+	// Branch is the full name of the branch checked out, or HEAD when it was detached
+	Branch string
+}
+
+// TakeSnapshot records the contents of the working tree dir belongs to
+func TakeSnapshot(ctx context.Context, dir string) (*Snapshot, error) {
+	topLevel, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := Run(ctx, topLevel, "rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("%s has no commits yet: %w", topLevel, err)
+	}
+
+	headTree, err := Run(ctx, topLevel, "rev-parse", head+"^{tree}")
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := CurrentBranch(ctx, topLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := WriteTree(ctx, topLevel, head)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Dir:      topLevel,
+		Head:     head,
+		Tree:     tree,
+		HeadTree: headTree,
+		Branch:   branch,
+	}, nil
+}
+
+// CurrentBranch returns the full name of the branch checked out in dir, or HEAD when it's detached
+func CurrentBranch(ctx context.Context, dir string) (string, error) {
+	return Run(ctx, dir, "rev-parse", "--symbolic-full-name", "HEAD")
+}
+
+// IgnoredFiles returns the slash-separated paths of the files in the working tree
+// that git ignores, relative to dir. Nested repositories are left out.
+func IgnoredFiles(ctx context.Context, dir string) ([]string, error) {
+	output, err := Run(ctx, dir, "ls-files", "--others", "--ignored", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+
+	for _, path := range strings.Split(output, "\x00") {
+		// Nested repositories are listed as directories
+		if path != "" && !strings.HasSuffix(path, "/") {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// Dirty reports whether there were uncommitted changes when the snapshot was taken
+func (snapshot *Snapshot) Dirty() bool {
+	return snapshot.Tree != snapshot.HeadTree
+}
+
+// WriteTree stores the contents of the working tree as a tree object and returns its ID.
+// A temporary index based on the given commit is used, so the real one stays as is.
+func WriteTree(ctx context.Context, dir string, base string) (string, error) {
+	var tree string
+
+	err := withTemporaryIndex(func(env []string) error {
+		for _, args := range [][]string{
+			{"read-tree", base},
+			{"add", "--all", "--", "."},
+		} {
+			if _, err := run(ctx, dir, env, args...); err != nil {
+				return err
+			}
+		}
+
+		var err error
+
+		tree, err = run(ctx, dir, env, "write-tree")
+
+		return err
+	})
+
+	return tree, err
+}
+
+// CheckoutTree makes the working tree match the tree object to, given that it
+// currently matches from: changed and deleted files are restored and new files
+// are removed, but ignored files, the index and HEAD are left as is
+func CheckoutTree(ctx context.Context, dir string, from string, to string) error {
+	return withTemporaryIndex(func(env []string) error {
+		if _, err := run(ctx, dir, env, "read-tree", from); err != nil {
+			return err
+		}
+
+		_, err := run(ctx, dir, env, "read-tree", "--reset", "-u", to)
+
+		return err
+	})
+}
+
+// DiffTrees returns the paths that differ between two trees
+func DiffTrees(ctx context.Context, dir string, from string, to string) ([]string, error) {
+	output, err := Run(ctx, dir, "diff", "--name-only", "--no-renames", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return lines(output), nil
+}
+
+// Keep stores the snapshot in a commit referenced by ref, so that
+// its tree isn't garbage collected, and returns the commit
+func (snapshot *Snapshot) Keep(ctx context.Context, ref string) (string, error) {
+	// The commit is only there to hold the tree, so it doesn't need the user's identity
+	env := []string{
+		"GIT_AUTHOR_NAME=chamber", "GIT_AUTHOR_EMAIL=chamber@localhost",
+		"GIT_COMMITTER_NAME=chamber", "GIT_COMMITTER_EMAIL=chamber@localhost",
+	}
+
+	commit, err := run(ctx, snapshot.Dir, env, "commit-tree", snapshot.Tree, "-p", snapshot.Head,
+		"-m", "chamber snapshot")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := Run(ctx, snapshot.Dir, "update-ref", ref, commit); err != nil {
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// DeleteRef removes a ref created by Keep, letting git garbage collect the snapshot
+func DeleteRef(ctx context.Context, dir string, ref string) error {
+	_, err := Run(ctx, dir, "update-ref", "-d", ref)
+
+	return err
+}
+
+// withTemporaryIndex runs fn with the environment that points git to a new, empty index
+func withTemporaryIndex(fn func(env []string) error) error {
+	dir, err := os.MkdirTemp("", "chamber-index-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary index: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Git refuses to read an empty index file, but creates a missing one just fine
+	return fn([]string{"GIT_INDEX_FILE=" + filepath.Join(dir, "index")})
+}
+
+// CommitAll stages all the changes in the working tree dir belongs to and commits them,
+// returning the new commit or an empty string if there was nothing to commit
+func CommitAll(ctx context.Context, dir string, message string) (string, error) {
+	if _, err := Run(ctx, dir, "add", "--all", "--", ":/"); err != nil {
+		return "", err
+	}
+
+	// Nothing staged, e.g. the agent has already committed everything itself
+	if _, err := Run(ctx, dir, "diff", "--cached", "--quiet"); err == nil {
+		return "", nil
+	}
+
+	if _, err := Run(ctx, dir, "commit", "--quiet", "--no-verify", "--message", message); err != nil {
+		return "", err
+	}
+
+	return Run(ctx, dir, "rev-parse", "HEAD")
+}
+
+// FormatPatch writes the changes from the snapshot to the given tree as a single patch
+// in the git format-patch format, which can be applied with git am. It returns false
+// and writes nothing if there are no changes.
+func FormatPatch(ctx context.Context, from *Snapshot, to string, message string, path string) (bool, error) {
+	if from.Tree == to {
+		return false, nil
+	}
+
+	// The changes that were already there get a commit of their own, so that
+	// the patch only contains what has changed since the snapshot
+	parent := from.Head
+	if from.Dirty() {
+		var err error
+
+		parent, err = Run(ctx, from.Dir, "commit-tree", from.Tree, "-p", from.Head,
+			"-m", "Uncommitted changes")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	commit, err := Run(ctx, from.Dir, "commit-tree", to, "-p", parent, "-m", message)
+	if err != nil {
+		return false, err
+	}
+
+	patch, err := Run(ctx, from.Dir, "format-patch", "--stdout", "-1", commit)
+	if err != nil {
+		return false, err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return false, fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(patch+"\n"), 0o644); err != nil {
+		return false, fmt.Errorf("failed to write patch: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,324 @@
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/events"
+	"github.com/cirruslabs/chamber/internal/snapshot"
+)
+
+var (
+	ErrNotFound  = errors.New("run not found")
+	ErrAmbiguous = errors.New("ambiguous run ID")
+)
+
+// Run is the record of a single chamber run
+type Run struct {
+	ID         string    `json:"id"`
+	Dir        string    `json:"dir"`
+	Seed       string    `json:"seed"`
+	VM         string    `json:"vm,omitempty"`
+	Agent      string    `json:"agent,omitempty"`
+	Command    []string  `json:"command"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Phases     []Phase   `json:"phases,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+
+	// LogPath is the log of the run's lifecycle events
+	LogPath string `json:"log_path,omitempty"`
+
+	// RecordingPath is the session recording, if the run was recorded
+	RecordingPath string `json:"recording_path,omitempty"`
+
+	// Worktree and Branch are where the run's changes went when it ran in a git worktree
+	Worktree string `json:"worktree,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+
+	// Before and After are the snapshots of the working directory taken around the run,
+	// which "chamber undo" restores from
+	Before *snapshot.Snapshot `json:"before,omitempty"`
+	After  *snapshot.Snapshot `json:"after,omitempty"`
+
+	mtx          sync.Mutex
+	lastBoundary time.Time
+}
+
+// Phase is a step of a run and how long it took
+type Phase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// phaseEnds maps the events to the phases they complete
+var phaseEnds = map[events.Type]string{
+	events.VMCloned:       events.PhaseClone,
+	events.VMBooted:       events.PhaseBoot,
+	events.SSHConnected:   events.PhaseSSH,
+	events.MountReady:     events.PhaseMount,
+	events.CommandStarted: events.PhaseInject,
+	events.CommandExited:  events.PhaseCommand,
+	events.CleanupDone:    events.PhaseCleanup,
+}
+
+// NewID returns a new run ID, which sorts chronologically
+func NewID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+
+	return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// NewRun starts the record of a run
+func NewRun(id string, now time.Time) *Run {
+	return &Run{
+		ID:           id,
+		StartedAt:    now.UTC(),
+		lastBoundary: now,
+	}
+}
+
+// Observe records the phase timings, exit code and errors from the run's lifecycle events
+func (run *Run) Observe(event events.Event) {
+	run.mtx.Lock()
+	defer run.mtx.Unlock()
+
+	if event.VM != "" {
+		run.VM = event.VM
+	}
+
+	switch event.Type {
+	case events.CommandExited:
+		run.ExitCode = event.ExitCode
+	case events.Error:
+		run.Error = fmt.Sprintf("%s: %s", event.Phase, event.Error)
+	}
+
+	if phase, ok := phaseEnds[event.Type]; ok {
+		run.Phases = append(run.Phases, Phase{Name: phase, Duration: event.Time.Sub(run.lastBoundary)})
+		run.lastBoundary = event.Time
+	}
+}
+
+// Finish records the end of the run and its outcome
+func (run *Run) Finish(now time.Time, err error) {
+	run.mtx.Lock()
+	defer run.mtx.Unlock()
+
+	run.FinishedAt = now.UTC()
+
+	if err != nil && run.Error == "" && run.ExitCode == nil {
+		run.Error = err.Error()
+	}
+}
+
+// Duration returns how long the run took, or has been running for
+func (run *Run) Duration(now time.Time) time.Duration {
+	if run.FinishedAt.IsZero() {
+		return now.Sub(run.StartedAt)
+	}
+
+	return run.FinishedAt.Sub(run.StartedAt)
+}
+
+// Status describes the outcome of the run in a word or two
+func (run *Run) Status() string {
+	switch {
+	case run.ExitCode != nil:
+		return fmt.Sprintf("exit %d", *run.ExitCode)
+	case run.Error != "":
+		return "failed"
+	case run.FinishedAt.IsZero():
+		return "running"
+	default:
+		return "done"
+	}
+}
+
+// Store keeps the run records as JSON files in a directory
+type Store struct {
+	dir string
+
+	// warnings receives the run records List skips
+	warnings io.Writer
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, warnings: os.Stderr}
+}
+
+// DefaultStore returns the store located in ~/.config/chamber/runs
+func DefaultStore() (*Store, error) {
+	dir, err := config.Path("runs")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(dir), nil
+}
+
+// Save writes the run record, replacing any previous version
+func (store *Store) Save(run *Run) error {
+	run.mtx.Lock()
+	defer run.mtx.Unlock()
+
+	if err := config.WriteJSON(store.path(run.ID), run); err != nil {
+		return fmt.Errorf("failed to save run: %w", err)
+	}
+
+	return nil
+}
+
+// Load returns the run with the given ID, or the only run whose ID starts with it
+func (store *Store) Load(id string) (*Run, error) {
+	if run, err := store.load(id); !errors.Is(err, ErrNotFound) {
+		return run, err
+	}
+
+	ids, err := store.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, candidate := range ids {
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return store.load(matches[0])
+	default:
+		return nil, fmt.Errorf("%w: %s matches %d runs", ErrAmbiguous, id, len(matches))
+	}
+}
+
+func (store *Store) load(id string) (*Run, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+
+	contents, err := os.ReadFile(store.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+
+		return nil, fmt.Errorf("failed to read run: %w", err)
+	}
+
+	var run Run
+	if err := json.Unmarshal(contents, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+
+	return &run, nil
+}
+
+// List returns all runs, the most recent first. Runs whose records can't be read,
+// e.g. edited by hand, are skipped with a warning rather than hiding all the others.
+func (store *Store) List() ([]*Run, error) {
+	ids, err := store.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Run
+
+	for _, id := range ids {
+		run, err := store.load(id)
+		if err != nil {
+			fmt.Fprintf(store.warnings, "Warning: skipping run %s: %v\n", id, err)
+
+			continue
+		}
+
+		result = append(result, run)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartedAt.After(result[j].StartedAt)
+	})
+
+	return result, nil
+}
+
+func (store *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	var ids []string
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || strings.HasPrefix(id, ".") {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (store *Store) path(id string) string {
+	return filepath.Join(store.dir, id+".json")
+}
+
+// Filter selects runs, the zero value matches all runs
+type Filter struct {
+	// Dir matches runs in the directory or its subdirectories
+	Dir    string
+	Agent  string
+	Seed   string
+	Since  time.Time
+	Until  time.Time
+	Failed bool
+}
+
+// Match reports whether the run passes the filter
+func (filter Filter) Match(run *Run) bool {
+	if filter.Dir != "" && run.Dir != filter.Dir && !strings.HasPrefix(run.Dir, filter.Dir+string(filepath.Separator)) {
+		return false
+	}
+	if filter.Agent != "" && run.Agent != filter.Agent {
+		return false
+	}
+	if filter.Seed != "" && run.Seed != filter.Seed {
+		return false
+	}
+	if !filter.Since.IsZero() && run.StartedAt.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && !run.StartedAt.Before(filter.Until) {
+		return false
+	}
+	if filter.Failed && run.Error == "" && (run.ExitCode == nil || *run.ExitCode == 0) {
+		return false
+	}
+
+	return true
+}
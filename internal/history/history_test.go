@@ -0,0 +1,143 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/events"
+)
+
+func TestRunObserve(t *testing.T) {
+	started := time.Date(2025, 1, 7, 10, 0, 0, 0, time.UTC)
+	run := NewRun("20250107-100000-abcd", started)
+
+	at := func(seconds int) time.Time {
+		return started.Add(time.Duration(seconds) * time.Second)
+	}
+
+	run.Observe(events.Event{Type: events.VMCloned, Time: at(2), VM: "chamber-ephemeral-20250107-100000"})
+	run.Observe(events.Event{Type: events.VMBooted, Time: at(20)})
+	run.Observe(events.Event{Type: events.SSHConnected, Time: at(21)})
+	run.Observe(events.Event{Type: events.MountReady, Time: at(22)})
+	run.Observe(events.Event{Type: events.CommandStarted, Time: at(23)})
+	run.Observe(events.Event{Type: events.CommandExited, Time: at(83), ExitCode: events.Int(1)})
+	run.Observe(events.Event{Type: events.CleanupDone, Time: at(88)})
+	run.Finish(at(88), errors.New("command exited with status 1"))
+
+	expected := []Phase{
+		{events.PhaseClone, 2 * time.Second},
+		{events.PhaseBoot, 18 * time.Second},
+		{events.PhaseSSH, time.Second},
+		{events.PhaseMount, time.Second},
+		{events.PhaseInject, time.Second},
+		{events.PhaseCommand, time.Minute},
+		{events.PhaseCleanup, 5 * time.Second},
+	}
+	if !reflect.DeepEqual(run.Phases, expected) {
+		t.Errorf("got phases %v, want %v", run.Phases, expected)
+	}
+
+	if run.VM != "chamber-ephemeral-20250107-100000" {
+		t.Errorf("unexpected VM %q", run.VM)
+	}
+	if run.Status() != "exit 1" || run.Error != "" {
+		t.Errorf("a command that ran isn't a failed run, got %q, %q", run.Status(), run.Error)
+	}
+	if run.Duration(time.Now()) != 88*time.Second {
+		t.Errorf("unexpected duration %s", run.Duration(time.Now()))
+	}
+
+	failed := NewRun("20250107-110000-abcd", started)
+	failed.Observe(events.Event{Type: events.Error, Time: at(1), Phase: events.PhaseClone, Error: "no such VM"})
+	failed.Finish(at(1), errors.New("no such VM"))
+
+	if failed.Status() != "failed" || failed.Error != "clone: no such VM" {
+		t.Errorf("unexpected outcome %q, %q", failed.Status(), failed.Error)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	for i, id := range []string{"20250107-100000-aaaa", "20250107-100000-bbbb", "20250108-090000-cccc"} {
+		run := NewRun(id, time.Date(2025, 1, 7+i, 0, 0, 0, 0, time.UTC))
+		run.Dir = "/Users/fedor/app"
+
+		if err := store.Save(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].ID != "20250108-090000-cccc" {
+		t.Errorf("expected the most recent run first, got %v", runs)
+	}
+
+	run, err := store.Load("20250108")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != "20250108-090000-cccc" || run.Dir != "/Users/fedor/app" {
+		t.Errorf("unexpected run %+v", run)
+	}
+
+	if _, err := store.Load("20250107"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("expected ErrAmbiguous, got %v", err)
+	}
+	if _, err := store.Load("2024"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// A corrupted record doesn't hide the other runs
+	if err := os.WriteFile(filepath.Join(store.dir, "20250109-080000-dddd.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings bytes.Buffer
+	store.warnings = &warnings
+
+	runs, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 {
+		t.Errorf("expected the 3 other runs, got %v", runs)
+	}
+	if !strings.Contains(warnings.String(), "skipping run 20250109-080000-dddd") {
+		t.Errorf("expected a warning about the corrupted run, got %q", warnings.String())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	run := &Run{
+		Dir:       "/Users/fedor/app/ios",
+		Agent:     "claude",
+		Seed:      "xcode",
+		StartedAt: time.Date(2025, 1, 7, 12, 0, 0, 0, time.UTC),
+		ExitCode:  events.Int(0),
+	}
+
+	for filter, expected := range map[*Filter]bool{
+		{}:                        true,
+		{Dir: "/Users/fedor/app"}: true,
+		{Dir: "/Users/fedor/ap"}:  false,
+		{Agent: "codex"}:          false,
+		{Seed: "xcode"}:           true,
+		{Since: time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC), Until: time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)}: true,
+		{Until: time.Date(2025, 1, 7, 12, 0, 0, 0, time.UTC)}:                                                    false,
+		{Failed: true}: false,
+	} {
+		if filter.Match(run) != expected {
+			t.Errorf("%+v: expected %t", *filter, expected)
+		}
+	}
+}
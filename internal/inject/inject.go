@@ -0,0 +1,288 @@
+package inject
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/cirruslabs/chamber/internal/transfer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultMode is used for injected files unless specified otherwise,
+// since agent configuration tends to contain tokens
+const defaultMode fs.FileMode = 0o600
+
+var ErrInvalidFile = errors.New("invalid file to inject")
+
+// File is a configuration file that is copied into the ephemeral VM before each run,
+// e.g. ~/.claude/settings.json, a global CLAUDE.md or Codex's config.toml
+type File struct {
+	// Src is the file on the host, relative paths are resolved against
+	// the directory of the configuration file that declares it
+	Src string `yaml:"src,omitempty"`
+
+	// Content is the file's contents, an alternative to Src for short files
+	Content string `yaml:"content,omitempty"`
+
+	// Dst is the path in the guest, a leading "~/" refers to the guest user's home
+	Dst string `yaml:"dst"`
+
+	// Template renders the file with Go's text/template before copying it
+	Template bool `yaml:"template,omitempty"`
+
+	// Agent restricts the file to runs of the given agent
+	Agent string `yaml:"agent,omitempty"`
+
+	// Optional skips the file when Src doesn't exist instead of failing
+	Optional bool `yaml:"optional,omitempty"`
+
+	// Mode is the octal file mode in the guest, defaults to 0600
+	Mode string `yaml:"mode,omitempty"`
+
+	// root is the project directory Src must stay in, for the files declared by a project,
+	// which can't be trusted with the host's files and environment
+	root string
+}
+
+// Validate checks that the file is usable
+func (file *File) Validate() error {
+	if file.Dst == "" {
+		return fmt.Errorf("%w: no destination for %s", ErrInvalidFile, valueOr(file.Src, "inline content"))
+	}
+
+	if (file.Src == "") == (file.Content == "") {
+		return fmt.Errorf("%w: %s needs either src or content", ErrInvalidFile, file.Dst)
+	}
+
+	if _, err := file.mode(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Resolve makes Src absolute, resolving it against dir and expanding a leading "~/" to the host's home
+func (file File) Resolve(dir string) (File, error) {
+	if file.Src == "" {
+		return file, nil
+	}
+
+	if rest, ok := strings.CutPrefix(file.Src, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return file, fmt.Errorf("failed to get home directory: %w", err)
+		}
+
+		file.Src = filepath.Join(home, rest)
+	} else if !filepath.IsAbs(file.Src) {
+		file.Src = filepath.Join(dir, file.Src)
+	}
+
+	return file, nil
+}
+
+// Resolve resolves all files against dir, see File.Resolve
+func Resolve(files []File, dir string) ([]File, error) {
+	var result []File
+
+	for _, file := range files {
+		resolved, err := file.Resolve(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, resolved)
+	}
+
+	return result, nil
+}
+
+// ResolveProject resolves the files declared by the project in dir, their sources must be inside
+// dir and their templates only see the forwarded environment variables, see Data.Env
+func ResolveProject(files []File, dir string) ([]File, error) {
+	result, err := Resolve(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		result[i].root = dir
+	}
+
+	return result, nil
+}
+
+// source returns the path to read Src from, with the symbolic links resolved
+// so that a project's file can't point outside of the project
+func (file *File) source() (string, error) {
+	if file.root == "" {
+		return file.Src, nil
+	}
+
+	src, err := filepath.EvalSymlinks(file.Src)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := filepath.EvalSymlinks(file.root)
+	if err != nil {
+		return "", err
+	}
+
+	if rel, err := filepath.Rel(root, src); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is outside of the project %s", ErrInvalidFile, file.Src, file.root)
+	}
+
+	return src, nil
+}
+
+func (file *File) mode() (fs.FileMode, error) {
+	if file.Mode == "" {
+		return defaultMode, nil
+	}
+
+	mode, err := strconv.ParseUint(file.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("%w: %s has an invalid mode %q", ErrInvalidFile, file.Dst, file.Mode)
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// Data is available to templated files
+type Data struct {
+	// Agent is the name of the agent being run, if any
+	Agent string
+
+	// Seed is the name of the seed the VM is cloned from
+	Seed string
+
+	// Project is the directory on the host the VM works in, the worktree if there is one
+	Project string
+
+	// Workspace is the path the working directory is mounted at in the guest
+	Workspace string
+
+	// Home is the guest user's home directory
+	Home string
+
+	// Env are the variables forwarded to the VM, which is what the templates of
+	// a project's files get instead of the host environment
+	Env map[string]string
+}
+
+// Rendered is a file ready to be written to the guest
+type Rendered struct {
+	Source   string
+	Dst      string
+	Contents []byte
+	Mode     fs.FileMode
+}
+
+// Render reads and templates the files that apply to data.Agent
+func Render(files []File, data Data) ([]Rendered, error) {
+	var result []Rendered
+
+	for _, file := range files {
+		if file.Agent != "" && file.Agent != data.Agent {
+			continue
+		}
+
+		if err := file.Validate(); err != nil {
+			return nil, err
+		}
+
+		mode, _ := file.mode()
+
+		source := "inline content"
+		contents := []byte(file.Content)
+
+		if file.Src != "" {
+			source = file.Src
+
+			src, err := file.source()
+			if errors.Is(err, ErrInvalidFile) {
+				return nil, err
+			}
+			if err == nil {
+				contents, err = os.ReadFile(src)
+			}
+			if err != nil {
+				if file.Optional && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+
+				return nil, fmt.Errorf("failed to read %s: %w", file.Src, err)
+			}
+		}
+
+		if file.Template {
+			getenv := os.Getenv
+			if file.root != "" {
+				getenv = func(name string) string {
+					return data.Env[name]
+				}
+			}
+
+			rendered, err := renderTemplate(source, contents, data, getenv)
+			if err != nil {
+				return nil, err
+			}
+
+			contents = rendered
+		}
+
+		result = append(result, Rendered{
+			Source:   source,
+			Dst:      file.Dst,
+			Contents: contents,
+			Mode:     mode,
+		})
+	}
+
+	return result, nil
+}
+
+func renderTemplate(name string, contents []byte, data Data, getenv func(string) string) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": getenv}).
+		Parse(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Upload writes the rendered files to the guest
+func Upload(client *gossh.Client, files []Rendered) error {
+	for _, file := range files {
+		if err := transfer.WriteFile(client, file.Contents, file.Dst, file.Mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
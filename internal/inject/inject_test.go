@@ -0,0 +1,121 @@
+package inject
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHAMBER_TEST_MODEL", "opus")
+
+	if err := os.WriteFile(filepath.Join(dir, "CLAUDE.md"),
+		[]byte("Work in {{.Workspace}} as {{.Agent}} with {{env \"CHAMBER_TEST_MODEL\"}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Resolve([]File{
+		{Src: "CLAUDE.md", Dst: "~/.claude/CLAUDE.md", Template: true},
+		{Content: "model = \"o3\"\n", Dst: "~/.codex/config.toml", Agent: "codex", Mode: "0644"},
+		{Src: "missing.json", Dst: "~/.claude/settings.json", Optional: true},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := Render(files, Data{Agent: "claude", Workspace: "/Users/admin/workspace/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Codex's configuration doesn't apply and the optional file is missing
+	if len(rendered) != 1 {
+		t.Fatalf("expected a single file, got %+v", rendered)
+	}
+
+	if rendered[0].Source != filepath.Join(dir, "CLAUDE.md") {
+		t.Errorf("the source should be resolved against the declaring directory, got %s", rendered[0].Source)
+	}
+	if string(rendered[0].Contents) != "Work in /Users/admin/workspace/app as claude with opus\n" {
+		t.Errorf("unexpected contents %q", rendered[0].Contents)
+	}
+	if rendered[0].Mode != 0o600 {
+		t.Errorf("expected a private file by default, got %o", rendered[0].Mode)
+	}
+
+	rendered, err = Render(files, Data{Agent: "codex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != 2 || rendered[1].Mode != 0o644 {
+		t.Errorf("unexpected files for codex %+v", rendered)
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	for _, file := range []File{
+		{Content: "x"},
+		{Dst: "~/a"},
+		{Src: "a", Content: "b", Dst: "~/a"},
+		{Content: "x", Dst: "~/a", Mode: "rw"},
+		{Content: "{{.Unknown}}", Dst: "~/a", Template: true},
+	} {
+		if _, err := Render([]File{file}, Data{}); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("%+v: expected ErrInvalidFile, got %v", file, err)
+		}
+	}
+
+	if _, err := Render([]File{{Src: filepath.Join(t.TempDir(), "missing"), Dst: "~/a"}}, Data{}); err == nil {
+		t.Error("a missing file that isn't optional should fail the run")
+	}
+}
+
+func TestRenderProject(t *testing.T) {
+	home := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("CHAMBER_TEST_TOKEN", "hunter2")
+
+	if err := os.WriteFile(filepath.Join(home, "id_ed25519"), []byte("key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(home, filepath.Join(project, "home")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "CLAUDE.md"),
+		[]byte(`{{env "CHAMBER_TEST_TOKEN"}}/{{env "MODEL"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither a path outside of the project nor a symbolic link to one can be read
+	relative, err := filepath.Rel(project, filepath.Join(home, "id_ed25519"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{filepath.Join(home, "id_ed25519"), relative, "home/id_ed25519"} {
+		files, err := ResolveProject([]File{{Src: src, Dst: "~/a"}}, project)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Render(files, Data{}); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("%s: expected ErrInvalidFile, got %v", src, err)
+		}
+	}
+
+	// Templates only see the forwarded variables
+	files, err := ResolveProject([]File{{Src: "CLAUDE.md", Dst: "~/a", Template: true}}, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := Render(files, Data{Env: map[string]string{"MODEL": "opus"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents := string(rendered[0].Contents); contents != "/opus" {
+		t.Errorf("expected only the forwarded variables to be rendered, got %q", contents)
+	}
+}
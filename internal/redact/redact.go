@@ -0,0 +1,342 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mask replaces redacted values in the output
+const Mask = "<redacted>"
+
+const (
+	// Secrets shorter than this are too likely to occur in regular output to be masked
+	minSecretLength = 6
+
+	// Tokens that keep growing past this length are flushed as is
+	maxTokenLength = 256
+
+	// The output held back because it might be the beginning of a secret is flushed after
+	// this long without new output, so that e.g. a prompt is never stuck in the buffer
+	flushDelay = 50 * time.Millisecond
+)
+
+// tokenPatterns are the common token formats that are masked even when they weren't injected.
+// Each token starts with one of the prefixes, which lets the writer hold back only output that
+// might turn out to be a token.
+var tokenPatterns = []struct {
+	prefixes []string
+	pattern  *regexp.Regexp
+}{
+	{[]string{"AKIA"}, regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{[]string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_"}, regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{[]string{"github_pat_"}, regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+	{[]string{"sk-"}, regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{[]string{"xoxa-", "xoxb-", "xoxp-", "xoxr-", "xoxs-"}, regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{[]string{"AIza"}, regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+}
+
+// Writer masks secret values and common token patterns in a stream of terminal output.
+//
+// A secret can be split across writes, so output that might be the beginning of a secret is
+// held back until the rest arrives. Secrets are also recognized when terminal escape sequences,
+// e.g. colors, are interleaved with their characters, in which case the escape sequences are kept.
+type Writer struct {
+	mtx     sync.Mutex
+	w       io.Writer
+	secrets [][]byte
+	pending []byte
+	timer   *time.Timer
+	err     error
+}
+
+// NewWriter returns a writer that masks the given secrets and common token patterns before writing to w
+func NewWriter(w io.Writer, secrets []string) *Writer {
+	writer := &Writer{w: w}
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			writer.secrets = append(writer.secrets, []byte(secret))
+		}
+	}
+
+	return writer
+}
+
+// Write always consumes all of p, returning an error only if writing to the underlying writer failed
+func (writer *Writer) Write(p []byte) (int, error) {
+	writer.mtx.Lock()
+	defer writer.mtx.Unlock()
+
+	if writer.err != nil {
+		return 0, writer.err
+	}
+
+	writer.pending = append(writer.pending, p...)
+	writer.flush(false)
+
+	if len(writer.pending) != 0 {
+		if writer.timer == nil {
+			writer.timer = time.AfterFunc(flushDelay, writer.Flush)
+		} else {
+			writer.timer.Reset(flushDelay)
+		}
+	}
+
+	return len(p), writer.err
+}
+
+// Flush writes out all the output held back so far
+func (writer *Writer) Flush() {
+	writer.mtx.Lock()
+	defer writer.mtx.Unlock()
+
+	writer.flush(true)
+}
+
+// Close flushes the held back output, the underlying writer is left open
+func (writer *Writer) Close() error {
+	writer.mtx.Lock()
+	defer writer.mtx.Unlock()
+
+	if writer.timer != nil {
+		writer.timer.Stop()
+	}
+
+	writer.flush(true)
+
+	return writer.err
+}
+
+// flush writes out the pending output that can't be the beginning of a secret, or all of it when final
+func (writer *Writer) flush(final bool) {
+	if writer.err != nil || len(writer.pending) == 0 {
+		return
+	}
+
+	text := parse(writer.pending)
+
+	hold := len(text.visible)
+	if !final {
+		hold = writer.holdFrom(text.visible)
+	}
+
+	var out []byte
+	raw := 0
+
+	for _, match := range writer.matches(text.visible) {
+		if match.end > hold {
+			// The match might still grow, e.g. a token that's only partially printed
+			hold = min(hold, match.start)
+
+			break
+		}
+
+		start, end := text.index[match.start], text.index[match.end-1]+1
+
+		out = append(out, writer.pending[raw:start]...)
+		out = append(out, Mask...)
+		out = append(out, text.escapes(writer.pending, start, end)...)
+		raw = end
+	}
+
+	cut := len(writer.pending)
+	switch {
+	case hold < len(text.visible):
+		cut = text.index[hold]
+	case !final:
+		// Don't split an escape sequence that isn't complete yet
+		cut = text.incomplete
+	}
+
+	out = append(out, writer.pending[raw:cut]...)
+	writer.pending = append(writer.pending[:0], writer.pending[cut:]...)
+
+	if len(out) != 0 {
+		_, writer.err = writer.w.Write(out)
+	}
+}
+
+// holdFrom returns the position in the visible text from which the output might be the beginning of a secret
+func (writer *Writer) holdFrom(visible []byte) int {
+	hold := len(visible)
+
+	for _, secret := range writer.secrets {
+		for length := min(len(secret)-1, len(visible)); length > 0; length-- {
+			if bytes.HasSuffix(visible, secret[:length]) {
+				hold = min(hold, len(visible)-length)
+
+				break
+			}
+		}
+	}
+
+	// Find the trailing run of characters tokens consist of
+	start := len(visible)
+	for start > 0 && isTokenChar(visible[start-1]) && len(visible)-start < maxTokenLength {
+		start--
+	}
+
+	for i := start; i < len(visible) && i < hold; i++ {
+		if couldBeToken(visible[i:]) {
+			hold = i
+
+			break
+		}
+	}
+
+	return hold
+}
+
+func couldBeToken(tail []byte) bool {
+	for _, token := range tokenPatterns {
+		for _, prefix := range token.prefixes {
+			if bytes.HasPrefix(tail, []byte(prefix)) || bytes.HasPrefix([]byte(prefix), tail) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isTokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+// matches returns the sorted, non-overlapping spans of the visible text to mask
+func (writer *Writer) matches(visible []byte) []span {
+	var spans []span
+
+	for _, secret := range writer.secrets {
+		for offset := 0; ; {
+			i := bytes.Index(visible[offset:], secret)
+			if i == -1 {
+				break
+			}
+
+			spans = append(spans, span{offset + i, offset + i + len(secret)})
+			offset += i + len(secret)
+		}
+	}
+
+	for _, token := range tokenPatterns {
+		for _, match := range token.pattern.FindAllIndex(visible, -1) {
+			spans = append(spans, span{match[0], match[1]})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	var merged []span
+
+	for _, current := range spans {
+		if last := len(merged) - 1; last >= 0 && current.start <= merged[last].end {
+			merged[last].end = max(merged[last].end, current.end)
+
+			continue
+		}
+
+		merged = append(merged, current)
+	}
+
+	return merged
+}
+
+// text is terminal output with the escape sequences taken out
+type text struct {
+	visible []byte
+
+	// index maps the positions in visible to the positions in the raw output
+	index []int
+
+	// incomplete is the position of an escape sequence at the end of the raw
+	// output that isn't complete yet, or the length of the raw output
+	incomplete int
+}
+
+func parse(raw []byte) text {
+	result := text{incomplete: len(raw)}
+
+	for i := 0; i < len(raw); {
+		if raw[i] != 0x1b {
+			result.visible = append(result.visible, raw[i])
+			result.index = append(result.index, i)
+			i++
+
+			continue
+		}
+
+		length, ok := escapeLength(raw[i:])
+		if !ok {
+			result.incomplete = i
+
+			break
+		}
+
+		i += length
+	}
+
+	return result
+}
+
+// escapes returns the escape sequences within raw[start:end]
+func (text text) escapes(raw []byte, start int, end int) []byte {
+	var result []byte
+
+	next := start
+	for _, i := range text.index {
+		if i < start || i >= end {
+			continue
+		}
+
+		result = append(result, raw[next:i]...)
+		next = i + 1
+	}
+
+	return append(result, raw[next:end]...)
+}
+
+// escapeLength returns the length of the escape sequence at the beginning of b,
+// or false if b ends before the sequence does
+func escapeLength(b []byte) (int, bool) {
+	if len(b) < 2 {
+		return 0, false
+	}
+
+	switch b[1] {
+	case '[':
+		// CSI: parameters and intermediate bytes followed by a final byte
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1, true
+			}
+		}
+
+		return 0, false
+	case ']', 'P', '_', '^':
+		// OSC and other strings: terminated by BEL or ST
+		for i := 2; i < len(b); i++ {
+			if b[i] == 0x07 {
+				return i + 1, true
+			}
+			if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+
+		return 0, false
+	default:
+		return 2, true
+	}
+}
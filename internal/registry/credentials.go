@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tartKeychainLabel is the label of the keychain items "tart login" creates
+const tartKeychainLabel = "Tart Credentials"
+
+// dockerConfig is the part of ~/.docker/config.json that describes credentials
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+
+	// CredHelpers maps registry hosts to credential helpers, which take precedence over CredsStore
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+// defaultCredentials looks up the registry credentials the way Tart does: in the environment,
+// then in ~/.docker/config.json and its credential helpers, then in the keychain items of "tart login"
+func defaultCredentials(host string) (string, string, bool) {
+	username, password := os.Getenv("TART_REGISTRY_USERNAME"), os.Getenv("TART_REGISTRY_PASSWORD")
+	if username != "" && password != "" {
+		return username, password, true
+	}
+
+	if username, password, ok := dockerCredentials(host); ok {
+		return username, password, true
+	}
+
+	return keychainCredentials(host)
+}
+
+func dockerCredentials(host string) (string, string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", false
+	}
+
+	contents, err := os.ReadFile(filepath.Join(home, ".docker", "config.json"))
+	if err != nil {
+		return "", "", false
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return "", "", false
+	}
+
+	if auth, ok := config.Auths[host]; ok && auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", false
+		}
+
+		return strings.Cut(string(decoded), ":")
+	}
+
+	helper, ok := config.CredHelpers[host]
+	if !ok {
+		helper = config.CredsStore
+	}
+	if helper == "" {
+		return "", "", false
+	}
+
+	return helperCredentials(helper, host)
+}
+
+// helperCredentials asks the docker-credential-<helper> program for the credentials of host
+func helperCredentials(helper string, host string) (string, string, bool) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(host)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", false
+	}
+
+	var credentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(output, &credentials); err != nil || credentials.Secret == "" {
+		return "", "", false
+	}
+
+	return credentials.Username, credentials.Secret, true
+}
+
+// keychainCredentials reads the credentials "tart login" stored in the macOS keychain
+func keychainCredentials(host string) (string, string, bool) {
+	attributes, err := exec.Command("security", "find-internet-password",
+		"-s", host, "-l", tartKeychainLabel).Output()
+	if err != nil {
+		return "", "", false
+	}
+
+	password, err := exec.Command("security", "find-internet-password",
+		"-s", host, "-l", tartKeychainLabel, "-w").Output()
+	if err != nil {
+		return "", "", false
+	}
+
+	username, ok := keychainAccount(attributes)
+	if !ok {
+		return "", "", false
+	}
+
+	return username, string(bytes.TrimSuffix(password, []byte("\n"))), true
+}
+
+// keychainAccount extracts the account from the attributes printed by "security find-internet-password",
+// which include a line like `    "acct"<blob>="username"`
+func keychainAccount(attributes []byte) (string, bool) {
+	for _, line := range strings.Split(string(attributes), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), `"acct"<blob>=`)
+		if !ok {
+			continue
+		}
+
+		if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+			return "", false
+		}
+
+		return value[1 : len(value)-1], true
+	}
+
+	return "", false
+}
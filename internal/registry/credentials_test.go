@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDockerCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// The helper prints the credentials of the host it's given
+	bin := t.TempDir()
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	writeFile(t, filepath.Join(bin, "docker-credential-fake"), 0o755,
+		"#!/bin/sh\nread host\nprintf '{\"ServerURL\":\"%s\",\"Username\":\"helper\",\"Secret\":\"%s-token\"}' \"$host\" \"$host\"\n")
+
+	writeFile(t, filepath.Join(home, ".docker", "config.json"), 0o600, `{
+  "auths": {"docker.io": {"auth": "dXNlcjpwYXNz"}, "ghcr.io": {}},
+  "credHelpers": {"ghcr.io": "fake", "quay.io": "missing"},
+  "credsStore": "fake"
+}`)
+
+	for host, expected := range map[string][2]string{
+		"docker.io":           {"user", "pass"},
+		"ghcr.io":             {"helper", "ghcr.io-token"},
+		"registry.example.io": {"helper", "registry.example.io-token"},
+	} {
+		username, password, ok := dockerCredentials(host)
+		if !ok || username != expected[0] || password != expected[1] {
+			t.Errorf("%s: expected %v, got %q %q %t", host, expected, username, password, ok)
+		}
+	}
+
+	// A helper that isn't installed doesn't fall back to the store
+	if _, _, ok := dockerCredentials("quay.io"); ok {
+		t.Error("quay.io: expected no credentials")
+	}
+}
+
+func TestKeychainAccount(t *testing.T) {
+	attributes := []byte(`keychain: "/Users/admin/Library/Keychains/login.keychain-db"
+class: "inet"
+attributes:
+    0x00000007 <blob>="Tart Credentials"
+    "acct"<blob>="octocat"
+    "srvr"<blob>="ghcr.io"
+`)
+
+	if account, ok := keychainAccount(attributes); !ok || account != "octocat" {
+		t.Errorf("expected octocat, got %q (%t)", account, ok)
+	}
+	if _, ok := keychainAccount([]byte(`    "acct"<blob>=<NULL>`)); ok {
+		t.Error("expected no account")
+	}
+}
+
+func writeFile(t *testing.T, path string, perm os.FileMode, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), perm); err != nil {
+		t.Fatal(err)
+	}
+}
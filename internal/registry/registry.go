@@ -0,0 +1,373 @@
+// Package registry implements just enough of the OCI distribution API to read and update
+// the annotations of the manifests that Tart pushes, which is where chamber keeps seed metadata
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// Manifests are small, anything bigger is not something we should be editing
+	maxManifestSize = 4 << 20
+)
+
+var (
+	ErrInvalidReference = errors.New("invalid image reference")
+	ErrRequestFailed    = errors.New("registry request failed")
+)
+
+// Reference identifies a manifest in a registry, e.g. ghcr.io/myteam/seed:latest
+type Reference struct {
+	Registry   string
+	Repository string
+
+	// Tag or digest
+	Reference string
+}
+
+// ParseReference parses an image reference, which must include the registry host
+func ParseReference(ref string) (Reference, error) {
+	host, rest, ok := strings.Cut(ref, "/")
+	if !ok || rest == "" || !IsHost(host) {
+		return Reference{}, fmt.Errorf("%w: %q must include a registry, e.g. ghcr.io/org/seed:latest",
+			ErrInvalidReference, ref)
+	}
+
+	result := Reference{Registry: host, Reference: "latest"}
+
+	// A digest takes precedence over the tag, e.g. in ghcr.io/org/seed:v1@sha256:...
+	rest, digest, hasDigest := strings.Cut(rest, "@")
+
+	if i := strings.LastIndex(rest, ":"); i != -1 {
+		result.Repository, result.Reference = rest[:i], rest[i+1:]
+	} else {
+		result.Repository = rest
+	}
+
+	if hasDigest {
+		result.Reference = digest
+	}
+
+	if result.Repository == "" || result.Reference == "" {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, ref)
+	}
+
+	return result, nil
+}
+
+// IsHost reports whether the first component of a reference is a registry host
+// rather than a namespace, using the same rules as Docker
+func IsHost(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+func (ref Reference) String() string {
+	separator := ":"
+	if strings.HasPrefix(ref.Reference, "sha256:") {
+		separator = "@"
+	}
+
+	return ref.Registry + "/" + ref.Repository + separator + ref.Reference
+}
+
+// WithDigest returns the reference pinned to the digest
+func (ref Reference) WithDigest(digest string) Reference {
+	ref.Reference = digest
+
+	return ref
+}
+
+// Client talks to OCI registries using the same credentials Tart does:
+// TART_REGISTRY_USERNAME/TART_REGISTRY_PASSWORD, the Docker configuration or "tart login"
+type Client struct {
+	httpClient *http.Client
+
+	// Insecure makes the client use plain HTTP, like "tart push --insecure"
+	Insecure bool
+
+	// credentials returns the username and password for the registry host, if any
+	credentials func(host string) (string, string, bool)
+}
+
+func New(insecure bool) *Client {
+	return &Client{
+		httpClient:  http.DefaultClient,
+		Insecure:    insecure,
+		credentials: defaultCredentials,
+	}
+}
+
+// Manifest is a raw manifest along with its media type and digest
+type Manifest struct {
+	MediaType string
+	Digest    string
+	Body      []byte
+}
+
+// GetManifest fetches the manifest the reference points to
+func (client *Client) GetManifest(ctx context.Context, ref Reference) (*Manifest, error) {
+	resp, err := client.do(ctx, http.MethodGet, client.manifestURL(ref), nil, "",
+		mediaTypeOCIManifest+", "+mediaTypeDockerManifest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the manifest of %s: %v", ErrRequestFailed, ref, err)
+	}
+
+	return &Manifest{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    digestOf(body),
+		Body:      body,
+	}, nil
+}
+
+// PutManifest uploads the manifest under the reference's tag and returns its digest
+func (client *Client) PutManifest(ctx context.Context, ref Reference, manifest *Manifest) (string, error) {
+	resp, err := client.do(ctx, http.MethodPut, client.manifestURL(ref), manifest.Body, manifest.MediaType, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return digestOf(manifest.Body), nil
+}
+
+// Annotations returns the annotations of the manifest the reference points to
+// along with the manifest's digest
+func (client *Client) Annotations(ctx context.Context, ref Reference) (map[string]string, string, error) {
+	manifest, err := client.GetManifest(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var parsed struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(manifest.Body, &parsed); err != nil {
+		return nil, "", fmt.Errorf("%w: failed to parse the manifest of %s: %v", ErrRequestFailed, ref, err)
+	}
+
+	return parsed.Annotations, manifest.Digest, nil
+}
+
+// SetAnnotations adds the annotations to the manifest the reference's tag points to,
+// keeping everything else intact, and returns the digest of the updated manifest
+func (client *Client) SetAnnotations(ctx context.Context, ref Reference, annotations map[string]string) (string, error) {
+	manifest, err := client.GetManifest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	// Decode into raw messages so that fields we don't know about survive the round-trip
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(manifest.Body, &fields); err != nil {
+		return "", fmt.Errorf("%w: failed to parse the manifest of %s: %v", ErrRequestFailed, ref, err)
+	}
+
+	existing := map[string]string{}
+	if raw, ok := fields["annotations"]; ok {
+		if err := json.Unmarshal(raw, &existing); err != nil {
+			return "", fmt.Errorf("%w: failed to parse the annotations of %s: %v", ErrRequestFailed, ref, err)
+		}
+	}
+
+	for key, value := range annotations {
+		existing[key] = value
+	}
+
+	fields["annotations"], err = json.Marshal(existing)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return client.PutManifest(ctx, ref, &Manifest{MediaType: manifest.MediaType, Body: body})
+}
+
+func (client *Client) manifestURL(ref Reference) string {
+	scheme := "https"
+	if client.Insecure {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.Registry, ref.Repository, ref.Reference)
+}
+
+// do performs the request, authenticating with the scheme the registry asks for on 401
+func (client *Client) do(
+	ctx context.Context,
+	method string,
+	url string,
+	body []byte,
+	contentType string,
+	accept string,
+) (*http.Response, error) {
+	var authorization string
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := client.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			_ = resp.Body.Close()
+
+			authorization, err = client.authorize(ctx, req.URL.Host, challenge)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			_ = resp.Body.Close()
+
+			return nil, fmt.Errorf("%w: %s %s: %s: %s", ErrRequestFailed, method, url, resp.Status,
+				strings.TrimSpace(string(message)))
+		}
+
+		return resp, nil
+	}
+}
+
+// authorize answers a Basic or Bearer WWW-Authenticate challenge
+func (client *Client) authorize(ctx context.Context, host string, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	username, password, hasCredentials := client.credentials(host)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredentials {
+			return "", fmt.Errorf("%w: %s requires credentials, run \"tart login %s\" or set "+
+				"TART_REGISTRY_USERNAME and TART_REGISTRY_PASSWORD", ErrRequestFailed, host, host)
+		}
+
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+		tokenURL, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return "", fmt.Errorf("%w: invalid token realm in %q", ErrRequestFailed, challenge)
+		}
+
+		query := tokenURL.Query()
+		for _, key := range []string{"service", "scope"} {
+			if params[key] != "" {
+				query.Set(key, params[key])
+			}
+		}
+		tokenURL.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCredentials {
+			req.SetBasicAuth(username, password)
+		}
+
+		resp, err := client.httpClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to obtain a token: %v", ErrRequestFailed, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%w: failed to obtain a token from %s: %s", ErrRequestFailed,
+				tokenURL.Host, resp.Status)
+		}
+
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("%w: failed to parse the token response: %v", ErrRequestFailed, err)
+		}
+
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+
+		return "Bearer " + token.Token, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported authentication scheme in %q", ErrRequestFailed, challenge)
+	}
+}
+
+// parseChallenge parses a header like `Bearer realm="https://auth",service="registry",scope="..."`
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end == -1 {
+				params[key] = value[1:]
+
+				break
+			}
+
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+
+		rest = strings.TrimLeft(rest, ", ")
+	}
+
+	return scheme, params
+}
+
+func digestOf(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
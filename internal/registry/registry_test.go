@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is a minimal stand-in for an OCI registry that requires token authentication
+type fakeRegistry struct {
+	mtx       sync.Mutex
+	server    *httptest.Server
+	manifests map[string][]byte
+	types     map[string]string
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	registry := &fakeRegistry{
+		manifests: map[string][]byte{},
+		types:     map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "token-for-" + r.URL.Query().Get("scope")})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		repository, reference, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		scope := "repository:" + repository + ":pull"
+		if r.Method == http.MethodPut {
+			scope += ",push"
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token-for-"+scope {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.server.URL+`/token",service="fake",scope="`+scope+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		registry.mtx.Lock()
+		defer registry.mtx.Unlock()
+
+		key := repository + ":" + reference
+
+		switch r.Method {
+		case http.MethodGet:
+			body, ok := registry.manifests[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", registry.types[key])
+			_, _ = w.Write(body)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			registry.manifests[key] = body
+			registry.types[key] = r.Header.Get("Content-Type")
+			registry.manifests[repository+":"+digestOf(body)] = body
+			registry.types[repository+":"+digestOf(body)] = r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	registry.server = httptest.NewServer(mux)
+	t.Cleanup(registry.server.Close)
+
+	return registry
+}
+
+func (registry *fakeRegistry) host() string {
+	return strings.TrimPrefix(registry.server.URL, "http://")
+}
+
+func TestParseReference(t *testing.T) {
+	for ref, expected := range map[string]Reference{
+		"ghcr.io/myteam/seed":              {"ghcr.io", "myteam/seed", "latest"},
+		"ghcr.io/myteam/seed:v1":           {"ghcr.io", "myteam/seed", "v1"},
+		"localhost:5000/seed:v1":           {"localhost:5000", "seed", "v1"},
+		"ghcr.io/myteam/seed@sha256:abcd":  {"ghcr.io", "myteam/seed", "sha256:abcd"},
+		"localhost:5000/a/b/seed@sha256:1": {"localhost:5000", "a/b/seed", "sha256:1"},
+		"ghcr.io/myteam/seed:v1@sha256:1":  {"ghcr.io", "myteam/seed", "sha256:1"},
+	} {
+		parsed, err := ParseReference(ref)
+		if err != nil {
+			t.Errorf("%s: %v", ref, err)
+
+			continue
+		}
+		if parsed != expected {
+			t.Errorf("%s: got %+v, want %+v", ref, parsed, expected)
+		}
+	}
+
+	for _, ref := range []string{"chamber-seed", "myteam/seed:latest"} {
+		if _, err := ParseReference(ref); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("%s: expected ErrInvalidReference without a registry, got %v", ref, err)
+		}
+	}
+
+	ref, _ := ParseReference("ghcr.io/myteam/seed:v1")
+	if pinned := ref.WithDigest("sha256:abcd").String(); pinned != "ghcr.io/myteam/seed@sha256:abcd" {
+		t.Errorf("unexpected pinned reference %q", pinned)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	fake := newFakeRegistry(t)
+
+	fake.manifests["myteam/seed:latest"] = []byte(`{"schemaVersion":2,"config":{"digest":"sha256:1"},` +
+		`"layers":[{"digest":"sha256:2"}],"annotations":{"org.example":"kept"}}`)
+	fake.types["myteam/seed:latest"] = mediaTypeOCIManifest
+
+	client := New(true)
+	client.credentials = func(host string) (string, string, bool) {
+		return "user", "secret", host == fake.host()
+	}
+
+	ref, err := ParseReference(fake.host() + "/myteam/seed:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := client.SetAnnotations(context.Background(), ref, map[string]string{"dev.chamber.name": "seed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tag and the returned digest both point to the updated manifest
+	for _, ref := range []Reference{ref, ref.WithDigest(digest)} {
+		annotations, manifestDigest, err := client.Annotations(context.Background(), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if manifestDigest != digest {
+			t.Errorf("%s: digest %s, want %s", ref, manifestDigest, digest)
+		}
+		if annotations["dev.chamber.name"] != "seed" || annotations["org.example"] != "kept" {
+			t.Errorf("%s: unexpected annotations %v", ref, annotations)
+		}
+	}
+
+	// Unknown fields survive the round-trip
+	manifest, err := client.GetManifest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest.Body), `"layers":[{"digest":"sha256:2"}]`) {
+		t.Errorf("layers were lost: %s", manifest.Body)
+	}
+	if manifest.MediaType != mediaTypeOCIManifest {
+		t.Errorf("unexpected media type %q", manifest.MediaType)
+	}
+}
+
+func TestAnnotationsUnauthorized(t *testing.T) {
+	fake := newFakeRegistry(t)
+
+	client := New(true)
+	client.credentials = func(host string) (string, string, bool) {
+		return "user", "wrong", true
+	}
+
+	ref, _ := ParseReference(fake.host() + "/myteam/seed:latest")
+
+	if _, _, err := client.Annotations(context.Background(), ref); !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("expected ErrRequestFailed, got %v", err)
+	}
+}
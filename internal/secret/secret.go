@@ -0,0 +1,311 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Scheme prefixes secret references, e.g. secret://keychain/github-token
+const Scheme = "secret://"
+
+var (
+	ErrInvalidReference = errors.New("invalid secret reference")
+	ErrNotFound         = errors.New("secret not found")
+	ErrInvalidSecret    = errors.New("invalid secret")
+	ErrUntrusted        = errors.New("secret references are only resolved in -e flags and ~/.config/chamber/config.yaml")
+
+	providerPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	fileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	envNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Reference points to a secret kept by a provider on the host
+type Reference struct {
+	Provider string
+	Name     string
+}
+
+// IsReference reports whether the value is a secret reference rather than a literal value
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// ParseReference parses secret://<provider>/<name>, where the name may contain
+// slashes, e.g. secret://file/~/.config/token or secret://cmd/op read op://vault/item
+func ParseReference(value string) (Reference, error) {
+	rest, ok := strings.CutPrefix(value, Scheme)
+	if !ok {
+		return Reference{}, fmt.Errorf("%w: %q doesn't start with %s", ErrInvalidReference, value, Scheme)
+	}
+
+	provider, name, _ := strings.Cut(rest, "/")
+	if !providerPattern.MatchString(provider) || name == "" {
+		return Reference{}, fmt.Errorf("%w: %q, expected %s<provider>/<name>", ErrInvalidReference, value, Scheme)
+	}
+
+	return Reference{Provider: provider, Name: name}, nil
+}
+
+func (ref Reference) String() string {
+	return Scheme + ref.Provider + "/" + ref.Name
+}
+
+// Resolver looks up secrets by name for a single provider
+type Resolver interface {
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface
+type ResolverFunc func(ctx context.Context, name string) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Resolvers maps provider names to their resolvers
+type Resolvers struct {
+	providers map[string]Resolver
+
+	// plugin returns the resolver for providers that aren't registered, if any
+	plugin func(provider string) (Resolver, bool)
+}
+
+// NewResolvers returns an empty set of resolvers, useful with fakes in tests
+func NewResolvers() *Resolvers {
+	return &Resolvers{providers: map[string]Resolver{}}
+}
+
+// DefaultResolvers returns the built-in keychain, env, file and cmd providers.
+// Any other provider is handled by a chamber-secret-<provider> executable in $PATH.
+func DefaultResolvers(dir string) *Resolvers {
+	resolvers := NewResolvers()
+
+	resolvers.Register("keychain", ResolverFunc(resolveKeychain))
+	resolvers.Register("env", ResolverFunc(resolveEnv))
+	resolvers.Register("file", ResolverFunc(func(ctx context.Context, name string) (string, error) {
+		return resolveFile(dir, name)
+	}))
+	resolvers.Register("cmd", ResolverFunc(func(ctx context.Context, name string) (string, error) {
+		return runResolver(ctx, dir, "/bin/sh", "-c", name)
+	}))
+
+	resolvers.plugin = func(provider string) (Resolver, bool) {
+		path, err := exec.LookPath("chamber-secret-" + provider)
+		if err != nil {
+			return nil, false
+		}
+
+		return ResolverFunc(func(ctx context.Context, name string) (string, error) {
+			return runResolver(ctx, dir, path, name)
+		}), true
+	}
+
+	return resolvers
+}
+
+// Register adds or replaces the resolver for the provider
+func (resolvers *Resolvers) Register(provider string, resolver Resolver) {
+	resolvers.providers[provider] = resolver
+}
+
+// Resolve looks up the secret the reference points to
+func (resolvers *Resolvers) Resolve(ctx context.Context, value string) (string, error) {
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	resolver, ok := resolvers.providers[ref.Provider]
+	if !ok && resolvers.plugin != nil {
+		resolver, ok = resolvers.plugin(ref.Provider)
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: unknown provider %q in %s, install chamber-secret-%s to support it",
+			ErrInvalidReference, ref.Provider, ref, ref.Provider)
+	}
+
+	secret, err := resolver.Resolve(ctx, ref.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+
+	return secret, nil
+}
+
+func resolveKeychain(ctx context.Context, name string) (string, error) {
+	cmd := exec.CommandContext(ctx, "security", "find-generic-password", "-s", name, "-w")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", fmt.Errorf("%w: no keychain item with service %q", ErrNotFound, name)
+		}
+
+		return "", fmt.Errorf("security failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func resolveEnv(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not set", ErrNotFound, name)
+	}
+
+	return value, nil
+}
+
+func resolveFile(dir string, name string) (string, error) {
+	path := name
+
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+
+		path = filepath.Join(home, rest)
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s doesn't exist", ErrNotFound, path)
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(contents), "\n"), nil
+}
+
+// runResolver runs a command and returns its output as the secret. The command's
+// stderr is passed through, so that it can ask for confirmation or a password.
+func runResolver(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", filepath.Base(name), err)
+	}
+
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// Secret declares how a secret is exposed to the command in the guest:
+// either as an environment variable or as a file on an in-memory volume
+type Secret struct {
+	// Ref is the secret reference, e.g. secret://keychain/github-token
+	Ref string `yaml:"ref"`
+
+	// Env is the environment variable to set to the secret's value
+	Env string `yaml:"env,omitempty"`
+
+	// File is the name of the file to write the secret to in the directory
+	// pointed to by $CHAMBER_SECRETS, which is an in-memory volume in the guest
+	File string `yaml:"file,omitempty"`
+}
+
+// Validate checks that the secret is usable without resolving it
+func (secret *Secret) Validate() error {
+	if _, err := ParseReference(secret.Ref); err != nil {
+		return err
+	}
+
+	if (secret.Env == "") == (secret.File == "") {
+		return fmt.Errorf("%w: %s needs either env or file", ErrInvalidSecret, secret.Ref)
+	}
+
+	if secret.Env != "" && !envNamePattern.MatchString(secret.Env) {
+		return fmt.Errorf("%w: %q is not a valid environment variable name", ErrInvalidSecret, secret.Env)
+	}
+
+	if secret.File != "" && !fileNamePattern.MatchString(secret.File) {
+		return fmt.Errorf("%w: %q must be a plain file name", ErrInvalidSecret, secret.File)
+	}
+
+	return nil
+}
+
+// Resolved holds everything that needs to reach the guest for a single run
+type Resolved struct {
+	// Env are the environment variables with the secret references replaced by their values
+	Env map[string]string
+
+	// Files maps file names on the secrets volume to their contents
+	Files map[string]string
+
+	// Values are all resolved secret values, which must never be shown
+	Values []string
+}
+
+// Resolve replaces the secret references among the environment variable values
+// and resolves the declared secrets
+func Resolve(ctx context.Context, resolvers *Resolvers, env map[string]string, secrets []Secret) (*Resolved, error) {
+	resolved := &Resolved{
+		Env:   map[string]string{},
+		Files: map[string]string{},
+	}
+
+	resolve := func(ref string) (string, error) {
+		value, err := resolvers.Resolve(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+
+		if value != "" {
+			resolved.Values = append(resolved.Values, value)
+		}
+
+		return value, nil
+	}
+
+	for name, value := range env {
+		if IsReference(value) {
+			var err error
+
+			value, err = resolve(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		resolved.Env[name] = value
+	}
+
+	for _, secret := range secrets {
+		if err := secret.Validate(); err != nil {
+			return nil, err
+		}
+
+		value, err := resolve(secret.Ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if secret.Env != "" {
+			resolved.Env[secret.Env] = value
+		} else {
+			resolved.Files[secret.File] = value
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,122 @@
+package secret_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cirruslabs/chamber/internal/secret"
+	"github.com/cirruslabs/chamber/internal/secret/secrettest"
+)
+
+func TestParseReference(t *testing.T) {
+	for value, expected := range map[string]secret.Reference{
+		"secret://keychain/github-token":         {Provider: "keychain", Name: "github-token"},
+		"secret://file/~/.config/token":          {Provider: "file", Name: "~/.config/token"},
+		"secret://file//etc/token":               {Provider: "file", Name: "/etc/token"},
+		"secret://cmd/op read op://vault/item/x": {Provider: "cmd", Name: "op read op://vault/item/x"},
+	} {
+		ref, err := secret.ParseReference(value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+
+			continue
+		}
+		if ref != expected {
+			t.Errorf("%s: got %+v, want %+v", value, ref, expected)
+		}
+	}
+
+	for _, value := range []string{"keychain/token", "secret://keychain", "secret://keychain/", "secret:///token"} {
+		if _, err := secret.ParseReference(value); !errors.Is(err, secret.ErrInvalidReference) {
+			t.Errorf("%s: expected ErrInvalidReference, got %v", value, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	vault := secrettest.NewResolver(map[string]string{
+		"github-token": "ghp_secret",
+		"gcloud":       `{"type": "service_account"}`,
+	})
+	resolvers := secrettest.NewResolvers(map[string]*secrettest.Resolver{"vault": vault})
+
+	resolved, err := secret.Resolve(context.Background(), resolvers,
+		map[string]string{"MODEL": "opus", "GH_TOKEN": "secret://vault/github-token"},
+		[]secret.Secret{
+			{Ref: "secret://vault/gcloud", File: "gcloud.json"},
+			{Ref: "secret://vault/github-token", Env: "GITHUB_TOKEN"},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedEnv := map[string]string{"MODEL": "opus", "GH_TOKEN": "ghp_secret", "GITHUB_TOKEN": "ghp_secret"}
+	if !reflect.DeepEqual(resolved.Env, expectedEnv) {
+		t.Errorf("got env %v, want %v", resolved.Env, expectedEnv)
+	}
+	if resolved.Files["gcloud.json"] != `{"type": "service_account"}` {
+		t.Errorf("unexpected files %v", resolved.Files)
+	}
+
+	// Literal values aren't secrets
+	values := append([]string(nil), resolved.Values...)
+	sort.Strings(values)
+	if !reflect.DeepEqual(values, []string{"ghp_secret", "ghp_secret", `{"type": "service_account"}`}) {
+		t.Errorf("unexpected secret values %q", values)
+	}
+
+	if _, err := secret.Resolve(context.Background(), resolvers, map[string]string{"X": "secret://vault/missing"}, nil); !errors.Is(err, secret.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := secret.Resolve(context.Background(), resolvers, map[string]string{"X": "secret://nope/x"}, nil); !errors.Is(err, secret.ErrInvalidReference) {
+		t.Errorf("expected ErrInvalidReference for an unknown provider, got %v", err)
+	}
+
+	for _, invalid := range []secret.Secret{
+		{Ref: "secret://vault/gcloud"},
+		{Ref: "secret://vault/gcloud", Env: "X", File: "x"},
+		{Ref: "secret://vault/gcloud", File: "../escape"},
+		{Ref: "secret://vault/gcloud", Env: "NOT VALID"},
+	} {
+		if _, err := secret.Resolve(context.Background(), resolvers, nil, []secret.Secret{invalid}); !errors.Is(err, secret.ErrInvalidSecret) {
+			t.Errorf("%+v: expected ErrInvalidSecret, got %v", invalid, err)
+		}
+	}
+}
+
+func TestDefaultResolvers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHAMBER_TEST_SECRET", "from-env")
+
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolvers := secret.DefaultResolvers(dir)
+
+	for ref, expected := range map[string]string{
+		"secret://env/CHAMBER_TEST_SECRET": "from-env",
+		"secret://file/token":              "from-file",
+		"secret://cmd/echo from-cmd":       "from-cmd",
+	} {
+		value, err := resolvers.Resolve(context.Background(), ref)
+		if err != nil {
+			t.Errorf("%s: %v", ref, err)
+
+			continue
+		}
+		if value != expected {
+			t.Errorf("%s: got %q, want %q", ref, value, expected)
+		}
+	}
+
+	if _, err := resolvers.Resolve(context.Background(), "secret://env/CHAMBER_TEST_UNSET"); !errors.Is(err, secret.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package secrettest provides fake secret resolvers for tests
+package secrettest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cirruslabs/chamber/internal/secret"
+)
+
+// Resolver is an in-memory secret provider that records which secrets were looked up
+type Resolver struct {
+	mtx     sync.Mutex
+	secrets map[string]string
+	calls   []string
+}
+
+func NewResolver(secrets map[string]string) *Resolver {
+	return &Resolver{secrets: secrets}
+}
+
+func (resolver *Resolver) Resolve(ctx context.Context, name string) (string, error) {
+	resolver.mtx.Lock()
+	defer resolver.mtx.Unlock()
+
+	resolver.calls = append(resolver.calls, name)
+
+	value, ok := resolver.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", secret.ErrNotFound, name)
+	}
+
+	return value, nil
+}
+
+// Calls returns the names of the looked up secrets in order
+func (resolver *Resolver) Calls() []string {
+	resolver.mtx.Lock()
+	defer resolver.mtx.Unlock()
+
+	return append([]string(nil), resolver.calls...)
+}
+
+// NewResolvers returns resolvers that only know the given providers
+func NewResolvers(providers map[string]*Resolver) *secret.Resolvers {
+	resolvers := secret.NewResolvers()
+
+	for provider, resolver := range providers {
+		resolvers.Register(provider, resolver)
+	}
+
+	return resolvers
+}
@@ -0,0 +1,80 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/cirruslabs/chamber/internal/transfer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// VolumePath is where the in-memory secrets volume is mounted in the guest
+const VolumePath = "/Volumes/chamber-secrets"
+
+// EnvVar points the command to the directory with the secret files
+const EnvVar = "CHAMBER_SECRETS"
+
+// volumeSizeSectors is the size of the RAM disk in 512-byte sectors, i.e. 8 MiB
+const volumeSizeSectors = 16384
+
+// Volume is a RAM disk in the guest holding secret files for the duration of a run,
+// so that they never touch the VM's disk, let alone the seed or the shared working directory
+type Volume struct {
+	client *gossh.Client
+	device string
+}
+
+// MountVolumeCommand returns the guest command that creates the RAM disk and prints its device
+func MountVolumeCommand() string {
+	return strings.Join([]string{
+		fmt.Sprintf("dev=$(hdiutil attach -nomount ram://%d | awk '{print $1}')", volumeSizeSectors),
+		`diskutil quiet erasevolume HFS+ chamber-secrets "$dev"`,
+		"chmod 700 " + VolumePath,
+		`echo "$dev"`,
+	}, " && ")
+}
+
+// UnmountVolumeCommand returns the guest command that detaches the RAM disk, discarding its contents
+func UnmountVolumeCommand(device string) string {
+	return "hdiutil detach -force " + device
+}
+
+// MountVolume creates the secrets volume in the guest
+func MountVolume(client *gossh.Client) (*Volume, error) {
+	output, err := ssh.Output(client, MountVolumeCommand())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the secrets volume: %w", err)
+	}
+
+	device := strings.TrimSpace(output)
+	if !strings.HasPrefix(device, "/dev/") {
+		return nil, fmt.Errorf("failed to create the secrets volume: unexpected device %q", device)
+	}
+
+	return &Volume{client: client, device: device}, nil
+}
+
+// Write stores the secret files on the volume, readable only by the guest user
+func (volume *Volume) Write(files map[string]string) error {
+	for name, contents := range files {
+		if err := transfer.WriteFile(volume.client, []byte(contents), path.Join(VolumePath, name), 0o600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unmount detaches the volume over client, which doesn't have to be the connection it was mounted over
+func (volume *Volume) Unmount(client *gossh.Client) error {
+	var stderr bytes.Buffer
+
+	if err := ssh.Run(client, UnmountVolumeCommand(volume.device), nil, &stderr); err != nil {
+		return fmt.Errorf("failed to detach the secrets volume: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
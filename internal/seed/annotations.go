@@ -0,0 +1,96 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Annotations under which the seed metadata travels with the image in an OCI registry
+const (
+	AnnotationName            = "dev.chamber.seed.name"
+	AnnotationBaseImage       = "dev.chamber.seed.base-image"
+	AnnotationCreated         = "dev.chamber.seed.created"
+	AnnotationAgents          = "dev.chamber.seed.agents"
+	AnnotationTools           = "dev.chamber.seed.tools"
+	AnnotationChamberfileHash = "dev.chamber.seed.chamberfile-hash"
+)
+
+// Annotations returns the metadata as OCI manifest annotations, which only support string values
+func (metadata *Metadata) Annotations() (map[string]string, error) {
+	annotations := map[string]string{
+		AnnotationName:    metadata.Name,
+		AnnotationCreated: metadata.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if metadata.BaseImage != "" {
+		annotations[AnnotationBaseImage] = metadata.BaseImage
+	}
+	if metadata.ChamberfileHash != "" {
+		annotations[AnnotationChamberfileHash] = metadata.ChamberfileHash
+	}
+
+	agents, err := json.Marshal(metadata.Agents)
+	if err != nil {
+		return nil, err
+	}
+	annotations[AnnotationAgents] = string(agents)
+
+	if len(metadata.Tools) != 0 {
+		tools, err := json.Marshal(metadata.Tools)
+		if err != nil {
+			return nil, err
+		}
+		annotations[AnnotationTools] = string(tools)
+	}
+
+	return annotations, nil
+}
+
+// MetadataFromAnnotations restores the seed metadata from OCI manifest annotations,
+// returning an ErrNoMetadata-wrapped error for images that weren't pushed by chamber
+func MetadataFromAnnotations(annotations map[string]string) (*Metadata, error) {
+	name, ok := annotations[AnnotationName]
+	if !ok {
+		return nil, ErrNoMetadata
+	}
+
+	metadata := &Metadata{
+		Name:            name,
+		BaseImage:       annotations[AnnotationBaseImage],
+		ChamberfileHash: annotations[AnnotationChamberfileHash],
+	}
+
+	if created := annotations[AnnotationCreated]; created != "" {
+		createdAt, err := time.Parse(time.RFC3339, created)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationCreated, err)
+		}
+		metadata.CreatedAt = createdAt
+	}
+
+	if agents := annotations[AnnotationAgents]; agents != "" {
+		if err := json.Unmarshal([]byte(agents), &metadata.Agents); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationAgents, err)
+		}
+	}
+
+	if tools := annotations[AnnotationTools]; tools != "" {
+		if err := json.Unmarshal([]byte(tools), &metadata.Tools); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationTools, err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// HasAgent reports whether the agent is installed in the seed
+func (metadata *Metadata) HasAgent(name string) bool {
+	for _, info := range metadata.Agents {
+		if info.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package seed
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnnotations(t *testing.T) {
+	metadata := &Metadata{
+		Name:            "team-seed",
+		BaseImage:       "ghcr.io/cirruslabs/macos-sequoia-base:latest",
+		CreatedAt:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Agents:          []AgentInfo{{Name: "claude", Version: "1.0.0"}},
+		Tools:           map[string]string{"node": "v22.1.0"},
+		ChamberfileHash: "sha256:abcd",
+	}
+
+	annotations, err := metadata.Annotations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range annotations {
+		if value == "" {
+			t.Errorf("annotation %s is empty", key)
+		}
+	}
+
+	restored, err := MetadataFromAnnotations(annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, metadata) {
+		t.Errorf("restored %+v, want %+v", restored, metadata)
+	}
+	if !restored.HasAgent("claude") || restored.HasAgent("codex") {
+		t.Errorf("unexpected agents %+v", restored.Agents)
+	}
+
+	if _, err := MetadataFromAnnotations(map[string]string{"org.opencontainers.image.title": "x"}); !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("expected ErrNoMetadata for foreign images, got %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Severity of an audit finding, a seed with high severity findings fails the audit
+type Severity int
+
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityHigh:
+		return "high"
+	case SeverityMedium:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+func (severity *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "high":
+		*severity = SeverityHigh
+	case "medium":
+		*severity = SeverityMedium
+	case "low":
+		*severity = SeverityLow
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+
+	return nil
+}
+
+// Finding is something sensitive found in the seed, which every agent run would be exposed to
+type Finding struct {
+	Severity    Severity `json:"severity"`
+	Path        string   `json:"path"`
+	Line        int      `json:"line,omitempty"`
+	Description string   `json:"description"`
+}
+
+func (finding Finding) String() string {
+	location := finding.Path
+	if finding.Line != 0 {
+		location = fmt.Sprintf("%s:%d", finding.Path, finding.Line)
+	}
+
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(finding.Severity.String()), location, finding.Description)
+}
+
+// AuditReport is the result of scanning a seed for secrets
+type AuditReport struct {
+	Time     time.Time `json:"time"`
+	Passed   bool      `json:"passed"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Count returns the number of findings with the given severity
+func (report *AuditReport) Count(severity Severity) int {
+	var count int
+
+	for _, finding := range report.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
+
+// AuditCurrent reports whether the seed passed an audit that happened after its last modification
+func (metadata *Metadata) AuditCurrent() error {
+	switch {
+	case metadata.Audit == nil:
+		return fmt.Errorf("seed %s has not been audited", metadata.Name)
+	case metadata.Audit.Time.Before(metadata.UpdatedAt) || metadata.Audit.Time.Before(metadata.CreatedAt):
+		return fmt.Errorf("seed %s has changed since it was last audited", metadata.Name)
+	case !metadata.Audit.Passed:
+		return fmt.Errorf("seed %s failed its last audit with %d high severity finding(s)",
+			metadata.Name, metadata.Audit.Count(SeverityHigh))
+	}
+
+	return nil
+}
+
+// credentialLocation is a well-known place where tools keep credentials,
+// relative to the home directory and possibly containing * wildcards
+type credentialLocation struct {
+	pattern     string
+	severity    Severity
+	description string
+
+	// contains limits the finding to files containing the given string
+	contains string
+
+	// exclude is a pattern for the file names that match but aren't credentials
+	exclude string
+}
+
+var credentialLocations = []credentialLocation{
+	{pattern: ".ssh/id_*", severity: SeverityHigh, description: "SSH private key", exclude: "*.pub"},
+	{pattern: ".netrc", severity: SeverityHigh, description: "netrc credentials"},
+	{pattern: ".git-credentials", severity: SeverityHigh, description: "Git credentials"},
+	{pattern: ".aws/credentials", severity: SeverityHigh, description: "AWS credentials"},
+	{pattern: ".config/gcloud/application_default_credentials.json", severity: SeverityHigh,
+		description: "Google Cloud credentials"},
+	{pattern: ".config/gcloud/credentials.db", severity: SeverityHigh, description: "Google Cloud credentials"},
+	{pattern: ".azure/accessTokens.json", severity: SeverityHigh, description: "Azure access tokens"},
+	{pattern: ".kube/config", severity: SeverityHigh, description: "Kubernetes credentials"},
+	{pattern: ".config/gh/hosts.yml", severity: SeverityHigh, description: "GitHub CLI token", contains: "oauth_token"},
+	{pattern: ".npmrc", severity: SeverityHigh, description: "npm auth token", contains: "_authToken"},
+	{pattern: ".docker/config.json", severity: SeverityMedium, description: "Docker registry credentials",
+		contains: "\"auth\""},
+	{pattern: ".gnupg/private-keys-v1.d/*", severity: SeverityHigh, description: "GnuPG private key"},
+	{pattern: "Library/Cookies/Cookies.binarycookies", severity: SeverityMedium, description: "Safari cookies"},
+	{pattern: "Library/Application Support/Google/Chrome/*/Cookies", severity: SeverityMedium,
+		description: "Chrome cookies"},
+	{pattern: "Library/Application Support/Firefox/Profiles/*/cookies.sqlite", severity: SeverityMedium,
+		description: "Firefox cookies"},
+	{pattern: ".zsh_history", severity: SeverityLow, description: "shell history, which may contain secrets"},
+	{pattern: ".bash_history", severity: SeverityLow, description: "shell history, which may contain secrets"},
+	{pattern: ".claude/.credentials.json", severity: SeverityLow,
+		description: "Claude Code credentials, expected if you authenticated it in the seed"},
+	{pattern: ".codex/auth.json", severity: SeverityLow,
+		description: "Codex credentials, expected if you authenticated it in the seed"},
+}
+
+// Known token formats are reported regardless of their entropy
+var tokenPatterns = []struct {
+	pattern     *regexp.Regexp
+	description string
+}{
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`), "private key"},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), "AWS access key"},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`), "GitHub token"},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), "API key"},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), "Slack token"},
+	{regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`), "Google API key"},
+}
+
+var (
+	tokenCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{24,}`)
+	hasLetter      = regexp.MustCompile(`[A-Za-z]`)
+	hasDigit       = regexp.MustCompile(`[0-9]`)
+)
+
+const (
+	// Random base64 and hex strings have an entropy of about 4-6 bits per character,
+	// while identifiers and words rarely exceed 4
+	minSecretEntropy = 4.0
+
+	// Files larger than this are unlikely to be configuration files
+	maxScannedFileSize = 16 << 10
+	maxScannedFiles    = 500
+
+	// Separates the files in the output of the content scan
+	recordSeparator = "\x1e"
+)
+
+// Audit scans the guest for credentials in well-known locations and for files containing secrets
+func Audit(client *gossh.Client, now time.Time) (*AuditReport, error) {
+	locations, err := ssh.Output(client, "/bin/sh -c "+executor.ShellQuote(locationsScript()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan credential locations: %w", err)
+	}
+
+	contents, err := ssh.Output(client, "/bin/sh -c "+executor.ShellQuote(contentScript()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan files for secrets: %w", err)
+	}
+
+	findings := append(parseLocations(locations), scanContents(contents)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+
+		return findings[i].Path < findings[j].Path
+	})
+
+	report := &AuditReport{Time: now.UTC(), Findings: findings}
+	report.Passed = report.Count(SeverityHigh) == 0
+
+	return report, nil
+}
+
+// locationsScript prints "<index>\t<path>" for each existing credential location
+func locationsScript() string {
+	var lines []string
+
+	for i, location := range credentialLocations {
+		test := `[ -s "$f" ]`
+		if location.contains != "" {
+			test += " && grep -q " + executor.ShellQuote(location.contains) + ` "$f"`
+		}
+
+		if location.exclude != "" {
+			test = fmt.Sprintf(`case "${f##*/}" in %s) false ;; esac && `, location.exclude) + test
+		}
+
+		lines = append(lines, fmt.Sprintf(`for f in %s; do [ -f "$f" ] && %s && printf '%d\t%%s\n' "$f"; done`,
+			homeGlob(location.pattern), test, i))
+	}
+
+	return strings.Join(append(lines, "true"), "\n")
+}
+
+// homeGlob quotes the pattern relative to $HOME, leaving the wildcards to the shell
+func homeGlob(pattern string) string {
+	parts := strings.Split(pattern, "*")
+
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = "'" + strings.ReplaceAll(part, "'", `'\''`) + "'"
+		}
+	}
+
+	return `"$HOME"/` + strings.Join(parts, "*")
+}
+
+func parseLocations(output string) []Finding {
+	var findings []Finding
+
+	for _, line := range strings.Split(output, "\n") {
+		index, file, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+
+		var i int
+		if _, err := fmt.Sscanf(index, "%d", &i); err != nil || i < 0 || i >= len(credentialLocations) {
+			continue
+		}
+
+		if exclude := credentialLocations[i].exclude; exclude != "" {
+			if excluded, _ := path.Match(exclude, path.Base(file)); excluded {
+				continue
+			}
+		}
+
+		findings = append(findings, Finding{
+			Severity:    credentialLocations[i].severity,
+			Path:        file,
+			Description: credentialLocations[i].description,
+		})
+	}
+
+	return findings
+}
+
+// contentScript prints the contents of small configuration-like files in the home directory,
+// each preceded by a record separator and the file's path
+func contentScript() string {
+	return fmt.Sprintf(`find "$HOME" -maxdepth 4 \
+  \( -name Library -o -name node_modules -o -name .npm -o -name .cache -o -name .Trash -o -name .git \) -prune -o \
+  -type f -size -%dk \( -name '.*rc' -o -name '.*profile' -o -name '.env*' -o -name '*.env' \
+  -o -name '*.pem' -o -name '*.key' -o -name '*credential*' -o -name '*secret*' -o -name '*token*' \
+  -o -name '*.json' -o -name '*.yml' -o -name '*.yaml' -o -name '*.toml' -o -name '*.ini' -o -name '*.conf' \
+  -o -name '*.cfg' -o -name '*.properties' \) -print 2>/dev/null | head -n %d | while IFS= read -r f; do
+  printf '%s%%s\n' "$f"
+  cat "$f" 2>/dev/null
+done
+true`, maxScannedFileSize>>10, maxScannedFiles, recordSeparator)
+}
+
+func scanContents(output string) []Finding {
+	var findings []Finding
+
+	for _, record := range strings.Split(output, recordSeparator)[1:] {
+		path, contents, _ := strings.Cut(record, "\n")
+
+		// Skip binary files and lock files full of checksums
+		if strings.Contains(contents, "\x00") || strings.HasSuffix(path, "-lock.json") {
+			continue
+		}
+
+		findings = append(findings, scanFile(path, contents)...)
+	}
+
+	return findings
+}
+
+// scanFile reports at most one finding per line, without including the secret itself
+func scanFile(path string, contents string) []Finding {
+	var findings []Finding
+
+	for i, line := range strings.Split(contents, "\n") {
+		if finding, ok := scanLine(line); ok {
+			finding.Path = path
+			finding.Line = i + 1
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func scanLine(line string) (Finding, bool) {
+	for _, token := range tokenPatterns {
+		if token.pattern.MatchString(line) {
+			return Finding{Severity: SeverityHigh, Description: token.description}, true
+		}
+	}
+
+	for _, candidate := range tokenCandidate.FindAllString(line, -1) {
+		// Paths and identifiers rarely contain both letters and digits
+		// in a random-looking mix, secrets almost always do
+		if !hasLetter.MatchString(candidate) || !hasDigit.MatchString(candidate) || strings.Count(candidate, "/") > 2 {
+			continue
+		}
+
+		if entropy(candidate) >= minSecretEntropy {
+			return Finding{Severity: SeverityMedium, Description: "high-entropy string, possibly a secret"}, true
+		}
+	}
+
+	return Finding{}, false
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var result float64
+	length := float64(len(s))
+
+	for _, count := range counts {
+		p := float64(count) / length
+		result -= p * math.Log2(p)
+	}
+
+	return result
+}
+
+// FormatReport renders the findings for humans
+func FormatReport(report *AuditReport) string {
+	var buf bytes.Buffer
+
+	for _, finding := range report.Findings {
+		fmt.Fprintln(&buf, finding)
+	}
+
+	fmt.Fprintf(&buf, "%d high, %d medium, %d low severity finding(s)\n",
+		report.Count(SeverityHigh), report.Count(SeverityMedium), report.Count(SeverityLow))
+
+	return buf.String()
+}
@@ -0,0 +1,113 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// BootedVM is a seed or build VM that's running and accepts SSH connections
+type BootedVM struct {
+	Client *gossh.Client
+
+	vm        *tart.VM
+	ephemeral bool
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	stop      func()
+	shutDown  bool
+}
+
+// Boot starts the VM in place, without cloning it, and connects to it via SSH
+func Boot(ctx context.Context, vmName string, sshUser string, sshPass string, out io.Writer) (*BootedVM, error) {
+	vm, err := tart.NewVM(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "Starting %s...\n", vmName)
+
+	return boot(ctx, vm, false, sshUser, sshPass)
+}
+
+// BootEphemeral starts an ephemeral clone of the VM, which leaves the VM itself untouched.
+// The clone is deleted on shutdown.
+func BootEphemeral(ctx context.Context, vmName string, sshUser string, sshPass string, out io.Writer) (*BootedVM, error) {
+	fmt.Fprintf(out, "Starting an ephemeral clone of %s...\n", vmName)
+
+	vm, err := tart.NewVMClonedFrom(ctx, vmName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return boot(ctx, vm, true, sshUser, sshPass)
+}
+
+func boot(ctx context.Context, vm *tart.VM, ephemeral bool, sshUser string, sshPass string) (*BootedVM, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	vm.Start(ctx, nil)
+
+	booted := &BootedVM{
+		vm:        vm,
+		ephemeral: ephemeral,
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      vm.Monitor(cancel),
+	}
+
+	ip, err := vm.RetrieveIP(ctx)
+	if err != nil {
+		err = booted.Failure(fmt.Errorf("failed to get VM IP: %w", err))
+		booted.Shutdown()
+
+		return nil, err
+	}
+
+	booted.Client, err = ssh.WaitForSSH(ctx, fmt.Sprintf("%s:22", ip), sshUser, sshPass)
+	if err != nil {
+		err = booted.Failure(fmt.Errorf("failed to connect via SSH: %w", err))
+		booted.Shutdown()
+
+		return nil, err
+	}
+
+	return booted, nil
+}
+
+// Shutdown flushes the guest's file system buffers and stops the VM (deleting it if it's ephemeral),
+// it's safe to call multiple times
+func (booted *BootedVM) Shutdown() {
+	if booted.shutDown {
+		return
+	}
+	booted.shutDown = true
+
+	booted.stop()
+
+	if booted.Client != nil {
+		_ = ssh.Run(booted.Client, "sync", io.Discard, io.Discard)
+		_ = booted.Client.Close()
+	}
+
+	_ = booted.vm.StopWithContext(context.Background())
+	booted.cancel(nil)
+
+	if booted.ephemeral {
+		_ = booted.vm.Delete()
+	}
+}
+
+// Failure replaces err with the reason of the VM's exit if it exited unexpectedly
+func (booted *BootedVM) Failure(err error) error {
+	if cause := context.Cause(booted.ctx); errors.Is(cause, tart.ErrVMExited) {
+		return cause
+	}
+
+	return err
+}
@@ -0,0 +1,301 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	"github.com/cirruslabs/chamber/internal/transfer"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const buildVMPrefix = "chamber-build-"
+
+// Builder provisions a seed VM from a Chamberfile
+type Builder struct {
+	chamberfile *Chamberfile
+	dir         string
+	name        string
+	registry    *agent.Registry
+	store       *Store
+	layers      *LayerIndex
+	out         io.Writer
+	sshUser     string
+	sshPass     string
+}
+
+// NewBuilder creates a builder for the Chamberfile located in dir, which produces a seed with the given name.
+// Progress and the output of each step are written to out.
+func NewBuilder(
+	chamberfile *Chamberfile,
+	dir string,
+	name string,
+	registry *agent.Registry,
+	store *Store,
+	out io.Writer,
+) *Builder {
+	return &Builder{
+		chamberfile: chamberfile,
+		dir:         dir,
+		name:        name,
+		registry:    registry,
+		store:       store,
+		out:         out,
+		sshUser:     "admin",
+		sshPass:     "admin",
+	}
+}
+
+// UseLayerCache enables caching the result of each step as an intermediate VM,
+// so that a rebuild only re-applies the steps after the deepest unchanged layer
+func (builder *Builder) UseLayerCache(layers *LayerIndex) {
+	builder.layers = layers
+}
+
+// Build applies all the steps to a fresh clone of the base image and saves the result as the seed
+func (builder *Builder) Build(ctx context.Context) (*Metadata, error) {
+	// Fail early on unknown agents instead of after a lengthy clone
+	for _, step := range builder.chamberfile.Steps {
+		if step.Agent != "" {
+			if _, ok := builder.registry.Get(step.Agent); !ok {
+				return nil, fmt.Errorf("%w: unknown agent %q", ErrInvalidChamberfile, step.Agent)
+			}
+		}
+	}
+
+	metadata := &Metadata{
+		Name:            builder.name,
+		BaseImage:       builder.chamberfile.Base,
+		CreatedAt:       time.Now().UTC(),
+		ChamberfileHash: builder.chamberfile.Hash,
+	}
+
+	source := builder.chamberfile.Base
+	start := 0
+
+	var keys []string
+
+	if builder.layers != nil {
+		baseDigest, err := tart.ResolveDigest(ctx, builder.chamberfile.Base)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err = LayerKeys(baseDigest, builder.chamberfile.Steps, builder.dir, builder.registry)
+		if err != nil {
+			return nil, err
+		}
+
+		if layer, depth := builder.deepestCachedLayer(ctx, keys); layer != nil {
+			builder.logf("Reusing cached layers for steps 1-%d\n", depth)
+
+			source = layer.VMName()
+			start = depth
+			metadata.Agents = append(metadata.Agents, layer.Agents...)
+			metadata.Tools = layer.Tools
+		}
+	}
+
+	buildVM := buildVMPrefix + time.Now().Format("20060102-150405")
+
+	builder.logf("Cloning %s to %s...\n", source, buildVM)
+	if err := tart.CloneVM(ctx, source, buildVM); err != nil {
+		return nil, err
+	}
+
+	if err := builder.provision(ctx, buildVM, keys, start, metadata); err != nil {
+		builder.logf("Build failed, deleting %s...\n", buildVM)
+		_ = tart.DeleteVM(context.Background(), buildVM)
+
+		return nil, err
+	}
+
+	if err := builder.replaceSeed(ctx, buildVM); err != nil {
+		_ = tart.DeleteVM(context.Background(), buildVM)
+
+		return nil, err
+	}
+
+	if err := builder.store.Save(metadata); err != nil {
+		return nil, err
+	}
+
+	if builder.layers != nil {
+		builder.layers.RecordBuild(builder.name, keys, time.Now().UTC())
+
+		if err := builder.layers.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	builder.logf("Seed %s is ready\n", builder.name)
+
+	return metadata, nil
+}
+
+// deepestCachedLayer returns the cached layer that covers the most steps and how many steps it covers
+func (builder *Builder) deepestCachedLayer(ctx context.Context, keys []string) (*Layer, int) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		layer, ok := builder.layers.Get(keys[i])
+		if !ok {
+			continue
+		}
+
+		// The index might be out of sync with Tart, e.g. if the user deleted the VM
+		if exists, err := tart.Exists(ctx, layer.VMName()); err != nil || !exists {
+			builder.layers.Remove(layer.Key)
+
+			continue
+		}
+
+		return layer, i + 1
+	}
+
+	return nil, 0
+}
+
+func (builder *Builder) provision(ctx context.Context, vmName string, keys []string, start int, metadata *Metadata) error {
+	steps := builder.chamberfile.Steps
+	if start == len(steps) {
+		return nil
+	}
+
+	booted, err := Boot(ctx, vmName, builder.sshUser, builder.sshPass, builder.out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		booted.Shutdown()
+	}()
+
+	for i := start; i < len(steps); i++ {
+		step := steps[i]
+
+		builder.logf("\n==> Step %d/%d: %s\n", i+1, len(steps), step.Description())
+		started := time.Now()
+
+		if err := builder.applyStep(booted.Client, step, metadata); err != nil {
+			return booted.Failure(fmt.Errorf("step %d (%s) failed: %w", i+1, step.Description(), err))
+		}
+
+		builder.logf("==> Step %d/%d done in %s\n", i+1, len(steps), time.Since(started).Round(time.Millisecond))
+
+		if i == len(steps)-1 {
+			tools, err := DetectTools(booted.Client)
+			if err != nil {
+				builder.logf("Warning: %v\n", err)
+			}
+
+			metadata.Tools = tools
+		}
+
+		if builder.layers == nil {
+			continue
+		}
+
+		// Snapshot the result, which requires the VM to be stopped for the clone to be consistent
+		booted.Shutdown()
+
+		if err := builder.cacheLayer(ctx, vmName, keys, i, metadata); err != nil {
+			return err
+		}
+
+		if i+1 < len(steps) {
+			next, err := Boot(ctx, vmName, builder.sshUser, builder.sshPass, builder.out)
+			if err != nil {
+				return err
+			}
+
+			booted = next
+		}
+	}
+
+	return nil
+}
+
+func (builder *Builder) cacheLayer(ctx context.Context, vmName string, keys []string, i int, metadata *Metadata) error {
+	now := time.Now().UTC()
+
+	layer := &Layer{
+		Key:       keys[i],
+		Step:      builder.chamberfile.Steps[i].Description(),
+		CreatedAt: now,
+		LastUsed:  now,
+		Agents:    append([]AgentInfo(nil), metadata.Agents...),
+		Tools:     metadata.Tools,
+	}
+	if i > 0 {
+		layer.Parent = keys[i-1]
+	}
+
+	// A stale layer VM with the same name might be left over from an interrupted build
+	_ = tart.DeleteVM(ctx, layer.VMName())
+
+	builder.logf("Caching layer %s...\n", layer.VMName())
+	if err := tart.CloneVM(ctx, vmName, layer.VMName()); err != nil {
+		return err
+	}
+
+	builder.layers.Add(layer)
+
+	// Save right away, so that the layer is reused even if a later step fails
+	return builder.layers.Save()
+}
+
+func (builder *Builder) applyStep(client *gossh.Client, step Step, metadata *Metadata) error {
+	switch {
+	case step.Copy != nil:
+		src := step.Copy.Src
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(builder.dir, src)
+		}
+
+		return transfer.Upload(client, src, step.Copy.Dst)
+	case step.Agent != "":
+		definition, _ := builder.registry.Get(step.Agent)
+
+		if err := agent.Install(client, definition, builder.out, builder.out); err != nil {
+			return err
+		}
+
+		version, err := agent.DetectVersion(client, definition)
+		if err != nil {
+			return err
+		}
+
+		builder.logf("%s %s installed\n", definition.Name, version)
+		metadata.SetAgent(AgentInfo{Name: definition.Name, Version: version})
+
+		return nil
+	default:
+		// Stop at the first failing command of a multi-line step
+		return ssh.Run(client, executor.LoginShell("set -e\n"+step.ShellCommand()), builder.out, builder.out)
+	}
+}
+
+func (builder *Builder) replaceSeed(ctx context.Context, buildVM string) error {
+	exists, err := tart.Exists(ctx, builder.name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		builder.logf("Replacing the existing %s...\n", builder.name)
+
+		if err := tart.DeleteVM(ctx, builder.name); err != nil {
+			return err
+		}
+	}
+
+	return tart.RenameVM(ctx, buildVM, builder.name)
+}
+
+func (builder *Builder) logf(format string, args ...any) {
+	fmt.Fprintf(builder.out, format, args...)
+}
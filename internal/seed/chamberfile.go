@@ -0,0 +1,154 @@
+package seed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidChamberfile = errors.New("invalid Chamberfile")
+
+// Chamberfile declares how to provision a seed VM from a base image
+type Chamberfile struct {
+	// Base is the Tart VM or OCI image to start from
+	Base string `yaml:"base"`
+
+	// Name of the resulting seed, can be overridden on the command line
+	Name string `yaml:"name,omitempty"`
+
+	// Steps are applied in order over SSH
+	Steps []Step `yaml:"steps"`
+
+	// Hash identifies the Chamberfile contents
+	Hash string `yaml:"-"`
+}
+
+// Step is a single provisioning step, exactly one of its fields must be set
+type Step struct {
+	// Run executes a shell command in the guest's login shell
+	Run string `yaml:"run,omitempty"`
+
+	// Copy uploads a host file or directory to the guest
+	Copy *CopyStep `yaml:"copy,omitempty"`
+
+	// Brew installs Homebrew packages
+	Brew []string `yaml:"brew,omitempty"`
+
+	// NPM installs global npm packages
+	NPM []string `yaml:"npm,omitempty"`
+
+	// Agent installs a registered agent
+	Agent string `yaml:"agent,omitempty"`
+}
+
+// CopyStep uploads Src, which is relative to the Chamberfile, to Dst in the guest
+type CopyStep struct {
+	Src string `yaml:"src"`
+	Dst string `yaml:"dst"`
+}
+
+// LoadChamberfile reads and validates a Chamberfile
+func LoadChamberfile(path string) (*Chamberfile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Chamberfile: %w", err)
+	}
+
+	return ParseChamberfile(contents)
+}
+
+// ParseChamberfile parses and validates the Chamberfile contents
+func ParseChamberfile(contents []byte) (*Chamberfile, error) {
+	var chamberfile Chamberfile
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&chamberfile); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChamberfile, err)
+	}
+
+	if chamberfile.Base == "" {
+		return nil, fmt.Errorf("%w: base image is not specified", ErrInvalidChamberfile)
+	}
+
+	for i, step := range chamberfile.Steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("%w: step %d: %v", ErrInvalidChamberfile, i+1, err)
+		}
+	}
+
+	sum := sha256.Sum256(contents)
+	chamberfile.Hash = hex.EncodeToString(sum[:])
+
+	return &chamberfile, nil
+}
+
+func (step *Step) validate() error {
+	var set int
+
+	if step.Run != "" {
+		set++
+	}
+	if step.Copy != nil {
+		set++
+
+		if step.Copy.Src == "" || step.Copy.Dst == "" {
+			return errors.New("copy requires both src and dst")
+		}
+	}
+	if len(step.Brew) != 0 {
+		set++
+	}
+	if len(step.NPM) != 0 {
+		set++
+	}
+	if step.Agent != "" {
+		set++
+	}
+
+	if set != 1 {
+		return errors.New("exactly one of run, copy, brew, npm or agent must be specified")
+	}
+
+	return nil
+}
+
+// Description returns a short human-readable description of the step
+func (step *Step) Description() string {
+	switch {
+	case step.Run != "":
+		return "run: " + step.Run
+	case step.Copy != nil:
+		return fmt.Sprintf("copy: %s -> %s", step.Copy.Src, step.Copy.Dst)
+	case len(step.Brew) != 0:
+		return "brew: " + strings.Join(step.Brew, " ")
+	case len(step.NPM) != 0:
+		return "npm: " + strings.Join(step.NPM, " ")
+	default:
+		return "agent: " + step.Agent
+	}
+}
+
+// ShellCommand returns the guest shell command for the steps that are plain commands,
+// or an empty string for steps that need special handling (copy and agent)
+func (step *Step) ShellCommand() string {
+	switch {
+	case step.Run != "":
+		return step.Run
+	case len(step.Brew) != 0:
+		// Don't let Homebrew update itself in the middle of a build to keep it reproducible
+		return "HOMEBREW_NO_AUTO_UPDATE=1 brew install " + executor.ShellJoin(step.Brew)
+	case len(step.NPM) != 0:
+		return "npm install -g " + executor.ShellJoin(step.NPM)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,69 @@
+package seed
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseChamberfile(t *testing.T) {
+	chamberfile, err := ParseChamberfile([]byte(`base: ghcr.io/cirruslabs/macos-sequoia-base:latest
+name: ios-seed
+steps:
+  - run: echo hello
+  - copy:
+      src: dotfiles/.zshrc
+      dst: ~/.zshrc
+  - brew: [go, jq]
+  - npm: ["@biomejs/biome"]
+  - agent: claude
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chamberfile.Name != "ios-seed" || len(chamberfile.Steps) != 5 {
+		t.Fatalf("unexpected Chamberfile: %+v", chamberfile)
+	}
+	if len(chamberfile.Hash) != 64 {
+		t.Errorf("expected a SHA-256 hash, got %q", chamberfile.Hash)
+	}
+
+	expected := []struct {
+		description string
+		command     string
+	}{
+		{"run: echo hello", "echo hello"},
+		{"copy: dotfiles/.zshrc -> ~/.zshrc", ""},
+		{"brew: go jq", "HOMEBREW_NO_AUTO_UPDATE=1 brew install go jq"},
+		{"npm: @biomejs/biome", "npm install -g @biomejs/biome"},
+		{"agent: claude", ""},
+	}
+
+	for i, step := range chamberfile.Steps {
+		if step.Description() != expected[i].description {
+			t.Errorf("step %d: description %q, want %q", i+1, step.Description(), expected[i].description)
+		}
+		if step.ShellCommand() != expected[i].command {
+			t.Errorf("step %d: command %q, want %q", i+1, step.ShellCommand(), expected[i].command)
+		}
+	}
+}
+
+func TestParseChamberfileInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing base":        "steps:\n  - run: echo\n",
+		"empty step":          "base: x\nsteps:\n  - {}\n",
+		"ambiguous step":      "base: x\nsteps:\n  - run: echo\n    agent: claude\n",
+		"incomplete copy":     "base: x\nsteps:\n  - copy:\n      src: a\n",
+		"unknown field":       "base: x\nstepz: []\n",
+		"unknown step action": "base: x\nsteps:\n  - apt: [git]\n",
+	}
+
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseChamberfile([]byte(contents)); !errors.Is(err, ErrInvalidChamberfile) {
+				t.Errorf("expected ErrInvalidChamberfile, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package seed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+)
+
+// LayerVMPrefix is the name prefix of the Tart VMs holding cached build layers
+const LayerVMPrefix = "chamber-layer-"
+
+// Layer is the cached result of applying a Chamberfile step
+type Layer struct {
+	Key       string            `json:"key"`
+	Parent    string            `json:"parent,omitempty"`
+	Step      string            `json:"step"`
+	CreatedAt time.Time         `json:"created_at"`
+	LastUsed  time.Time         `json:"last_used"`
+	Agents    []AgentInfo       `json:"agents,omitempty"`
+	Tools     map[string]string `json:"tools,omitempty"`
+}
+
+// VMName returns the name of the Tart VM holding the layer
+func (layer *Layer) VMName() string {
+	return LayerVMName(layer.Key)
+}
+
+// LayerVMName returns the name of the Tart VM for the layer key
+func LayerVMName(key string) string {
+	return LayerVMPrefix + key[:16]
+}
+
+// LayerIndex tracks the cached layers and which of them are used by the latest build of each seed
+type LayerIndex struct {
+	path string
+
+	Layers map[string]*Layer `json:"layers"`
+
+	// Builds maps seed names to the layer keys of their latest build
+	Builds map[string][]string `json:"builds"`
+}
+
+// LoadLayerIndex reads the layer index, returning an empty index if it doesn't exist yet
+func LoadLayerIndex(path string) (*LayerIndex, error) {
+	index := &LayerIndex{
+		path:   path,
+		Layers: map[string]*Layer{},
+		Builds: map[string][]string{},
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return index, nil
+		}
+
+		return nil, fmt.Errorf("failed to read layer index: %w", err)
+	}
+
+	if err := json.Unmarshal(contents, index); err != nil {
+		return nil, fmt.Errorf("failed to parse layer index: %w", err)
+	}
+
+	return index, nil
+}
+
+// Save writes the layer index back to disk
+func (index *LayerIndex) Save() error {
+	if err := os.MkdirAll(filepath.Dir(index.path), 0o700); err != nil {
+		return fmt.Errorf("failed to save layer index: %w", err)
+	}
+
+	contents, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(index.path, append(contents, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to save layer index: %w", err)
+	}
+
+	return nil
+}
+
+// Add records a newly cached layer
+func (index *LayerIndex) Add(layer *Layer) {
+	index.Layers[layer.Key] = layer
+}
+
+// Get returns the cached layer with the given key
+func (index *LayerIndex) Get(key string) (*Layer, bool) {
+	layer, ok := index.Layers[key]
+
+	return layer, ok
+}
+
+// RecordBuild remembers the layers used by the seed's latest build, which protects them from pruning
+func (index *LayerIndex) RecordBuild(seedName string, keys []string, now time.Time) {
+	index.Builds[seedName] = keys
+
+	for _, key := range keys {
+		if layer, ok := index.Layers[key]; ok {
+			layer.LastUsed = now
+		}
+	}
+}
+
+// ForgetBuild releases the layers used by the seed's latest build, e.g. when the seed is removed
+func (index *LayerIndex) ForgetBuild(seedName string) {
+	delete(index.Builds, seedName)
+}
+
+// Remove forgets about the layer
+func (index *LayerIndex) Remove(key string) {
+	delete(index.Layers, key)
+}
+
+// Stale returns the layers that aren't used by the latest build of any seed
+func (index *LayerIndex) Stale() []*Layer {
+	used := map[string]bool{}
+
+	for _, keys := range index.Builds {
+		for _, key := range keys {
+			used[key] = true
+		}
+	}
+
+	var result []*Layer
+
+	for key, layer := range index.Layers {
+		if !used[key] {
+			result = append(result, layer)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+// LayerKeys computes the cache key of each step's result. Every key covers the base image
+// digest and all preceding steps, including the contents of copied files and the install
+// commands of agents, so that changing a step invalidates its layer and all layers after it.
+func LayerKeys(baseDigest string, steps []Step, dir string, registry *agent.Registry) ([]string, error) {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "base:%s\n", baseDigest)
+
+	var keys []string
+
+	for _, step := range steps {
+		serialized, err := json.Marshal(step)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(hash, "step:%s\n", serialized)
+
+		if step.Copy != nil {
+			src := step.Copy.Src
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(dir, src)
+			}
+
+			if err := hashPath(hash, src); err != nil {
+				return nil, fmt.Errorf("failed to hash %s: %w", step.Copy.Src, err)
+			}
+		}
+
+		// The step only names the agent, what gets installed is up to its definition
+		if step.Agent != "" {
+			definition, ok := registry.Get(step.Agent)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown agent %q", ErrInvalidChamberfile, step.Agent)
+			}
+
+			fmt.Fprintf(hash, "install:%q\n", definition.Install)
+		}
+
+		keys = append(keys, hex.EncodeToString(hash.Sum(nil)))
+	}
+
+	return keys, nil
+}
+
+func hashPath(w io.Writer, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "file:%s:%s\n", filepath.ToSlash(relPath), info.Mode())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "link:%s\n", target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if _, err := io.Copy(w, file); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// IsLayerVM reports whether the VM name belongs to a cached build layer
+func IsLayerVM(name string) bool {
+	return strings.HasPrefix(name, LayerVMPrefix)
+}
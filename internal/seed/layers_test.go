@@ -0,0 +1,146 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+)
+
+func TestLayerKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	registry, err := agent.NewRegistry(filepath.Join(dir, "agents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".zshrc"), []byte("export A=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []Step{
+		{Brew: []string{"go"}},
+		{Copy: &CopyStep{Src: ".zshrc", Dst: "~/.zshrc"}},
+		{Agent: "claude"},
+	}
+
+	keys, err := LayerKeys("sha256:base", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+
+	// Keys are stable
+	again, err := LayerKeys("sha256:base", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range keys {
+		if keys[i] != again[i] {
+			t.Fatalf("key %d is not stable", i)
+		}
+	}
+
+	// Changing the last step only invalidates the last layer
+	changedLast := append(append([]Step(nil), steps[:2]...), Step{Agent: "codex"})
+	changed, err := LayerKeys("sha256:base", changedLast, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0] != keys[0] || changed[1] != keys[1] || changed[2] == keys[2] {
+		t.Errorf("only the last key should change: %v vs %v", keys, changed)
+	}
+
+	// Changing the contents of a copied file invalidates its layer and all layers after it
+	if err := os.WriteFile(filepath.Join(dir, ".zshrc"), []byte("export A=2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = LayerKeys("sha256:base", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0] != keys[0] || changed[1] == keys[1] || changed[2] == keys[2] {
+		t.Errorf("keys starting from the copy step should change: %v vs %v", keys, changed)
+	}
+
+	// Changing the base image invalidates everything
+	changed, err = LayerKeys("sha256:other", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0] == keys[0] {
+		t.Error("a different base image should change all keys")
+	}
+
+	// Changing how an agent is installed invalidates its layer
+	keys, err = LayerKeys("sha256:base", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "agents"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "agents", "claude.yaml"),
+		[]byte("name: claude\nbinary: claude\ninstall: npm install -g @anthropic-ai/claude-code@1.0.0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err = agent.NewRegistry(filepath.Join(dir, "agents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err = LayerKeys("sha256:base", steps, dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0] != keys[0] || changed[1] != keys[1] || changed[2] == keys[2] {
+		t.Errorf("only the agent's key should change: %v vs %v", keys, changed)
+	}
+}
+
+func TestLayerIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layers.json")
+
+	index, err := LoadLayerIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, key := range []string{"aaaaaaaaaaaaaaaa1", "bbbbbbbbbbbbbbbb2", "cccccccccccccccc3"} {
+		index.Add(&Layer{Key: key, Step: key, CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	index.RecordBuild("chamber-seed", []string{"aaaaaaaaaaaaaaaa1", "cccccccccccccccc3"}, now)
+
+	if err := index.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadLayerIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := loaded.Stale()
+	if len(stale) != 1 || stale[0].Key != "bbbbbbbbbbbbbbbb2" {
+		t.Fatalf("unexpected stale layers: %+v", stale)
+	}
+	if stale[0].VMName() != "chamber-layer-bbbbbbbbbbbbbbbb" {
+		t.Errorf("unexpected layer VM name %q", stale[0].VMName())
+	}
+
+	layer, ok := loaded.Get("aaaaaaaaaaaaaaaa1")
+	if !ok || !layer.LastUsed.Equal(now) {
+		t.Errorf("expected the used layer to be touched: %+v", layer)
+	}
+
+	// A rebuild with different steps makes the old layers stale
+	loaded.RecordBuild("chamber-seed", []string{"bbbbbbbbbbbbbbbb2"}, now)
+	if len(loaded.Stale()) != 2 {
+		t.Errorf("expected 2 stale layers after a rebuild, got %d", len(loaded.Stale()))
+	}
+}
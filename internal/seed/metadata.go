@@ -0,0 +1,158 @@
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/config"
+)
+
+// DefaultName is the seed used when neither the command line nor the configuration names one
+const DefaultName = "chamber-seed"
+
+var ErrNoMetadata = errors.New("seed has no chamber metadata")
+
+// AgentInfo records an agent installed in the seed
+type AgentInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Metadata describes how a seed VM was created and what it contains
+type Metadata struct {
+	Name      string      `json:"name"`
+	BaseImage string      `json:"base_image,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at,omitempty"`
+	Agents    []AgentInfo `json:"agents,omitempty"`
+
+	// Tools maps well-known tools like git or node to their versions in the seed
+	Tools map[string]string `json:"tools,omitempty"`
+
+	// ChamberfileHash identifies the Chamberfile the seed was built from, if any
+	ChamberfileHash string `json:"chamberfile_hash,omitempty"`
+
+	// Source and Digest identify the registry image the seed was pulled from or pushed to, if any
+	Source string `json:"source,omitempty"`
+	Digest string `json:"digest,omitempty"`
+
+	// Disk fingerprints the seed's disk as it was when pulled or pushed, see tart.ResolveDigest,
+	// so that a seed changed since no longer passes for the image it came from
+	Disk string `json:"disk,omitempty"`
+
+	// Audit is the result of the last "chamber seed audit"
+	Audit *AuditReport `json:"audit,omitempty"`
+}
+
+// SetAgent records the agent, replacing a previous record with the same name
+func (metadata *Metadata) SetAgent(info AgentInfo) {
+	for i := range metadata.Agents {
+		if metadata.Agents[i].Name == info.Name {
+			metadata.Agents[i] = info
+
+			return
+		}
+	}
+
+	metadata.Agents = append(metadata.Agents, info)
+}
+
+// Store keeps seed metadata as JSON files on the host, since Tart has no place for it
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store located in ~/.config/chamber/seeds
+func DefaultStore() (*Store, error) {
+	dir, err := config.Path("seeds")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(dir), nil
+}
+
+// Load returns the metadata for the given seed or an ErrNoMetadata-wrapped error
+func (store *Store) Load(name string) (*Metadata, error) {
+	contents, err := os.ReadFile(store.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNoMetadata, name)
+		}
+
+		return nil, fmt.Errorf("failed to read seed metadata: %w", err)
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(contents, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse seed metadata for %s: %w", name, err)
+	}
+
+	return &metadata, nil
+}
+
+// Save writes the seed metadata, replacing any previous version
+func (store *Store) Save(metadata *Metadata) error {
+	if err := config.WriteJSON(store.path(metadata.Name), metadata); err != nil {
+		return fmt.Errorf("failed to save seed metadata: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the metadata of all seeds sorted by name
+func (store *Store) List() ([]*Metadata, error) {
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to list seed metadata: %w", err)
+	}
+
+	var result []*Metadata
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		metadata, err := store.Load(name)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, metadata)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Delete removes the metadata for the given seed, if any
+func (store *Store) Delete(name string) error {
+	if err := os.Remove(store.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete seed metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (store *Store) path(name string) string {
+	return filepath.Join(store.dir, name+".json")
+}
@@ -0,0 +1,62 @@
+package seed
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if _, err := store.Load("chamber-seed"); !errors.Is(err, ErrNoMetadata) {
+		t.Fatalf("expected ErrNoMetadata, got %v", err)
+	}
+
+	metadata := &Metadata{
+		Name:      "chamber-seed",
+		BaseImage: "ghcr.io/cirruslabs/macos-sequoia-base:latest",
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	metadata.SetAgent(AgentInfo{Name: "claude", Version: "1.0.0"})
+	metadata.SetAgent(AgentInfo{Name: "codex", Version: "0.1.0"})
+	metadata.SetAgent(AgentInfo{Name: "claude", Version: "1.0.1"})
+
+	if err := store.Save(metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load("chamber-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, metadata) {
+		t.Errorf("loaded %+v, want %+v", loaded, metadata)
+	}
+	if len(loaded.Agents) != 2 || loaded.Agents[0].Version != "1.0.1" {
+		t.Errorf("SetAgent should replace existing records: %+v", loaded.Agents)
+	}
+
+	if err := store.Save(&Metadata{Name: "xcode"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Name != "chamber-seed" || all[1].Name != "xcode" {
+		t.Errorf("unexpected seed list: %+v", all)
+	}
+
+	if err := store.Delete("chamber-seed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("chamber-seed"); err != nil {
+		t.Fatalf("deleting missing metadata should succeed: %v", err)
+	}
+	if _, err := store.Load("chamber-seed"); !errors.Is(err, ErrNoMetadata) {
+		t.Fatalf("expected ErrNoMetadata after deletion, got %v", err)
+	}
+}
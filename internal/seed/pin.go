@@ -0,0 +1,111 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/registry"
+)
+
+var (
+	ErrInvalidPin     = errors.New("invalid seed pin")
+	ErrDigestMismatch = errors.New("seed doesn't match the pinned digest")
+
+	digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Pin is a seed reference from the configuration, optionally pinned to the digest of the image
+// the seed was pulled from, e.g. "ghcr.io/myteam/seed@sha256:..." or "team-seed@sha256:..."
+type Pin struct {
+	// Name of the local seed VM
+	Name string
+
+	// Source is the registry reference to pull the pinned image from, if known
+	Source string
+
+	// Digest the local seed must have been pulled from, empty if the seed is not pinned
+	Digest string
+}
+
+// ParsePin parses a seed reference. Registry references are stored locally under
+// the last component of the repository, just like "chamber seed pull" does.
+func ParsePin(value string) (Pin, error) {
+	name, digest, pinned := strings.Cut(value, "@")
+	if !pinned {
+		return Pin{Name: value}, nil
+	}
+
+	if !digestPattern.MatchString(digest) {
+		return Pin{}, fmt.Errorf("%w: %q, expected a sha256 digest like name@sha256:<64 hex digits>",
+			ErrInvalidPin, value)
+	}
+
+	if !strings.Contains(name, "/") {
+		return Pin{Name: name, Digest: digest}, nil
+	}
+
+	// Without a registry, e.g. "myteam/seed@sha256:...", the seed can only be verified
+	// and pulled from the registry the local seed was pulled from
+	if host, _, _ := strings.Cut(name, "/"); !registry.IsHost(host) {
+		repository, _, _ := strings.Cut(path.Base(name), ":")
+
+		return Pin{Name: repository, Digest: digest}, nil
+	}
+
+	ref, err := registry.ParseReference(value)
+	if err != nil {
+		return Pin{}, fmt.Errorf("%w: %v", ErrInvalidPin, err)
+	}
+
+	return Pin{
+		Name:   path.Base(ref.Repository),
+		Source: ref.String(),
+		Digest: digest,
+	}, nil
+}
+
+// Pinned reports whether the pin requires a specific digest
+func (pin Pin) Pinned() bool {
+	return pin.Digest != ""
+}
+
+// Verify checks that the local seed described by metadata matches the pinned digest,
+// and that its disk, whose current fingerprint is given, hasn't changed since it was pulled
+func (pin Pin) Verify(metadata *Metadata, disk string) error {
+	if !pin.Pinned() {
+		return nil
+	}
+
+	switch {
+	case metadata == nil:
+		return fmt.Errorf("%w: %s has no chamber metadata, so its digest is unknown", ErrDigestMismatch, pin.Name)
+	case metadata.Digest == "":
+		return fmt.Errorf("%w: %s was not pulled from a registry, so its digest is unknown", ErrDigestMismatch, pin.Name)
+	case metadata.Digest != pin.Digest:
+		return fmt.Errorf("%w: %s is pinned to %s, but the local seed is %s",
+			ErrDigestMismatch, pin.Name, pin.Digest, metadata.Digest)
+	case metadata.Disk == "" || metadata.Disk != disk:
+		return fmt.Errorf("%w: %s has changed since it was pulled", ErrDigestMismatch, pin.Name)
+	}
+
+	return nil
+}
+
+// PullReference returns the digest-pinned registry reference to pull the seed from,
+// falling back to the repository the local seed was pulled from or pushed to
+func (pin Pin) PullReference(metadata *Metadata) (string, bool) {
+	source := pin.Source
+	if source == "" && metadata != nil {
+		source = metadata.Source
+	}
+
+	ref, err := registry.ParseReference(source)
+	if err != nil {
+		return "", false
+	}
+
+	return ref.WithDigest(pin.Digest).String(), true
+}
@@ -0,0 +1,73 @@
+package seed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParsePin(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	for value, expected := range map[string]Pin{
+		"xcode":                              {Name: "xcode"},
+		"xcode@" + digest:                    {Name: "xcode", Digest: digest},
+		"ghcr.io/myteam/seed@" + digest:      {Name: "seed", Source: "ghcr.io/myteam/seed@" + digest, Digest: digest},
+		"ghcr.io/myteam/seed:v1@" + digest:   {Name: "seed", Source: "ghcr.io/myteam/seed@" + digest, Digest: digest},
+		"myteam/seed@" + digest:              {Name: "seed", Digest: digest},
+		"localhost:5000/team-seed@" + digest: {Name: "team-seed", Source: "localhost:5000/team-seed@" + digest, Digest: digest},
+	} {
+		pin, err := ParsePin(value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+
+			continue
+		}
+		if pin != expected {
+			t.Errorf("%s: got %+v, want %+v", value, pin, expected)
+		}
+	}
+
+	for _, value := range []string{"xcode@latest", "xcode@sha256:abc"} {
+		if _, err := ParsePin(value); !errors.Is(err, ErrInvalidPin) {
+			t.Errorf("%s: expected ErrInvalidPin, got %v", value, err)
+		}
+	}
+}
+
+func TestPinVerify(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	pin := Pin{Name: "seed", Digest: digest}
+
+	disk := "local:seed@2025-01-07T10:15:02.123456789Z"
+
+	if err := pin.Verify(&Metadata{Name: "seed", Digest: digest, Disk: disk}, disk); err != nil {
+		t.Errorf("expected a match, got %v", err)
+	}
+
+	for _, metadata := range []*Metadata{
+		nil,
+		{Name: "seed"},
+		{Name: "seed", Digest: "sha256:" + strings.Repeat("cd", 32), Disk: disk},
+		// Pulled before the disk was fingerprinted, or changed since
+		{Name: "seed", Digest: digest},
+		{Name: "seed", Digest: digest, Disk: "local:seed@2025-01-01T00:00:00Z"},
+	} {
+		if err := pin.Verify(metadata, disk); !errors.Is(err, ErrDigestMismatch) {
+			t.Errorf("%+v: expected ErrDigestMismatch, got %v", metadata, err)
+		}
+	}
+
+	if err := (Pin{Name: "seed"}).Verify(nil, ""); err != nil {
+		t.Errorf("unpinned seeds should always verify, got %v", err)
+	}
+
+	ref, ok := pin.PullReference(&Metadata{Source: "ghcr.io/myteam/seed:latest"})
+	if !ok || ref != "ghcr.io/myteam/seed@"+digest {
+		t.Errorf("unexpected pull reference %q", ref)
+	}
+
+	if _, ok := pin.PullReference(&Metadata{}); ok {
+		t.Error("expected no pull reference without a known source")
+	}
+}
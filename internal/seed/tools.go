@@ -0,0 +1,71 @@
+package seed
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type toolProbe struct {
+	name    string
+	command string
+}
+
+// toolProbes are the tools whose versions are recorded in the seed metadata,
+// which helps picking the right seed for a project
+var toolProbes = []toolProbe{
+	{"macos", "sw_vers -productVersion"},
+	{"xcode", "xcodebuild -version"},
+	{"brew", "brew --version"},
+	{"git", "git --version"},
+	{"node", "node --version"},
+	{"npm", "npm --version"},
+	{"python", "python3 --version"},
+	{"go", "go version"},
+	{"ruby", "ruby --version"},
+}
+
+// DetectTools determines the versions of well-known tools installed in the guest,
+// tools that are missing are simply omitted
+func DetectTools(client *gossh.Client) (map[string]string, error) {
+	output, err := ssh.Output(client, executor.LoginShell(toolsScript()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect tool versions: %w", err)
+	}
+
+	return parseTools(output), nil
+}
+
+// toolsScript prints a "<name>\t<first line of the version output>" line for each installed tool
+func toolsScript() string {
+	var lines []string
+
+	for _, probe := range toolProbes {
+		binary, _, _ := strings.Cut(probe.command, " ")
+
+		lines = append(lines, fmt.Sprintf("command -v %s >/dev/null 2>&1 && printf '%%s\\t%%s\\n' %s \"$(%s 2>/dev/null | head -n 1)\"",
+			binary, probe.name, probe.command))
+	}
+
+	return strings.Join(append(lines, "true"), "\n")
+}
+
+func parseTools(output string) map[string]string {
+	tools := map[string]string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(line, "\t")
+		version = strings.TrimSpace(version)
+
+		if !ok || version == "" {
+			continue
+		}
+
+		tools[name] = version
+	}
+
+	return tools
+}
@@ -0,0 +1,20 @@
+package seed
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTools(t *testing.T) {
+	output := "macos\t15.1\nxcode\tXcode 16.1\ngit\tgit version 2.39.5 (Apple Git-154)\nnode\t\n\n"
+
+	expected := map[string]string{
+		"macos": "15.1",
+		"xcode": "Xcode 16.1",
+		"git":   "git version 2.39.5 (Apple Git-154)",
+	}
+
+	if tools := parseTools(output); !reflect.DeepEqual(tools, expected) {
+		t.Errorf("got %v, want %v", tools, expected)
+	}
+}
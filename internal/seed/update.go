@@ -0,0 +1,231 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/agent"
+	"github.com/cirruslabs/chamber/internal/vm/tart"
+)
+
+// AgentUpdate describes the outcome of updating an agent in the seed
+type AgentUpdate struct {
+	Name       string
+	OldVersion string
+	NewVersion string
+	Err        error
+}
+
+// Changed reports whether the agent's version has changed
+func (update AgentUpdate) Changed() bool {
+	return update.Err == nil && update.OldVersion != update.NewVersion
+}
+
+// Unchanged reports whether all the agents were already up to date, in which
+// case the seed still matches the image it was pulled from
+func Unchanged(updates []AgentUpdate) bool {
+	for _, update := range updates {
+		if update.Err != nil || update.Changed() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (update AgentUpdate) String() string {
+	switch {
+	case update.Err != nil:
+		return fmt.Sprintf("%s: failed: %v", update.Name, update.Err)
+	case update.Changed():
+		return fmt.Sprintf("%s: %s -> %s", update.Name, valueOr(update.OldVersion, "unknown"), update.NewVersion)
+	default:
+		return fmt.Sprintf("%s: %s (already up to date)", update.Name, update.NewVersion)
+	}
+}
+
+// Age returns how long ago the seed was created or last updated
+func (metadata *Metadata) Age(now time.Time) time.Duration {
+	last := metadata.CreatedAt
+	if metadata.UpdatedAt.After(last) {
+		last = metadata.UpdatedAt
+	}
+
+	return now.Sub(last)
+}
+
+// Updater upgrades the agents installed in a seed VM in place
+type Updater struct {
+	name     string
+	registry *agent.Registry
+	store    *Store
+	out      io.Writer
+	sshUser  string
+	sshPass  string
+}
+
+func NewUpdater(name string, registry *agent.Registry, store *Store, out io.Writer) *Updater {
+	return &Updater{
+		name:     name,
+		registry: registry,
+		store:    store,
+		out:      out,
+		sshUser:  "admin",
+		sshPass:  "admin",
+	}
+}
+
+// Update boots the seed and upgrades the given agents, or all agents installed in the seed
+// when none are given. The seed metadata is saved even if some of the agents fail to update.
+func (updater *Updater) Update(ctx context.Context, only []string) ([]AgentUpdate, error) {
+	metadata, err := updater.store.Load(updater.name)
+	if err != nil {
+		if !errors.Is(err, ErrNoMetadata) {
+			return nil, err
+		}
+
+		metadata = &Metadata{Name: updater.name}
+	}
+
+	definitions, err := updater.agentsToUpdate(metadata, only)
+	if err != nil {
+		return nil, err
+	}
+
+	booted, err := Boot(ctx, updater.name, updater.sshUser, updater.sshPass, updater.out)
+	if err != nil {
+		return nil, err
+	}
+	defer booted.Shutdown()
+
+	// Seeds created before chamber kept metadata don't say which agents they have,
+	// so update the ones that turn out to be installed
+	if len(metadata.Agents) == 0 && len(only) == 0 {
+		var installed []agent.Definition
+
+		for _, definition := range definitions {
+			if version, err := agent.DetectVersion(booted.Client, definition); err == nil {
+				metadata.SetAgent(AgentInfo{Name: definition.Name, Version: version})
+				installed = append(installed, definition)
+			}
+		}
+
+		definitions = installed
+	}
+
+	var updates []AgentUpdate
+
+	for _, definition := range definitions {
+		update := AgentUpdate{Name: definition.Name}
+
+		for _, info := range metadata.Agents {
+			if info.Name == definition.Name {
+				update.OldVersion = info.Version
+			}
+		}
+
+		fmt.Fprintf(updater.out, "\nUpdating %s...\n", definition.Name)
+
+		if err := agent.Update(booted.Client, definition, updater.out, updater.out); err != nil {
+			update.Err = booted.Failure(err)
+			updates = append(updates, update)
+
+			continue
+		}
+
+		update.NewVersion, update.Err = agent.DetectVersion(booted.Client, definition)
+		if update.Err == nil {
+			metadata.SetAgent(AgentInfo{Name: definition.Name, Version: update.NewVersion})
+		}
+
+		updates = append(updates, update)
+	}
+
+	if tools, err := DetectTools(booted.Client); err == nil {
+		metadata.Tools = tools
+	}
+
+	booted.Shutdown()
+
+	if Unchanged(updates) {
+		// Booting touched the disk, but the seed is still as good as the image it was pulled from
+		if metadata.Disk != "" {
+			if metadata.Disk, err = tart.ResolveDigest(ctx, updater.name); err != nil {
+				return updates, err
+			}
+		}
+	} else {
+		// The seed no longer matches the image it was pulled from
+		metadata.Digest = ""
+		metadata.Disk = ""
+	}
+
+	var failed []error
+	for _, update := range updates {
+		if update.Err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", update.Name, update.Err))
+		}
+	}
+
+	// Otherwise the seed would pass for up to date, and the next run wouldn't retry
+	if len(failed) == 0 {
+		metadata.UpdatedAt = time.Now().UTC()
+	}
+
+	if err := updater.store.Save(metadata); err != nil {
+		return updates, err
+	}
+
+	if len(failed) != 0 {
+		return updates, fmt.Errorf("failed to update %d agent(s): %w", len(failed), errors.Join(failed...))
+	}
+
+	return updates, nil
+}
+
+func (updater *Updater) agentsToUpdate(metadata *Metadata, only []string) ([]agent.Definition, error) {
+	if len(only) != 0 {
+		var definitions []agent.Definition
+
+		for _, name := range only {
+			definition, ok := updater.registry.Get(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown agent %q", name)
+			}
+
+			definitions = append(definitions, definition)
+		}
+
+		return definitions, nil
+	}
+
+	if len(metadata.Agents) == 0 {
+		return updater.registry.All(), nil
+	}
+
+	var definitions []agent.Definition
+
+	for _, info := range metadata.Agents {
+		definition, ok := updater.registry.Get(info.Name)
+		if !ok {
+			fmt.Fprintf(updater.out, "Warning: %s is no longer a registered agent, skipping it\n", info.Name)
+
+			continue
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
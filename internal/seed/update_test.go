@@ -0,0 +1,52 @@
+package seed
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAgentUpdateString(t *testing.T) {
+	for update, expected := range map[AgentUpdate]string{
+		{Name: "claude", OldVersion: "1.0.0", NewVersion: "1.0.5"}: "claude: 1.0.0 -> 1.0.5",
+		{Name: "claude", NewVersion: "1.0.5"}:                      "claude: unknown -> 1.0.5",
+		{Name: "codex", OldVersion: "0.1.0", NewVersion: "0.1.0"}:  "codex: 0.1.0 (already up to date)",
+	} {
+		if update.String() != expected {
+			t.Errorf("got %q, want %q", update.String(), expected)
+		}
+	}
+
+	failed := AgentUpdate{Name: "codex", OldVersion: "0.1.0", Err: errors.New("npm failed")}
+	if failed.Changed() || failed.String() != "codex: failed: npm failed" {
+		t.Errorf("unexpected failed update %q", failed.String())
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	upToDate := AgentUpdate{Name: "codex", OldVersion: "0.1.0", NewVersion: "0.1.0"}
+
+	if !Unchanged([]AgentUpdate{upToDate}) {
+		t.Error("expected up to date agents to leave the seed unchanged")
+	}
+	if Unchanged([]AgentUpdate{upToDate, {Name: "claude", OldVersion: "1.0.0", NewVersion: "1.0.5"}}) {
+		t.Error("expected a new version to change the seed")
+	}
+	if Unchanged([]AgentUpdate{upToDate, {Name: "claude", OldVersion: "1.0.0", Err: errors.New("offline")}}) {
+		t.Error("expected a failed update to count as a change")
+	}
+}
+
+func TestMetadataAge(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	metadata := &Metadata{CreatedAt: now.Add(-9 * 24 * time.Hour)}
+	if age := metadata.Age(now); age != 9*24*time.Hour {
+		t.Errorf("unexpected age %s", age)
+	}
+
+	metadata.UpdatedAt = now.Add(-24 * time.Hour)
+	if age := metadata.Age(now); age != 24*time.Hour {
+		t.Errorf("the last update should reset the age, got %s", age)
+	}
+}
@@ -0,0 +1,518 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/git"
+)
+
+// garbageGracePeriod is how long CollectGarbage keeps the contents no manifest refers to
+const garbageGracePeriod = time.Hour
+
+// entry describes a file, directory or symbolic link in a manifest
+type entry struct {
+	Mode    fs.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mtime,omitempty"`
+	Hash    string      `json:"hash,omitempty"`
+	Link    string      `json:"link,omitempty"`
+}
+
+// same reports whether two entries have the same type, permissions and contents
+func (e entry) same(other entry) bool {
+	return e.Mode == other.Mode && e.Hash == other.Hash && e.Link == other.Link
+}
+
+// manifest maps the slash-separated paths relative to the snapshotted directory to their entries
+type manifest map[string]entry
+
+func (store *Store) saveManifest(dir string, name string, since *Snapshot) error {
+	var previous manifest
+	if since != nil && since.Manifest != "" && since.Dir == dir {
+		var err error
+
+		previous, err = store.loadManifest(since.Manifest)
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := store.scan(dir, previous, true)
+	if err != nil {
+		return err
+	}
+
+	return store.writeManifest(name, files)
+}
+
+func (store *Store) writeManifest(name string, files manifest) error {
+	contents, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(store.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to save the snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(store.manifestPath(name), contents, 0o600); err != nil {
+		return fmt.Errorf("failed to save the snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (store *Store) removeManifest(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	if err := os.Remove(store.manifestPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove the snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: manifest %q", ErrInvalidSnapshot, name)
+	}
+
+	return nil
+}
+
+func (store *Store) loadManifest(name string) (manifest, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(store.manifestPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot: %w", err)
+	}
+
+	var files manifest
+	if err := json.Unmarshal(contents, &files); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	return files, nil
+}
+
+func (store *Store) manifestChanges(snapshot *Snapshot) ([]string, error) {
+	saved, err := store.loadManifest(snapshot.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := store.scan(snapshot.Dir, saved, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(saved, current), nil
+}
+
+func (store *Store) restoreManifest(snapshot *Snapshot) ([]string, error) {
+	saved, err := store.loadManifest(snapshot.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := store.scan(snapshot.Dir, saved, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.restoreFiles(snapshot.Dir, saved, current)
+}
+
+// restoreFiles makes the files in dir described by current match saved and returns the paths it changed
+func (store *Store) restoreFiles(dir string, saved manifest, current manifest) ([]string, error) {
+	changes := diff(saved, current)
+
+	// Parents sort before their children, so remove in reverse and restore in order
+	for i := len(changes) - 1; i >= 0; i-- {
+		path := changes[i]
+		if _, ok := saved[path]; ok {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dir, filepath.FromSlash(path))); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	for _, path := range changes {
+		wanted, ok := saved[path]
+		if !ok {
+			continue
+		}
+
+		existing, exists := current[path]
+		if err := store.restoreEntry(filepath.Join(dir, filepath.FromSlash(path)), wanted, existing, exists); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+
+	return changes, nil
+}
+
+func (store *Store) restoreEntry(path string, wanted entry, existing entry, exists bool) error {
+	// Only a directory can be updated in place, anything else is replaced
+	if exists && (existing.Mode.Type() != wanted.Mode.Type() || !wanted.Mode.IsDir()) {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	switch wanted.Mode.Type() {
+	case fs.ModeDir:
+		if err := os.MkdirAll(path, wanted.Mode.Perm()); err != nil {
+			return err
+		}
+
+		return os.Chmod(path, wanted.Mode.Perm())
+	case fs.ModeSymlink:
+		return os.Symlink(wanted.Link, path)
+	default:
+		if err := store.copyObject(wanted.Hash, path, wanted.Mode.Perm()); err != nil {
+			return err
+		}
+
+		return os.Chtimes(path, wanted.ModTime, wanted.ModTime)
+	}
+}
+
+// scan builds the manifest of dir. The hashes of the files whose size and modification
+// time match the previous manifest are reused, and the contents of the others are copied
+// to the store when save is set.
+func (store *Store) scan(dir string, previous manifest, save bool) (manifest, error) {
+	files := manifest{}
+
+	err := filepath.WalkDir(dir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		// A repository without commits has nothing to snapshot in .git,
+		// and the store mustn't snapshot itself when it's inside dir
+		if dirEntry.IsDir() && (dirEntry.Name() == ".git" || path == store.dir) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		return store.add(files, path, rel, info, previous, save)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %w", dir, err)
+	}
+
+	return files, nil
+}
+
+// regenerated are the directories of dependencies and build output that git usually ignores.
+// They can be huge and are easily recreated, so they're left out of the snapshots.
+var regenerated = map[string]bool{
+	"node_modules": true,
+	".venv":        true,
+	"venv":         true,
+	"__pycache__":  true,
+	".tox":         true,
+	".gradle":      true,
+	".next":        true,
+	".build":       true,
+	"DerivedData":  true,
+	"build":        true,
+	"dist":         true,
+	"target":       true,
+}
+
+// scanIgnored builds the manifest of the files git ignores in the working tree dir, like scan does,
+// except for the ones in regenerated directories
+func (store *Store) scanIgnored(ctx context.Context, dir string, previous manifest, save bool) (manifest, error) {
+	paths, err := git.IgnoredFiles(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := manifest{}
+
+	for _, rel := range paths {
+		if inRegenerated(rel) {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+
+		// The store mustn't snapshot itself when it's inside dir
+		if inside, err := filepath.Rel(store.dir, path); err == nil && filepath.IsLocal(inside) {
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", dir, err)
+		}
+
+		if err := store.add(files, path, rel, info, previous, save); err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", dir, err)
+		}
+	}
+
+	return files, nil
+}
+
+// inRegenerated reports whether the slash-separated path is below a regenerated directory
+func inRegenerated(rel string) bool {
+	parts := strings.Split(rel, "/")
+
+	for _, part := range parts[:len(parts)-1] {
+		if regenerated[part] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add describes the file at path in files, unless it's a file that can't be restored
+func (store *Store) add(files manifest, path string, rel string, info fs.FileInfo, previous manifest, save bool) error {
+	current := entry{Mode: info.Mode().Type() | info.Mode().Perm()}
+
+	switch {
+	case info.IsDir():
+	case info.Mode()&fs.ModeSymlink != 0:
+		var err error
+
+		current.Link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	case info.Mode().IsRegular():
+		current.Size = info.Size()
+		current.ModTime = info.ModTime().UTC()
+
+		if known, ok := previous[rel]; ok && known.Mode == current.Mode && known.Size == current.Size &&
+			known.ModTime.Equal(current.ModTime) {
+			current.Hash = known.Hash
+		} else {
+			var err error
+
+			current.Hash, err = store.hashFile(path, save)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		// Sockets, pipes and devices can't be restored
+		return nil
+	}
+
+	files[rel] = current
+
+	return nil
+}
+
+// CollectGarbage removes the contents of the files that no manifest refers to anymore.
+// The contents stored recently are kept, as they may belong to a snapshot being taken.
+func (store *Store) CollectGarbage() error {
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to list the snapshots: %w", err)
+	}
+
+	used := map[string]bool{}
+
+	for _, dirEntry := range entries {
+		name, ok := strings.CutSuffix(dirEntry.Name(), ".json")
+		if dirEntry.IsDir() || !ok {
+			continue
+		}
+
+		files, err := store.loadManifest(name)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			used[file.Hash] = true
+		}
+	}
+
+	objects, err := os.ReadDir(store.objectsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to list the snapshot contents: %w", err)
+	}
+
+	for _, object := range objects {
+		if used[object.Name()] {
+			continue
+		}
+
+		info, err := object.Info()
+		if err != nil || time.Since(info.ModTime()) < garbageGracePeriod {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(store.objectsDir(), object.Name())); err != nil &&
+			!errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove the snapshot contents: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// hashFile returns the SHA-256 of the file and copies it to the store when save is set
+func (store *Store) hashFile(path string, save bool) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if !save {
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	if err := os.MkdirAll(store.objectsDir(), 0o700); err != nil {
+		return "", err
+	}
+
+	tmpFile, err := os.CreateTemp(store.objectsDir(), ".object-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(io.MultiWriter(hash, tmpFile), file)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	// The same contents are only stored once, but they're marked as recently
+	// stored again, so that they aren't collected while the manifest is written
+	if _, err := os.Stat(store.objectPath(sum)); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(tmpFile.Name(), store.objectPath(sum)); err != nil {
+			return "", err
+		}
+	} else {
+		now := time.Now()
+		if err := os.Chtimes(store.objectPath(sum), now, now); err != nil {
+			return "", err
+		}
+	}
+
+	return sum, nil
+}
+
+func (store *Store) copyObject(hash string, path string, perm fs.FileMode) error {
+	object, err := os.Open(store.objectPath(hash))
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write next to the destination first, so that an interrupted restore doesn't leave a partial file
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".chamber-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, object)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmpFile.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (store *Store) manifestPath(name string) string {
+	return filepath.Join(store.dir, name+".json")
+}
+
+func (store *Store) objectsDir() string {
+	return filepath.Join(store.dir, "objects")
+}
+
+func (store *Store) objectPath(hash string) string {
+	return filepath.Join(store.objectsDir(), hash)
+}
+
+// diff returns the sorted paths that differ between two manifests
+func diff(from manifest, to manifest) []string {
+	var paths []string
+
+	for path, entry := range from {
+		if other, ok := to[path]; !ok || !entry.same(other) {
+			paths = append(paths, path)
+		}
+	}
+	for path := range to {
+		if _, ok := from[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
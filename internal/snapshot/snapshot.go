@@ -0,0 +1,291 @@
+// Package snapshot records the state of a working directory before and after a run,
+// so that the changes made during the run can be undone
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/config"
+	"github.com/cirruslabs/chamber/internal/git"
+)
+
+// RefPrefix namespaces the git refs that keep the snapshots of git working trees
+const RefPrefix = "refs/chamber/snapshots/"
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	ErrBranchChanged   = errors.New("another branch is checked out")
+)
+
+// ignoredSuffix names the manifest of the files git ignores after the snapshot
+const ignoredSuffix = "-ignored"
+
+// Snapshot is the state of a directory at some point.
+//
+// The working tree of a git repository with at least one commit is snapshotted as a git tree
+// object, which includes untracked files, and a manifest of the files git ignores. Any other
+// directory is snapshotted as a manifest of its files. The contents of the files in manifests
+// are copied to the store.
+type Snapshot struct {
+	// Dir is the snapshotted directory, the root of the working tree for git snapshots
+	Dir string `json:"dir"`
+
+	// Head is the commit checked out and Tree the tree object with the contents of the working tree
+	Head string `json:"head,omitempty"`
+	Tree string `json:"tree,omitempty"`
+
+	// Branch is the full name of the branch checked out, or HEAD when it was detached
+	Branch string `json:"branch,omitempty"`
+
+	// Ref is the git ref that keeps Tree from being garbage collected
+	Ref string `json:"ref,omitempty"`
+
+	// Ignored is the name of the manifest of the files git ignores
+	Ignored string `json:"ignored,omitempty"`
+
+	// Manifest is the name of the file manifest in the store
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// Store keeps the file manifests and the contents of the files they refer to
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store located in ~/.config/chamber/snapshots
+func DefaultStore() (*Store, error) {
+	dir, err := config.Path("snapshots")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(dir), nil
+}
+
+// Take snapshots dir under the given name, which must be unique. The files that haven't
+// changed since the previous snapshot of dir, if any, aren't read again.
+func (store *Store) Take(ctx context.Context, dir string, name string, since *Snapshot) (*Snapshot, error) {
+	if _, err := git.TopLevel(ctx, dir); err == nil {
+		// Repositories without commits are snapshotted file by file
+		if snapshot, err := git.TakeSnapshot(ctx, dir); err == nil {
+			return store.takeGit(ctx, snapshot, name, since)
+		}
+	}
+
+	if err := store.saveManifest(dir, name, since); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Dir: dir, Manifest: name}, nil
+}
+
+func (store *Store) takeGit(ctx context.Context, snapshot *git.Snapshot, name string, since *Snapshot) (*Snapshot, error) {
+	// The tree leaves out the files git ignores, such as .env, so they're kept in a manifest
+	var previous manifest
+	if since != nil && since.Ignored != "" && since.Dir == snapshot.Dir {
+		var err error
+
+		previous, err = store.loadManifest(since.Ignored)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ignored, err := store.scanIgnored(ctx, snapshot.Dir, previous, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.writeManifest(name+ignoredSuffix, ignored); err != nil {
+		return nil, err
+	}
+
+	ref := RefPrefix + name
+	if _, err := snapshot.Keep(ctx, ref); err != nil {
+		return nil, fmt.Errorf("failed to keep the snapshot: %w", err)
+	}
+
+	return &Snapshot{
+		Dir:     snapshot.Dir,
+		Head:    snapshot.Head,
+		Tree:    snapshot.Tree,
+		Branch:  snapshot.Branch,
+		Ref:     ref,
+		Ignored: name + ignoredSuffix,
+	}, nil
+}
+
+// Changes returns the paths that differ between the directory and the snapshot,
+// git snapshots also report HEAD if another commit is checked out now
+func (store *Store) Changes(ctx context.Context, snapshot *Snapshot) ([]string, error) {
+	switch {
+	case snapshot.Tree != "":
+		changes, err := store.treeChanges(ctx, snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		if snapshot.Ignored != "" {
+			saved, current, err := store.ignoredFiles(ctx, snapshot)
+			if err != nil {
+				return nil, err
+			}
+
+			changes = merge(changes, diff(saved, current))
+		}
+
+		head, err := git.Run(ctx, snapshot.Dir, "rev-parse", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		if head != snapshot.Head {
+			changes = append([]string{"HEAD"}, changes...)
+		}
+
+		return changes, nil
+	case snapshot.Manifest != "":
+		return store.manifestChanges(snapshot)
+	default:
+		return nil, ErrInvalidSnapshot
+	}
+}
+
+// Check returns an error if the snapshot can't be restored: the branch of a git
+// working tree can only be reset to the snapshotted commit if it's still checked out
+func (store *Store) Check(ctx context.Context, snapshot *Snapshot) error {
+	if snapshot.Tree == "" || snapshot.Branch == "" {
+		return nil
+	}
+
+	branch, err := git.CurrentBranch(ctx, snapshot.Dir)
+	if err != nil {
+		return err
+	}
+
+	if branch != snapshot.Branch {
+		return fmt.Errorf("%w: %s was checked out when the snapshot was taken, check it out again first",
+			ErrBranchChanged, strings.TrimPrefix(snapshot.Branch, "refs/heads/"))
+	}
+
+	return nil
+}
+
+// Restore brings the directory back to the state of the snapshot and returns the paths it changed.
+// The index is left alone unless another commit is checked out now, in which case the current
+// branch is reset to the snapshotted commit, provided it's the branch that was checked out then.
+func (store *Store) Restore(ctx context.Context, snapshot *Snapshot) ([]string, error) {
+	switch {
+	case snapshot.Tree != "":
+		if err := store.Check(ctx, snapshot); err != nil {
+			return nil, err
+		}
+
+		changes, err := store.Changes(ctx, snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := git.WriteTree(ctx, snapshot.Dir, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := git.CheckoutTree(ctx, snapshot.Dir, current, snapshot.Tree); err != nil {
+			return nil, err
+		}
+
+		if len(changes) != 0 && changes[0] == "HEAD" {
+			if _, err := git.Run(ctx, snapshot.Dir, "reset", "--quiet", snapshot.Head); err != nil {
+				return nil, err
+			}
+		}
+
+		// The files that are ignored now are only known once the tree is restored
+		if snapshot.Ignored != "" {
+			saved, current, err := store.ignoredFiles(ctx, snapshot)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := store.restoreFiles(snapshot.Dir, saved, current); err != nil {
+				return nil, err
+			}
+		}
+
+		return changes, nil
+	case snapshot.Manifest != "":
+		return store.restoreManifest(snapshot)
+	default:
+		return nil, ErrInvalidSnapshot
+	}
+}
+
+// Remove deletes the snapshot: the ref that keeps its tree and its manifests. The contents
+// of the files stay in the store until CollectGarbage, as other snapshots may share them.
+func (store *Store) Remove(ctx context.Context, snapshot *Snapshot) error {
+	// There's nothing to delete if the repository is gone
+	if snapshot.Ref != "" {
+		if _, err := git.TopLevel(ctx, snapshot.Dir); err == nil {
+			if err := git.DeleteRef(ctx, snapshot.Dir, snapshot.Ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range []string{snapshot.Manifest, snapshot.Ignored} {
+		if name == "" {
+			continue
+		}
+
+		if err := store.removeManifest(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *Store) treeChanges(ctx context.Context, snapshot *Snapshot) ([]string, error) {
+	current, err := git.WriteTree(ctx, snapshot.Dir, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	return git.DiffTrees(ctx, snapshot.Dir, snapshot.Tree, current)
+}
+
+// ignoredFiles returns the saved manifest of the files git ignored and the current one
+func (store *Store) ignoredFiles(ctx context.Context, snapshot *Snapshot) (manifest, manifest, error) {
+	saved, err := store.loadManifest(snapshot.Ignored)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := store.scanIgnored(ctx, snapshot.Dir, saved, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return saved, current, nil
+}
+
+// merge returns the sorted union of two sorted lists of paths
+func merge(paths []string, others []string) []string {
+	if len(others) == 0 {
+		return paths
+	}
+
+	result := append(append([]string{}, paths...), others...)
+	sort.Strings(result)
+
+	return slices.Compact(result)
+}
@@ -0,0 +1,330 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cirruslabs/chamber/internal/git"
+)
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+// makeChanges edits, deletes and adds files the way an agent would
+func makeChanges(t *testing.T, dir string) {
+	t.Helper()
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main // changed\n")
+	if err := os.Remove(filepath.Join(dir, "docs", "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "new", "file.txt"), "new\n")
+}
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "docs", "README.md"), "docs\n")
+	if err := os.Symlink("main.go", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := store.Take(ctx, dir, "run-before", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Manifest == "" {
+		t.Fatalf("expected a file snapshot outside of a repository, got %+v", before)
+	}
+
+	makeChanges(t, dir)
+
+	after, err := store.Take(ctx, dir, "run-after", before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"docs/README.md", "main.go", "new", "new/file.txt"}
+
+	changes, err := store.Changes(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+
+	if changes, err := store.Changes(ctx, after); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes since the last snapshot, got %v (%v)", changes, err)
+	}
+
+	restored, err := store.Restore(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, expected) {
+		t.Errorf("expected to restore %v, got %v", expected, restored)
+	}
+
+	if contents := readFile(t, filepath.Join(dir, "main.go")); contents != "package main\n" {
+		t.Errorf("main.go wasn't restored, got %q", contents)
+	}
+	if contents := readFile(t, filepath.Join(dir, "docs", "README.md")); contents != "docs\n" {
+		t.Errorf("docs/README.md wasn't restored, got %q", contents)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected the new directory to be removed, got %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "main.go" {
+		t.Errorf("expected the symbolic link to be kept, got %q (%v)", target, err)
+	}
+
+	if changes, err := store.Changes(ctx, before); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes after restoring, got %v (%v)", changes, err)
+	}
+}
+
+// initRepo creates a repository in dir and commits its files
+func initRepo(t *testing.T, dir string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "docs", "README.md"), "docs\n")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "*.log\n.env\n")
+
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false",
+			"commit", "-q", "-m", "Initial commit"},
+	} {
+		if _, err := git.Run(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGit(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	dir := t.TempDir()
+
+	initRepo(t, dir)
+
+	// Untracked and ignored files are part of the snapshot
+	writeFile(t, filepath.Join(dir, "notes.txt"), "notes\n")
+	writeFile(t, filepath.Join(dir, ".env"), "TOKEN=before\n")
+
+	before, err := store.Take(ctx, dir, "run-before", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Tree == "" {
+		t.Fatalf("expected a git snapshot, got %+v", before)
+	}
+
+	makeChanges(t, dir)
+	writeFile(t, filepath.Join(dir, "debug.log"), "ignored\n")
+	writeFile(t, filepath.Join(dir, ".env"), "TOKEN=after\n")
+	if err := os.Remove(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".env", "debug.log", "docs/README.md", "main.go", "new/file.txt", "notes.txt"}
+
+	restored, err := store.Restore(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, expected) {
+		t.Errorf("expected to restore %v, got %v", expected, restored)
+	}
+
+	if contents := readFile(t, filepath.Join(dir, "notes.txt")); contents != "notes\n" {
+		t.Errorf("notes.txt wasn't restored, got %q", contents)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new", "file.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the new file to be removed, got %v", err)
+	}
+	if contents := readFile(t, filepath.Join(dir, ".env")); contents != "TOKEN=before\n" {
+		t.Errorf(".env wasn't restored, got %q", contents)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "debug.log")); !os.IsNotExist(err) {
+		t.Errorf("expected the new ignored file to be removed, got %v", err)
+	}
+
+	// The snapshot is kept from garbage collection
+	if _, err := git.Run(ctx, dir, "rev-parse", "--verify", RefPrefix+"run-before"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGitRegenerated(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	dir := t.TempDir()
+
+	initRepo(t, dir)
+
+	writeFile(t, filepath.Join(dir, "node_modules", "left-pad", "install.log"), "before\n")
+
+	before, err := store.Take(ctx, dir, "run-before", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dependencies are left as they are, even though git ignores them
+	writeFile(t, filepath.Join(dir, "node_modules", "left-pad", "install.log"), "after\n")
+	writeFile(t, filepath.Join(dir, "build", "output.log"), "built\n")
+
+	restored, err := store.Restore(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 0 {
+		t.Errorf("expected nothing to be restored, got %v", restored)
+	}
+	if contents := readFile(t, filepath.Join(dir, "node_modules", "left-pad", "install.log")); contents != "after\n" {
+		t.Errorf("expected the dependencies to be left alone, got %q", contents)
+	}
+}
+
+func TestGitBranchChanged(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	dir := t.TempDir()
+
+	initRepo(t, dir)
+
+	before, err := store.Take(ctx, dir, "run-before", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main // changed\n")
+	for _, args := range [][]string{
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false",
+			"commit", "-q", "-a", "-m", "Change"},
+		{"checkout", "-q", "-b", "other"},
+	} {
+		if _, err := git.Run(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.Restore(ctx, before); !errors.Is(err, ErrBranchChanged) {
+		t.Fatalf("expected %v, got %v", ErrBranchChanged, err)
+	}
+
+	// The other branch must not have been reset
+	if contents := readFile(t, filepath.Join(dir, "main.go")); contents != "package main // changed\n" {
+		t.Errorf("expected main.go to be left alone, got %q", contents)
+	}
+
+	if _, err := git.Run(ctx, dir, "checkout", "-q", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Restore(ctx, before); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readFile(t, filepath.Join(dir, "main.go")); contents != "package main\n" {
+		t.Errorf("main.go wasn't restored, got %q", contents)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(t.TempDir())
+	repo := t.TempDir()
+	dir := t.TempDir()
+
+	initRepo(t, repo)
+	writeFile(t, filepath.Join(repo, ".env"), "TOKEN=secret\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	var snapshots []*Snapshot
+
+	for _, path := range []string{repo, dir} {
+		snapshot, err := store.Take(ctx, path, "run-before", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	for _, snapshot := range snapshots {
+		if err := store.Remove(ctx, snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := git.Run(ctx, repo, "rev-parse", "--verify", RefPrefix+"run-before"); err == nil {
+		t.Error("expected the ref to be removed")
+	}
+
+	// The contents are only collected once they're old enough not to belong to a snapshot being taken
+	objects, err := os.ReadDir(store.objectsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) == 0 {
+		t.Fatal("expected the contents to be stored")
+	}
+
+	old := time.Now().Add(-2 * garbageGracePeriod)
+	for _, object := range objects {
+		if err := os.Chtimes(filepath.Join(store.objectsDir(), object.Name()), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.CollectGarbage(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			t.Errorf("expected the manifests to be removed, found %s", entry.Name())
+		}
+	}
+	if objects, err := os.ReadDir(store.objectsDir()); err != nil || len(objects) != 0 {
+		t.Errorf("expected the contents to be collected, got %d (%v)", len(objects), err)
+	}
+}
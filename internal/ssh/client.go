@@ -1,9 +1,12 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/avast/retry-go/v4"
@@ -58,3 +61,54 @@ func WaitForSSH(
 
 	return ssh.NewClient(sshConn, chans, reqs), nil
 }
+
+// Run executes a command in a new session, streaming its output to the given writers
+func Run(client *ssh.Client, command string, stdout io.Writer, stderr io.Writer) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	return session.Run(command)
+}
+
+// Output executes a command in a new session and returns its standard output
+func Output(client *ssh.Client, command string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(command)
+
+	return string(output), err
+}
+
+// RunWithInput executes a command in a new session, feeding it the given standard input
+func RunWithInput(client *ssh.Client, command string, stdin io.Reader) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+
+	session.Stdin = stdin
+	session.Stderr = &stderr
+
+	if err := session.Run(command); err != nil {
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// pollInterval is how often a pending read checks whether the input was stopped
+const pollInterval = 100 // milliseconds
+
+// terminalInput reads from the terminal until it's stopped. Reading os.Stdin directly would
+// block until the next key press, even after the session ended, and swallow that key press.
+type terminalInput struct {
+	file *os.File
+	stop chan struct{}
+}
+
+func newTerminalInput(file *os.File) *terminalInput {
+	return &terminalInput{
+		file: file,
+		stop: make(chan struct{}),
+	}
+}
+
+func (input *terminalInput) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-input.stop:
+			return 0, io.EOF
+		default:
+		}
+
+		// Only read once there's something to read, so that the read doesn't block
+		fds := []unix.PollFd{{Fd: int32(input.file.Fd()), Events: unix.POLLIN}}
+
+		ready, err := unix.Poll(fds, pollInterval)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return 0, err
+		}
+		if ready == 0 {
+			continue
+		}
+
+		return input.file.Read(p)
+	}
+}
+
+// Stop makes the pending and future reads return io.EOF
+func (input *terminalInput) Stop() {
+	close(input.stop)
+}
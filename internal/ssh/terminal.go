@@ -13,17 +13,45 @@ import (
 	"golang.org/x/term"
 )
 
+// Recorder is notified about the terminal's size and the user's input, e.g. to record the session.
+// The output is recorded by the writers passed to SetOutput.
+type Recorder interface {
+	Start(width int, height int) error
+	Resize(width int, height int)
+	RecordInput(p []byte)
+}
+
 // Terminal provides SSH terminal proxying with full PTY support
 type Terminal struct {
-	client *ssh.Client
+	client   *ssh.Client
+	stdout   io.Writer
+	stderr   io.Writer
+	recorder Recorder
 }
 
 // NewTerminal creates a new SSH terminal proxy
 func NewTerminal(client *ssh.Client) *Terminal {
-	return &Terminal{client: client}
+	return &Terminal{
+		client: client,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+// SetOutput overrides where the command's output is written, e.g. to redact it
+func (t *Terminal) SetOutput(stdout io.Writer, stderr io.Writer) {
+	t.stdout = stdout
+	t.stderr = stderr
+}
+
+// SetRecorder makes the terminal report its size and input to the recorder
+func (t *Terminal) SetRecorder(recorder Recorder) {
+	t.recorder = recorder
 }
 
-// RunInteractiveCommand runs a command with full terminal proxying
+// RunInteractiveCommand runs a command with full terminal proxying. Cancelling ctx closes
+// the SSH client, since a VM that went away never reports the command's exit status:
+// neither the terminal nor the client can be used after that.
 func (t *Terminal) RunInteractiveCommand(ctx context.Context, command string) error {
 	session, err := t.client.NewSession()
 	if err != nil {
@@ -31,6 +59,20 @@ func (t *Terminal) RunInteractiveCommand(ctx context.Context, command string) er
 	}
 	defer session.Close()
 
+	// Tear down the connection when the context is cancelled: if the VM went away,
+	// the remote end will never report an exit status and Wait() would hang forever
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Close()
+			_ = t.client.Close()
+		case <-done:
+		}
+	}()
+
 	// Check if we're running in a terminal
 	fd := int(os.Stdin.Fd())
 	if !term.IsTerminal(fd) {
@@ -79,6 +121,12 @@ func (t *Terminal) RunInteractiveCommand(ctx context.Context, command string) er
 		return fmt.Errorf("failed to request pty: %w", err)
 	}
 
+	if t.recorder != nil {
+		if err := t.recorder.Start(width, height); err != nil {
+			return err
+		}
+	}
+
 	// Set up pipes
 	stdin, err := session.StdinPipe()
 	if err != nil {
@@ -106,29 +154,39 @@ func (t *Terminal) RunInteractiveCommand(ctx context.Context, command string) er
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 
-	// Copy IO
+	// Copy IO, the input is stopped once the command completes so that it doesn't
+	// take the next key press, which belongs to whatever runs after the command
+	terminalInput := newTerminalInput(os.Stdin)
+
 	var wg sync.WaitGroup
 	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(stdin, os.Stdin)
+
+		var input io.Reader = terminalInput
+		if t.recorder != nil {
+			input = io.TeeReader(terminalInput, recordedInput{t.recorder})
+		}
+
+		_, _ = io.Copy(stdin, input)
 		_ = stdin.Close()
 	}()
 
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(os.Stdout, stdout)
+		_, _ = io.Copy(t.stdout, stdout)
 	}()
 
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(os.Stderr, stderr)
+		_, _ = io.Copy(t.stderr, stderr)
 	}()
 
 	// Wait for command to complete
 	err = session.Wait()
 	cancel() // Stop resize handler
+	terminalInput.Stop()
 	wg.Wait()
 
 	restore()
@@ -155,14 +213,28 @@ func (t *Terminal) handleWindowResize(ctx context.Context, session *ssh.Session,
 			if session != nil {
 				_ = session.WindowChange(height, width)
 			}
+			if t.recorder != nil {
+				t.recorder.Resize(width, height)
+			}
 		}
 	}
 }
 
+// recordedInput passes the user's input to the recorder
+type recordedInput struct {
+	recorder Recorder
+}
+
+func (input recordedInput) Write(p []byte) (int, error) {
+	input.recorder.RecordInput(p)
+
+	return len(p), nil
+}
+
 // runNonInteractive runs command without PTY for non-terminal environments
 func (t *Terminal) runNonInteractive(session *ssh.Session, command string) error {
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
+	session.Stdout = t.stdout
+	session.Stderr = t.stderr
 	session.Stdin = os.Stdin
 
 	return session.Run(command)
@@ -1,7 +1,11 @@
 package ssh
 
 import (
+	"errors"
+	"io"
+	"os"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -34,3 +38,49 @@ func TestTerminalStructure(t *testing.T) {
 	// We can't test RunInteractiveCommand with a nil client as it will panic
 	// But we can verify the method exists by checking it compiles
 }
+
+func TestTerminalInputStop(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+
+	input := newTerminalInput(reader)
+
+	if _, err := writer.Write([]byte("y")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 8)
+	if n, err := input.Read(buf); err != nil || string(buf[:n]) != "y" {
+		t.Fatalf("expected to read the input, got %q (%v)", buf[:n], err)
+	}
+
+	// A read that's waiting for the next key press returns once the input is stopped
+	result := make(chan error, 1)
+	go func() {
+		_, err := input.Read(buf)
+		result <- err
+	}()
+
+	input.Stop()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read didn't return after the input was stopped")
+	}
+
+	// What's typed afterwards is left for whoever reads next
+	if _, err := writer.Write([]byte("n")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reader.Read(buf); err != nil || string(buf[:n]) != "n" {
+		t.Errorf("expected the next key press to be left alone, got %q (%v)", buf[:n], err)
+	}
+}
@@ -0,0 +1,170 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cirruslabs/chamber/internal/executor"
+	"github.com/cirruslabs/chamber/internal/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// GuestPath renders a guest path for use in a shell command, expanding a leading "~/" to the guest's $HOME
+func GuestPath(path string) string {
+	if path == "~" {
+		return `"$HOME"`
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return `"$HOME"/` + executor.ShellQuote(rest)
+	}
+
+	return executor.ShellQuote(path)
+}
+
+// Upload copies a local file or directory to dst in the guest, creating parent directories as needed
+func Upload(client *gossh.Client, src string, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", src, err)
+	}
+
+	guestDst := GuestPath(dst)
+
+	if !info.IsDir() {
+		file, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", src, err)
+		}
+		defer file.Close()
+
+		if err := ssh.RunWithInput(client, writeFileCommand(dst, info.Mode()), file); err != nil {
+			return fmt.Errorf("failed to upload %s to %s: %w", src, dst, err)
+		}
+
+		return nil
+	}
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		_ = writer.CloseWithError(WriteTar(writer, src, nil))
+	}()
+
+	command := fmt.Sprintf("mkdir -p %[1]s && tar -x -f - -C %[1]s", guestDst)
+
+	if err := ssh.RunWithInput(client, command, reader); err != nil {
+		_ = reader.CloseWithError(err)
+
+		return fmt.Errorf("failed to upload %s to %s: %w", src, dst, err)
+	}
+
+	return nil
+}
+
+// WriteFile writes contents to dst in the guest with the given permissions,
+// creating parent directories as needed
+func WriteFile(client *gossh.Client, contents []byte, dst string, mode fs.FileMode) error {
+	if err := ssh.RunWithInput(client, writeFileCommand(dst, mode), bytes.NewReader(contents)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+
+	return nil
+}
+
+func writeFileCommand(dst string, mode fs.FileMode) string {
+	// Restrict the permissions before writing, the contents might be sensitive
+	return fmt.Sprintf("mkdir -p \"$(dirname %[1]s)\" && : > %[1]s && chmod %#[2]o %[1]s && cat > %[1]s",
+		GuestPath(dst), uint32(mode.Perm()))
+}
+
+// WriteTar writes the contents of the root directory as a tar stream. When include is not nil,
+// only the paths (relative to root, slash-separated) for which it returns true are written.
+func WriteTar(w io.Writer, root string, include func(path string, entry fs.DirEntry) bool) error {
+	tarWriter := tar.NewWriter(w)
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if include != nil && !include(relPath, entry) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		return writeTarEntry(tarWriter, path, relPath)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", root, err)
+	}
+
+	return tarWriter.Close()
+}
+
+func writeTarEntry(tarWriter *tar.Writer, path string, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	} else if !info.Mode().IsRegular() && !info.IsDir() {
+		// Sockets, pipes and devices can't be meaningfully transferred
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	// Don't leak host user names into the guest
+	header.Uname = ""
+	header.Gname = ""
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(tarWriter, file)
+
+	return err
+}
@@ -0,0 +1,93 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGuestPath(t *testing.T) {
+	tests := map[string]string{
+		"~":                 `"$HOME"`,
+		"~/.zshrc":          `"$HOME"/.zshrc`,
+		"~/My Documents/x":  `"$HOME"/'My Documents/x'`,
+		"/etc/hosts":        "/etc/hosts",
+		"/tmp/it's here":    `'/tmp/it'\''s here'`,
+		"relative/path.txt": "relative/path.txt",
+	}
+
+	for path, expected := range tests {
+		if result := GuestPath(path); result != expected {
+			t.Errorf("GuestPath(%q) = %s, want %s", path, result, expected)
+		}
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	root := t.TempDir()
+
+	for path, contents := range map[string]string{
+		"a.txt":         "a",
+		"dir/b.txt":     "b",
+		"skipped/c.txt": "c",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, path), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err := WriteTar(&buf, root, func(path string, entry fs.DirEntry) bool {
+		return path != "skipped"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := map[string]string{}
+
+	reader := tar.NewReader(&buf)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.Typeflag == tar.TypeSymlink {
+			entries[header.Name] = "-> " + header.Linkname
+		} else {
+			entries[header.Name] = string(contents)
+		}
+	}
+
+	expected := map[string]string{
+		"a.txt":     "a",
+		"dir/":      "",
+		"dir/b.txt": "b",
+		"link":      "-> a.txt",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("unexpected archive contents: %v", entries)
+	}
+}
@@ -0,0 +1,114 @@
+package tart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	goversion "github.com/hashicorp/go-version"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported Tart version")
+
+// Capabilities describe optional Tart features chamber relies on
+type Capabilities struct {
+	Version *goversion.Version
+
+	// NoClipboard is set when "tart run" supports --no-clipboard
+	NoClipboard bool
+
+	// NoAudio is set when "tart run" supports --no-audio
+	NoAudio bool
+
+	// DirTag is set when "tart run --dir" accepts the tag=<tag> option
+	DirTag bool
+}
+
+var (
+	capabilitiesMtx    sync.Mutex
+	cachedCapabilities *Capabilities
+)
+
+// DetectCapabilities determines the installed Tart version and the optional features it supports.
+// The result is cached for the lifetime of the process.
+func DetectCapabilities(ctx context.Context) (*Capabilities, error) {
+	capabilitiesMtx.Lock()
+	defer capabilitiesMtx.Unlock()
+
+	if cachedCapabilities != nil {
+		return cachedCapabilities, nil
+	}
+
+	version, err := Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Feature flags are easier to detect from the help output than to map to
+	// release numbers, and this keeps working for development builds of Tart
+	runHelp, _, err := CmdWithCapture(ctx, nil, "run", "--help")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect Tart capabilities: %w", err)
+	}
+
+	capabilities := parseRunHelp(runHelp)
+	capabilities.Version = version
+	cachedCapabilities = capabilities
+
+	return capabilities, nil
+}
+
+// EnsureSupported makes sure Tart is installed and recent enough to be used by chamber
+func EnsureSupported(ctx context.Context) (*Capabilities, error) {
+	if !Installed() {
+		return nil, fmt.Errorf("%w: tart is not installed. Please install it from https://github.com/cirruslabs/tart",
+			ErrTartNotFound)
+	}
+
+	capabilities, err := DetectCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !SupportedVersion(capabilities.Version) {
+		return nil, fmt.Errorf("%w: Tart %s is installed, but chamber requires Tart %s or newer, "+
+			"please upgrade it with \"brew upgrade cirruslabs/cli/tart\"",
+			ErrUnsupportedVersion, capabilities.Version, MinimumVersion)
+	}
+
+	return capabilities, nil
+}
+
+// String returns a human-readable list of the supported optional features
+func (capabilities *Capabilities) String() string {
+	var features []string
+
+	for _, feature := range []struct {
+		name      string
+		supported bool
+	}{
+		{"no-clipboard", capabilities.NoClipboard},
+		{"no-audio", capabilities.NoAudio},
+		{"dir-tag", capabilities.DirTag},
+	} {
+		if feature.supported {
+			features = append(features, feature.name)
+		}
+	}
+
+	if len(features) == 0 {
+		return "none"
+	}
+
+	return strings.Join(features, ", ")
+}
+
+func parseRunHelp(help string) *Capabilities {
+	return &Capabilities{
+		NoClipboard: strings.Contains(help, "--no-clipboard"),
+		NoAudio:     strings.Contains(help, "--no-audio"),
+		DirTag:      strings.Contains(help, "tag="),
+	}
+}
@@ -0,0 +1,76 @@
+package tart
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	goversion "github.com/hashicorp/go-version"
+)
+
+const runHelpSample = `OVERVIEW: Run a VM
+
+USAGE: tart run <name> [--no-graphics] [--no-clipboard] [--no-audio] [--dir <[name:]path[:options]> ...]
+
+OPTIONS:
+  --no-graphics           Don't open a UI window.
+  --no-clipboard          Disable clipboard sharing between host and guest.
+  --no-audio              Disable audio pass-through to host.
+  --dir <[name:]path[:options]>
+                          Additional directory shares with an optional read-only and mount tag options
+                          (e.g. --dir="~/src/build" or --dir="~/src/sources:ro" or --dir="~/src/sources:tag=my-tag")
+  --net-softnet-allow <comma-separated CIDRs>
+                          Comma-separated list of CIDRs to allow the traffic to when using Softnet isolation
+`
+
+func TestParseRunHelp(t *testing.T) {
+	capabilities := parseRunHelp(runHelpSample)
+
+	expected := &Capabilities{NoClipboard: true, NoAudio: true, DirTag: true}
+	if !reflect.DeepEqual(capabilities, expected) {
+		t.Errorf("parseRunHelp() = %+v, want %+v", capabilities, expected)
+	}
+
+	if capabilities := parseRunHelp("USAGE: tart run <name> [--no-graphics]"); capabilities.String() != "none" {
+		t.Errorf("expected no optional features, got %s", capabilities)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	mounts := []DirectoryMount{
+		{Name: "project", Path: "/Users/admin/project"},
+		{Name: "cache", Path: "/tmp/cache", ReadOnly: true},
+	}
+
+	args, err := RunArgs("vm", mounts, &Capabilities{NoClipboard: true, NoAudio: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"--no-graphics", "--no-clipboard", "--no-audio",
+		"--dir", "project:/Users/admin/project",
+		"--dir", "cache:/tmp/cache:ro",
+		"vm",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("RunArgs() = %q, want %q", args, expected)
+	}
+
+	// Optional flags are omitted when the installed Tart doesn't support them
+	args, err = RunArgs("vm", nil, &Capabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"--no-graphics", "vm"}) {
+		t.Errorf("unexpected arguments for an old Tart: %q", args)
+	}
+
+	// Mount tags can't be silently dropped
+	_, err = RunArgs("vm", []DirectoryMount{{Name: "a", Path: "/a", Tag: "custom"}}, &Capabilities{
+		Version: goversion.Must(goversion.NewVersion("2.0.0")),
+	})
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
@@ -5,12 +5,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
-const tartCommandName = "tart"
+const (
+	tartCommandName = "tart"
+
+	// How much of the Tart's standard error to keep for error messages
+	stderrTailSize = 4096
+)
 
 var (
 	ErrTartNotFound = errors.New("tart command not found")
@@ -80,9 +87,12 @@ func Cmd(
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 	}
 
-	// Inherit stdout, stdin, stderr
+	// Inherit stdout, stdin, stderr, but also keep the last
+	// part of stderr around to explain a potential failure
+	stderrTail := newTailBuffer(stderrTailSize)
+
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderrTail)
 	cmd.Stdin = os.Stdin
 
 	err := cmd.Run()
@@ -92,15 +102,50 @@ func Cmd(
 				ErrTartNotFound, tartCommandName)
 		}
 
-		if _, ok := err.(*exec.ExitError); ok {
-			// Tart command failed
-			return fmt.Errorf("%w", ErrTartFailed)
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// Tart command failed, include the exit details and what it had to say
+			if output := strings.TrimSpace(stderrTail.String()); output != "" {
+				return fmt.Errorf("%w (%s): %s", ErrTartFailed, exitErr, output)
+			}
+
+			return fmt.Errorf("%w (%s)", ErrTartFailed, exitErr)
 		}
 	}
 
 	return err
 }
 
+// tailBuffer is an io.Writer that only retains the last size bytes written to it
+type tailBuffer struct {
+	mtx  sync.Mutex
+	size int
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mtx.Lock()
+	defer tb.mtx.Unlock()
+
+	tb.buf = append(tb.buf, p...)
+
+	if overflow := len(tb.buf) - tb.size; overflow > 0 {
+		tb.buf = append(tb.buf[:0], tb.buf[overflow:]...)
+	}
+
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mtx.Lock()
+	defer tb.mtx.Unlock()
+
+	return string(tb.buf)
+}
+
 func firstNonEmptyLine(outputs ...string) string {
 	for _, output := range outputs {
 		for _, line := range strings.Split(output, "\n") {
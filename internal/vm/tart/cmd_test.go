@@ -0,0 +1,25 @@
+package tart
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tb := newTailBuffer(8)
+
+	_, _ = tb.Write([]byte("hello"))
+	if tb.String() != "hello" {
+		t.Fatalf("got %q, want %q", tb.String(), "hello")
+	}
+
+	_, _ = tb.Write([]byte(", world"))
+	if tb.String() != "o, world" {
+		t.Fatalf("got %q, want %q", tb.String(), "o, world")
+	}
+
+	_, _ = tb.Write([]byte(strings.Repeat("x", 100)))
+	if tb.String() != strings.Repeat("x", 8) {
+		t.Fatalf("got %q, want only the last 8 bytes", tb.String())
+	}
+}
@@ -0,0 +1,75 @@
+package tart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNoDigest = errors.New("failed to resolve image digest")
+
+// IsRemote reports whether the name refers to an OCI image rather than a local VM
+func IsRemote(name string) bool {
+	return strings.Contains(name, "/")
+}
+
+// SplitReference splits an OCI image reference into the repository and the tag or digest,
+// defaulting to the "latest" tag
+func SplitReference(ref string) (repository string, tagOrDigest string) {
+	if repository, digest, ok := strings.Cut(ref, "@"); ok {
+		return repository, digest
+	}
+
+	// A colon before the last slash belongs to the registry's port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+
+	return ref, "latest"
+}
+
+// ResolveDigest returns an identifier of the VM or image contents, which changes when
+// a mutable tag is moved or a local VM is modified. OCI images are pulled to find out
+// what the tag currently points to.
+func ResolveDigest(ctx context.Context, name string) (string, error) {
+	tartHome, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	if !IsRemote(name) {
+		// Local VMs have no digest, but any modification touches the disk image
+		info, err := os.Stat(filepath.Join(tartHome, "vms", name, "disk.img"))
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrNoDigest, name, err)
+		}
+
+		return fmt.Sprintf("local:%s@%s", name, info.ModTime().UTC().Format(time.RFC3339Nano)), nil
+	}
+
+	repository, tagOrDigest := SplitReference(name)
+	if strings.HasPrefix(tagOrDigest, "sha256:") {
+		return tagOrDigest, nil
+	}
+
+	if err := Cmd(ctx, nil, "pull", name); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrNoDigest, name, err)
+	}
+
+	// Tart's OCI cache stores tags as symbolic links to the digest directories
+	target, err := os.Readlink(filepath.Join(tartHome, "cache", "OCIs", repository, tagOrDigest))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrNoDigest, name, err)
+	}
+
+	digest := filepath.Base(target)
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("%w: %s: unexpected cache entry %q", ErrNoDigest, name, target)
+	}
+
+	return digest, nil
+}
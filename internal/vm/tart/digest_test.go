@@ -0,0 +1,27 @@
+package tart
+
+import (
+	"testing"
+)
+
+func TestSplitReference(t *testing.T) {
+	tests := []struct {
+		ref         string
+		repository  string
+		tagOrDigest string
+	}{
+		{"ghcr.io/cirruslabs/macos-sequoia-base:latest", "ghcr.io/cirruslabs/macos-sequoia-base", "latest"},
+		{"ghcr.io/cirruslabs/macos-sequoia-base", "ghcr.io/cirruslabs/macos-sequoia-base", "latest"},
+		{"localhost:5000/seed:v1", "localhost:5000/seed", "v1"},
+		{"localhost:5000/seed", "localhost:5000/seed", "latest"},
+		{"ghcr.io/team/seed@sha256:abcd", "ghcr.io/team/seed", "sha256:abcd"},
+	}
+
+	for _, tt := range tests {
+		repository, tagOrDigest := SplitReference(tt.ref)
+		if repository != tt.repository || tagOrDigest != tt.tagOrDigest {
+			t.Errorf("SplitReference(%q) = %q, %q, want %q, %q",
+				tt.ref, repository, tagOrDigest, tt.repository, tt.tagOrDigest)
+		}
+	}
+}
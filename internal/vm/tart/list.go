@@ -0,0 +1,70 @@
+package tart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VMInfo describes a VM as reported by "tart list"
+type VMInfo struct {
+	Source  string `json:"Source"`
+	Name    string `json:"Name"`
+	Disk    int    `json:"Disk"`
+	Size    int    `json:"Size"`
+	Running bool   `json:"Running"`
+	State   string `json:"State"`
+}
+
+// List returns all local and OCI-cached VMs known to Tart
+func List(ctx context.Context) ([]VMInfo, error) {
+	stdout, _, err := CmdWithCapture(ctx, nil, "list", "--format", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	var vms []VMInfo
+	if err := json.Unmarshal([]byte(stdout), &vms); err != nil {
+		return nil, fmt.Errorf("failed to parse VM list: %w", err)
+	}
+
+	return vms, nil
+}
+
+// Exists reports whether a local VM with the given name exists
+func Exists(ctx context.Context, name string) (bool, error) {
+	vms, err := List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, vm := range vms {
+		if vm.Source == "local" && vm.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// IsEphemeral reports whether the VM name belongs to an ephemeral VM created by chamber
+func IsEphemeral(name string) bool {
+	return strings.HasPrefix(name, vmNamePrefix)
+}
+
+// HomeDir returns the directory where Tart stores its VMs and OCI cache
+func HomeDir() (string, error) {
+	if tartHome := os.Getenv("TART_HOME"); tartHome != "" {
+		return tartHome, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".tart"), nil
+}
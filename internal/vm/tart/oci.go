@@ -0,0 +1,34 @@
+package tart
+
+import (
+	"context"
+	"fmt"
+)
+
+// PushVM pushes a local VM to an OCI registry
+func PushVM(ctx context.Context, name string, ref string, insecure bool) error {
+	args := []string{name, ref}
+	if insecure {
+		args = append([]string{"--insecure"}, args...)
+	}
+
+	if err := Cmd(ctx, nil, "push", args...); err != nil {
+		return fmt.Errorf("failed to push VM %q to %q: %w", name, ref, err)
+	}
+
+	return nil
+}
+
+// PullVM pulls an image from an OCI registry and clones it to a local VM
+func PullVM(ctx context.Context, ref string, name string, insecure bool) error {
+	args := []string{ref, name}
+	if insecure {
+		args = append([]string{"--insecure"}, args...)
+	}
+
+	if err := Cmd(ctx, nil, "clone", args...); err != nil {
+		return fmt.Errorf("failed to pull %q to %q: %w", ref, name, err)
+	}
+
+	return nil
+}
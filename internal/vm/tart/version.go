@@ -0,0 +1,42 @@
+package tart
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	goversion "github.com/hashicorp/go-version"
+)
+
+// MinimumVersion is the oldest Tart release chamber is tested against
+const MinimumVersion = "2.0.0"
+
+// Version returns the version of the installed Tart
+func Version(ctx context.Context) (*goversion.Version, error) {
+	stdout, _, err := CmdWithCapture(ctx, nil, "--version")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseVersion(stdout)
+}
+
+// SupportedVersion reports whether the given Tart version is at or above MinimumVersion
+func SupportedVersion(version *goversion.Version) bool {
+	return version.GreaterThanOrEqual(goversion.Must(goversion.NewVersion(MinimumVersion)))
+}
+
+func parseVersion(output string) (*goversion.Version, error) {
+	raw := firstNonEmptyLine(output)
+
+	// Be lenient towards a "tart" prefix or a build suffix separated by whitespace
+	fields := strings.Fields(raw)
+	for _, field := range fields {
+		version, err := goversion.NewVersion(field)
+		if err == nil {
+			return version, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to parse Tart version from %q", raw)
+}
@@ -0,0 +1,34 @@
+package tart
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output    string
+		expected  string
+		supported bool
+	}{
+		{output: "2.28.3\n", expected: "2.28.3", supported: true},
+		{output: "\ntart 2.0.0\n", expected: "2.0.0", supported: true},
+		{output: "1.14.0", expected: "1.14.0", supported: false},
+	}
+
+	for _, tt := range tests {
+		version, err := parseVersion(tt.output)
+		if err != nil {
+			t.Fatalf("parseVersion(%q) failed: %v", tt.output, err)
+		}
+		if version.String() != tt.expected {
+			t.Errorf("parseVersion(%q) = %s, want %s", tt.output, version, tt.expected)
+		}
+		if SupportedVersion(version) != tt.supported {
+			t.Errorf("SupportedVersion(%s) = %v, want %v", version, !tt.supported, tt.supported)
+		}
+	}
+
+	if _, err := parseVersion("unknown"); err == nil {
+		t.Error("expected an error for unparseable output")
+	}
+}
@@ -2,6 +2,7 @@ package tart
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -29,6 +30,8 @@ const (
 	vmNamePrefix = "chamber-ephemeral-"
 )
 
+var ErrVMExited = errors.New("VM exited unexpectedly")
+
 func NewVMClonedFrom(
 	ctx context.Context,
 	from string,
@@ -36,12 +39,8 @@ func NewVMClonedFrom(
 ) (*VM, error) {
 	runningVMCtx, runningVMCtxCancel := context.WithCancel(context.Background())
 
-	// Use timestamp for VM name
-	timestamp := time.Now().Format("20060102-150405")
-	tmpVMName := vmNamePrefix + timestamp
-
 	vm := &VM{
-		ident:              tmpVMName,
+		ident:              EphemeralVMName(time.Now()),
 		baseImage:          from,
 		env:                env,
 		runningVMCtx:       runningVMCtx,
@@ -57,31 +56,62 @@ func NewVMClonedFrom(
 	return vm, nil
 }
 
+// EphemeralVMName returns the name of an ephemeral VM created at the given time
+func EphemeralVMName(now time.Time) string {
+	return vmNamePrefix + now.Format("20060102-150405")
+}
+
 func (vm *VM) Ident() string {
 	return vm.ident
 }
 
 func (vm *VM) Configure(ctx context.Context, cpu uint32, memory uint32) error {
+	for _, setting := range configureSettings(vm.ident, cpu, memory) {
+		if err := Cmd(ctx, vm.env, "set", setting.args...); err != nil {
+			return fmt.Errorf("failed to set %s: %w", setting.description, err)
+		}
+	}
+
+	return nil
+}
+
+type setting struct {
+	description string
+	args        []string
+}
+
+func configureSettings(ident string, cpu uint32, memory uint32) []setting {
 	// Set random MAC address to avoid conflicts
-	if err := Cmd(ctx, vm.env, "set", vm.ident, "--random-mac"); err != nil {
-		return fmt.Errorf("failed to set random MAC: %w", err)
+	settings := []setting{
+		{description: "random MAC", args: []string{ident, "--random-mac"}},
 	}
 
 	if cpu != 0 {
-		cpuStr := fmt.Sprintf("%d", cpu)
-		if err := Cmd(ctx, vm.env, "set", vm.ident, "--cpu", cpuStr); err != nil {
-			return fmt.Errorf("failed to set CPU count: %w", err)
-		}
+		settings = append(settings, setting{
+			description: "CPU count",
+			args:        []string{ident, "--cpu", fmt.Sprintf("%d", cpu)},
+		})
 	}
 
 	if memory != 0 {
-		memoryStr := fmt.Sprintf("%d", memory)
-		if err := Cmd(ctx, vm.env, "set", vm.ident, "--memory", memoryStr); err != nil {
-			return fmt.Errorf("failed to set memory: %w", err)
-		}
+		settings = append(settings, setting{
+			description: "memory",
+			args:        []string{ident, "--memory", fmt.Sprintf("%d", memory)},
+		})
 	}
 
-	return nil
+	return settings
+}
+
+// ConfigureArgs returns the "tart set" invocations Configure would perform
+func ConfigureArgs(ident string, cpu uint32, memory uint32) [][]string {
+	var result [][]string
+
+	for _, setting := range configureSettings(ident, cpu, memory) {
+		result = append(result, append([]string{"set"}, setting.args...))
+	}
+
+	return result
 }
 
 func (vm *VM) Start(ctx context.Context, directoryMounts []DirectoryMount) {
@@ -90,42 +120,107 @@ func (vm *VM) Start(ctx context.Context, directoryMounts []DirectoryMount) {
 	go func() {
 		defer vm.wg.Done()
 
-		args := []string{"--no-graphics", "--no-clipboard", "--no-audio"}
+		capabilities, err := DetectCapabilities(ctx)
+		if err != nil {
+			vm.errChan <- err
+			return
+		}
 
-		for _, dm := range directoryMounts {
-			var opts []string
+		args, err := RunArgs(vm.ident, directoryMounts, capabilities)
+		if err != nil {
+			vm.errChan <- err
+			return
+		}
 
-			if tag := dm.Tag; tag != "" {
-				opts = append(opts, fmt.Sprintf("tag=%s", tag))
-			}
+		err = Cmd(vm.runningVMCtx, vm.env, "run", args...)
+		vm.errChan <- err
+	}()
+}
 
-			if dm.ReadOnly {
-				opts = append(opts, "ro")
-			}
+// RunArgs builds the "tart run" arguments, only using optional flags supported by the installed Tart
+func RunArgs(ident string, directoryMounts []DirectoryMount, capabilities *Capabilities) ([]string, error) {
+	args := []string{"--no-graphics"}
+
+	if capabilities.NoClipboard {
+		args = append(args, "--no-clipboard")
+	}
+
+	if capabilities.NoAudio {
+		args = append(args, "--no-audio")
+	}
 
-			dirArgumentValue := fmt.Sprintf("%s:%s", dm.Name, dm.Path)
+	for _, dm := range directoryMounts {
+		var opts []string
 
-			if len(opts) != 0 {
-				dirArgumentValue += ":" + strings.Join(opts, ",")
+		if tag := dm.Tag; tag != "" {
+			if !capabilities.DirTag {
+				return nil, fmt.Errorf("%w: directory mount %q requires a custom tag, "+
+					"which is not supported by Tart %s", ErrUnsupportedVersion, dm.Name, capabilities.Version)
 			}
 
-			args = append(args, "--dir", dirArgumentValue)
+			opts = append(opts, fmt.Sprintf("tag=%s", tag))
+		}
+
+		if dm.ReadOnly {
+			opts = append(opts, "ro")
 		}
 
-		args = append(args, vm.ident)
+		dirArgumentValue := fmt.Sprintf("%s:%s", dm.Name, dm.Path)
 
-		err := Cmd(vm.runningVMCtx, vm.env, "run", args...)
-		vm.errChan <- err
-	}()
+		if len(opts) != 0 {
+			dirArgumentValue += ":" + strings.Join(opts, ",")
+		}
+
+		args = append(args, "--dir", dirArgumentValue)
+	}
+
+	args = append(args, ident)
+
+	return args, nil
 }
 
 func (vm *VM) ErrChan() chan error {
 	return vm.errChan
 }
 
+// Monitor watches the "tart run" process started by Start until stop is called.
+//
+// If the VM exits before that, for example because the guest crashed or was shut down,
+// onExit is called with an ErrVMExited-wrapped error describing the reason.
+func (vm *VM) Monitor(onExit func(err error)) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		select {
+		case err := <-vm.errChan:
+			// Stopping takes precedence, e.g. when the VM exit was caused by cleanup
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			reason := "tart run exited"
+			if err != nil {
+				reason = err.Error()
+			}
+
+			onExit(fmt.Errorf("%w: %s", ErrVMExited, reason))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
 func (vm *VM) RetrieveIP(ctx context.Context) (string, error) {
 	// Wait up to 30 seconds for the VM to get an IP
-	stdout, _, err := CmdWithCapture(ctx, vm.env, "ip", "--wait", "30", vm.ident)
+	stdout, _, err := CmdWithCapture(ctx, vm.env, "ip", IPArgs(vm.ident)...)
 	if err != nil {
 		return "", err
 	}
@@ -133,13 +228,18 @@ func (vm *VM) RetrieveIP(ctx context.Context) (string, error) {
 	return strings.TrimSpace(stdout), nil
 }
 
+// IPArgs returns the "tart ip" arguments used by RetrieveIP
+func IPArgs(ident string) []string {
+	return []string{"--wait", "30", ident}
+}
+
 func (vm *VM) Stop() error {
 	return vm.StopWithContext(context.Background())
 }
 
 func (vm *VM) StopWithContext(ctx context.Context) error {
 	// Try to gracefully stop the VM
-	_ = Cmd(ctx, vm.env, "stop", "--timeout", "5", vm.ident)
+	_ = Cmd(ctx, vm.env, "stop", StopArgs(vm.ident)...)
 
 	vm.runningVMCtxCancel()
 	vm.wg.Wait()
@@ -147,6 +247,11 @@ func (vm *VM) StopWithContext(ctx context.Context) error {
 	return nil
 }
 
+// StopArgs returns the "tart stop" arguments used to gracefully stop a VM
+func StopArgs(ident string) []string {
+	return []string{"--timeout", "5", ident}
+}
+
 func (vm *VM) Delete() error {
 	ctx := context.Background()
 	err := Cmd(ctx, vm.env, "delete", vm.ident)
@@ -182,3 +287,19 @@ func CloneVM(ctx context.Context, from, to string) error {
 	}
 	return nil
 }
+
+// RenameVM renames a local VM
+func RenameVM(ctx context.Context, from, to string) error {
+	if err := Cmd(ctx, nil, "rename", from, to); err != nil {
+		return fmt.Errorf("failed to rename VM %q to %q: %w", from, to, err)
+	}
+	return nil
+}
+
+// DeleteVM deletes a local VM
+func DeleteVM(ctx context.Context, name string) error {
+	if err := Cmd(ctx, nil, "delete", name); err != nil {
+		return fmt.Errorf("failed to delete VM %q: %w", name, err)
+	}
+	return nil
+}
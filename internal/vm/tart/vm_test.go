@@ -0,0 +1,46 @@
+package tart
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	vm := &VM{errChan: make(chan error, 1)}
+
+	exited := make(chan error, 1)
+	stop := vm.Monitor(func(err error) {
+		exited <- err
+	})
+	defer stop()
+
+	vm.errChan <- errors.New("guest panicked")
+
+	select {
+	case err := <-exited:
+		if !errors.Is(err, ErrVMExited) {
+			t.Fatalf("expected ErrVMExited, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "VM exited unexpectedly: guest panicked") {
+			t.Fatalf("unexpected error message: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Monitor didn't report the VM exit")
+	}
+}
+
+func TestMonitorStopped(t *testing.T) {
+	vm := &VM{errChan: make(chan error, 1)}
+
+	stop := vm.Monitor(func(err error) {
+		t.Errorf("unexpected exit report after stopping: %v", err)
+	})
+	stop()
+	stop()
+
+	// A VM exit after stopping the monitor, e.g. during cleanup, goes unreported
+	vm.errChan <- nil
+	time.Sleep(10 * time.Millisecond)
+}
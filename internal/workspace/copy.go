@@ -0,0 +1,242 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Copy is a copy of the working directory without the ignored files, which the VM
+// gets to see instead of the working directory itself
+type Copy struct {
+	// Source is the working directory
+	Source string
+
+	// Dir is where the copy is
+	Dir string
+
+	// Excluded are the ignored paths of the working directory that weren't copied
+	Excluded []string
+
+	ignore *Ignore
+
+	// rejected are the excluded paths found in the VM's archive
+	rejected []string
+
+	// extracted are the files written from the VM's archive, with the hashes computed on the way
+	extracted Files
+
+	// missing are the paths that weren't in the VM's archive
+	missing []string
+
+	// base is the state of both directories when the copy was made
+	base Files
+}
+
+// SyncResult describes how the changes made in the copy were brought back
+type SyncResult struct {
+	// Changed are the paths updated, created or removed in the working directory
+	Changed []string
+
+	// Conflicts are the paths changed both in the copy and in the working directory,
+	// they're left as they are in the working directory
+	Conflicts []string
+
+	// Rejected are the paths the VM isn't allowed to change: the ignored ones, the ignore file,
+	// symbolic links pointing outside of the working directory and paths below symbolic links
+	Rejected []string
+}
+
+// Stage copies the files of source that aren't ignored to dir
+func Stage(ctx context.Context, source string, dir string, ignore *Ignore) (*Copy, error) {
+	files, excluded, err := Scan(source, ignore, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the workspace copy: %w", err)
+	}
+
+	paths := files.Paths()
+
+	for _, path := range paths {
+		// Copying a large working directory takes a while, which mustn't hold an interrupted run up
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := copyFile(source, dir, path, files[path]); err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", path, err)
+		}
+	}
+
+	if err := applyDirModes(dir, files, paths); err != nil {
+		return nil, err
+	}
+
+	return &Copy{
+		Source:    source,
+		Dir:       dir,
+		Excluded:  excluded,
+		ignore:    ignore,
+		base:      files,
+		extracted: Files{},
+	}, nil
+}
+
+// SyncBack brings the changes made in the copy back to the working directory. Ignored paths
+// are never synced back, so that the VM can't overwrite the files it wasn't allowed to see.
+func (staged *Copy) SyncBack() (*SyncResult, error) {
+	// The hashes of the extracted files are known, which matters when they changed without
+	// changing their size or modification time
+	copied, rejected, err := Scan(staged.Dir, staged.ignore, union(staged.base, staged.extracted))
+	if err != nil {
+		return nil, err
+	}
+
+	current, _, err := Scan(staged.Source, staged.ignore, staged.base)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Rejected: append(staged.rejected, rejected...)}
+
+	var removed []string
+	var updated []string
+
+	for _, path := range union(staged.base, copied).Paths() {
+		base, inBase := staged.base[path]
+		file, inCopy := copied[path]
+
+		if inBase == inCopy && base.Same(file) {
+			continue
+		}
+
+		// Otherwise the next run could be made to expose the ignored files
+		if path == IgnoreFile {
+			result.Rejected = append(result.Rejected, path)
+
+			continue
+		}
+
+		// A link to the host's files would be followed by the next sync back
+		if inCopy && file.Mode.Type() == fs.ModeSymlink && linkEscapes(path, file.Link) {
+			result.Rejected = append(result.Rejected, path)
+
+			continue
+		}
+
+		// Changed on both sides, unless both made the same change
+		now, inSource := current[path]
+		if inSource != inBase || (inSource && !now.Same(base)) {
+			if inSource != inCopy || (inSource && !now.Same(file)) {
+				result.Conflicts = append(result.Conflicts, path)
+			}
+
+			continue
+		}
+
+		if inCopy {
+			updated = append(updated, path)
+		} else {
+			removed = append(removed, path)
+		}
+	}
+
+	// Children are removed before their parents
+	for i := len(removed) - 1; i >= 0; i-- {
+		path := removed[i]
+
+		if err := checkParents(staged.Source, path); err != nil {
+			if !errors.Is(err, ErrUnsafePath) {
+				return nil, err
+			}
+
+			result.Rejected = append(result.Rejected, path)
+
+			continue
+		}
+
+		if err := removeFile(filepath.Join(staged.Source, filepath.FromSlash(path))); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+
+		result.Changed = append(result.Changed, path)
+	}
+
+	// Parents come first, so a symbolic link replaced by a directory is gone before its contents are checked
+	var applied []string
+	for _, path := range updated {
+		if err := checkParents(staged.Source, path); err != nil {
+			if !errors.Is(err, ErrUnsafePath) {
+				return nil, err
+			}
+
+			result.Rejected = append(result.Rejected, path)
+
+			continue
+		}
+
+		if err := copyFile(staged.Dir, staged.Source, path, copied[path]); err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", path, err)
+		}
+
+		applied = append(applied, path)
+	}
+
+	if err := applyDirModes(staged.Source, copied, applied); err != nil {
+		return nil, err
+	}
+
+	result.Changed = append(result.Changed, applied...)
+	sort.Strings(result.Changed)
+	sort.Strings(result.Rejected)
+
+	return result, nil
+}
+
+// Remove deletes the copy and the per-run directory it was made in
+func (staged *Copy) Remove() error {
+	if err := os.RemoveAll(staged.Dir); err != nil {
+		return fmt.Errorf("failed to remove the workspace copy: %w", err)
+	}
+
+	_ = os.Remove(filepath.Dir(staged.Dir))
+
+	return nil
+}
+
+// applyDirModes sets the permissions of the directories among the paths, children first
+// so that a read-only directory doesn't get in the way of its contents
+func applyDirModes(dir string, files Files, paths []string) error {
+	for i := len(paths) - 1; i >= 0; i-- {
+		file := files[paths[i]]
+		if !file.Mode.IsDir() {
+			continue
+		}
+
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(paths[i])), file.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func union(a Files, b Files) Files {
+	result := Files{}
+
+	for path, file := range a {
+		result[path] = file
+	}
+	for path, file := range b {
+		result[path] = file
+	}
+
+	return result
+}
@@ -0,0 +1,293 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File describes a file, directory or symbolic link in the working directory
+type File struct {
+	Mode    fs.FileMode
+	Size    int64
+	ModTime time.Time
+	Hash    string
+	Link    string
+}
+
+// Same reports whether two files have the same type, permissions and contents
+func (file File) Same(other File) bool {
+	return file.Mode == other.Mode && file.Hash == other.Hash && file.Link == other.Link
+}
+
+// Files maps the slash-separated paths relative to a directory to the files
+type Files map[string]File
+
+// Paths returns the sorted paths, parents come before their children
+func (files Files) Paths() []string {
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Scan lists the files in dir that aren't ignored, and the paths that were left out.
+// Files with the same size and modification time as in previous aren't read again.
+func Scan(dir string, ignore *Ignore, previous Files) (Files, []string, error) {
+	files := Files{}
+	var excluded []string
+
+	err := filepath.WalkDir(dir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if ignore.Match(rel, dirEntry.IsDir()) {
+			excluded = append(excluded, rel)
+
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		file := File{Mode: info.Mode().Type() | info.Mode().Perm()}
+
+		switch {
+		case info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			file.Link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			file.Size = info.Size()
+			file.ModTime = info.ModTime().UTC()
+
+			if known, ok := previous[rel]; ok && known.Mode == file.Mode && known.Size == file.Size &&
+				known.ModTime.Equal(file.ModTime) {
+				file.Hash = known.Hash
+			} else if file.Hash, err = hashFile(path); err != nil {
+				return err
+			}
+		default:
+			// Sockets, pipes and devices can't be copied
+			return nil
+		}
+
+		files[rel] = file
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	return files, excluded, nil
+}
+
+// Excluded returns the ignored paths of dir, without descending into ignored directories
+func Excluded(dir string, ignore *Ignore) ([]string, error) {
+	var excluded []string
+
+	err := filepath.WalkDir(dir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if ignore.Match(rel, dirEntry.IsDir()) {
+			excluded = append(excluded, rel)
+
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	return excluded, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checkParents makes sure that none of the parent directories of rel in dir is a symbolic link,
+// which would make writing to rel write outside of dir
+func checkParents(dir string, rel string) error {
+	for _, name := range strings.Split(path.Dir(rel), "/") {
+		if name == "." {
+			break
+		}
+
+		dir = filepath.Join(dir, name)
+
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s is not a directory", ErrUnsafePath, path.Dir(rel))
+		}
+	}
+
+	return nil
+}
+
+// linkEscapes reports whether the symbolic link at rel points outside of the directory it's in
+func linkEscapes(rel string, link string) bool {
+	if path.IsAbs(link) {
+		return true
+	}
+
+	target := path.Join(path.Dir(rel), link)
+
+	return target == ".." || strings.HasPrefix(target, "../")
+}
+
+// copyFile copies the file at rel from one directory to another, replacing what's there
+func copyFile(fromDir string, toDir string, rel string, file File) error {
+	src := filepath.Join(fromDir, filepath.FromSlash(rel))
+	dst := filepath.Join(toDir, filepath.FromSlash(rel))
+
+	switch file.Mode.Type() {
+	case fs.ModeDir:
+		// A symbolic link replaced by a directory must not be followed
+		if info, err := os.Lstat(dst); err == nil && !info.IsDir() {
+			if err := os.Remove(dst); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(dst, 0o700); err != nil {
+			return err
+		}
+
+		// Restricted permissions are applied once the contents are in place
+		return nil
+	case fs.ModeSymlink:
+		if err := removeFile(dst); err != nil {
+			return err
+		}
+
+		return os.Symlink(file.Link, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+
+	// Write next to the destination first, so that an interrupted copy doesn't leave a partial file
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), ".chamber-copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, in)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmpFile.Name(), file.Mode.Perm()); err != nil {
+		return err
+	}
+
+	// Keeping the modification time lets the next scan skip reading the file
+	if err := os.Chtimes(tmpFile.Name(), file.ModTime, file.ModTime); err != nil {
+		return err
+	}
+
+	if err := removeFile(dst); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), dst)
+}
+
+// removeFile removes a file, a symbolic link or an empty directory, if it exists.
+// Directories with contents are kept, they might hold excluded files.
+func removeFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !info.IsDir() {
+		return os.Remove(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return nil
+	}
+
+	return os.Remove(path)
+}
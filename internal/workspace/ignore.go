@@ -0,0 +1,205 @@
+// Package workspace prepares the copy of the working directory the VM sees when some of
+// its files must stay on the host, and brings the changes made in the copy back
+package workspace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists the files the VM must not see, in the gitignore syntax
+const IgnoreFile = ".chamberignore"
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// Ignore matches paths against the patterns of an ignore file
+type Ignore struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// LoadIgnore reads the .chamberignore file in dir, it returns nil if there's none
+func LoadIgnore(dir string) (*Ignore, error) {
+	file, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	defer file.Close()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+
+	ignore, err := ParseIgnore(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", IgnoreFile, err)
+	}
+
+	return ignore, nil
+}
+
+// ParseIgnore compiles the lines of an ignore file
+func ParseIgnore(lines []string) (*Ignore, error) {
+	ignore := &Ignore{}
+
+	for i, line := range lines {
+		rule, ok, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if ok {
+			ignore.rules = append(ignore.rules, rule)
+		}
+	}
+
+	return ignore, nil
+}
+
+func parseRule(line string) (rule, bool, error) {
+	var result rule
+
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless they're escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return result, false, nil
+	}
+
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		result.negate = true
+		line = rest
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		result.dirOnly = true
+		line = rest
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the directory of the ignore file,
+	// otherwise it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	if line == "" {
+		return result, false, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+
+	expr, err := globToRegexp(line)
+	if err != nil {
+		return result, false, err
+	}
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+
+	result.pattern, err = regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return result, false, fmt.Errorf("%w: %q: %v", ErrInvalidPattern, line, err)
+	}
+
+	return result, true, nil
+}
+
+// globToRegexp translates a gitignore glob into a regular expression
+func globToRegexp(glob string) (string, error) {
+	var expr strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// Any number of directories, including none
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**" && (i == 0 || glob[i-1] == '/'):
+			// Everything inside
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				return "", fmt.Errorf("%w: unterminated [ in %q", ErrInvalidPattern, glob)
+			}
+
+			class := glob[i+1 : i+1+end]
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String(), nil
+}
+
+// Match reports whether the slash-separated path relative to the directory
+// of the ignore file is ignored. The last matching pattern wins.
+func (ignore *Ignore) Match(path string, isDir bool) bool {
+	if ignore == nil {
+		return false
+	}
+
+	ignored := false
+
+	for _, rule := range ignore.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		if rule.pattern.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// Excludes reports whether the path or any of its parent directories is ignored,
+// i.e. whether the path would be left out when walking the directory
+func (ignore *Ignore) Excludes(path string, isDir bool) bool {
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && ignore.Match(path[:i], true) {
+			return true
+		}
+	}
+
+	return ignore.Match(path, isDir)
+}
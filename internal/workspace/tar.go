@@ -0,0 +1,240 @@
+package workspace
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrUnsafePath = errors.New("unsafe path in the archive")
+
+// WriteTar writes the copy as it was made to a tar archive, which is how it's copied to the VM
+func (staged *Copy) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	for _, rel := range staged.base.Paths() {
+		file := staged.base[rel]
+
+		header := &tar.Header{
+			Name:    rel,
+			Mode:    int64(file.Mode.Perm()),
+			ModTime: file.ModTime,
+			Format:  tar.FormatPAX,
+		}
+
+		switch file.Mode.Type() {
+		case fs.ModeDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case fs.ModeSymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = file.Link
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = file.Size
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := copyTo(tw, filepath.Join(staged.Dir, filepath.FromSlash(rel)), file.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tw.Close()
+}
+
+func copyTo(w io.Writer, path string, size int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// The archive header has promised exactly this many bytes
+	_, err = io.CopyN(w, file, size)
+
+	return err
+}
+
+// ReadTar updates the copy to match the tar archive of the VM's working directory, leaving
+// the files with unchanged contents alone. Paths excluded by the ignore file are never extracted.
+// The paths missing from the archive are only removed by RemoveMissing.
+func (staged *Copy) ReadTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	seen := map[string]bool{}
+
+	var dirs []string
+	dirModes := map[string]fs.FileMode{}
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the archive: %w", err)
+		}
+
+		rel := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if rel == "." {
+			continue
+		}
+		if path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+			return fmt.Errorf("%w: %s", ErrUnsafePath, header.Name)
+		}
+
+		// The ignore file can't be changed from the VM, it's only there for reference
+		if rel == IgnoreFile {
+			seen[rel] = true
+
+			continue
+		}
+
+		// Only the topmost excluded path is reported, not everything in an excluded directory
+		isDir := header.Typeflag == tar.TypeDir
+		if staged.ignore.Match(rel, isDir) {
+			staged.rejected = append(staged.rejected, rel)
+		}
+		if staged.ignore.Excludes(rel, isDir) {
+			continue
+		}
+
+		// The archive must not write outside the copy through a symbolic link it created earlier
+		if err := checkParents(staged.Dir, rel); err != nil {
+			return err
+		}
+
+		dst := filepath.Join(staged.Dir, filepath.FromSlash(rel))
+		mode := fs.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if info, err := os.Lstat(dst); err == nil && !info.IsDir() {
+				if err := os.Remove(dst); err != nil {
+					return err
+				}
+			}
+			if err := os.MkdirAll(dst, 0o700); err != nil {
+				return err
+			}
+
+			dirs = append(dirs, rel)
+			dirModes[rel] = mode
+		case tar.TypeSymlink:
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, dst); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			// The archive only has the modification time to the second, so only the contents
+			// tell whether a file changed
+			file := File{Mode: mode, Size: header.Size, ModTime: header.ModTime.UTC()}
+
+			changed, err := extractFile(dst, tr, &file, staged.base[rel])
+			if err != nil {
+				return err
+			}
+			if changed {
+				staged.extracted[rel] = file
+			}
+		default:
+			// Hard links, devices and the like aren't synced
+			continue
+		}
+
+		seen[rel] = true
+	}
+
+	// Whatever isn't in the archive was removed in the VM, or couldn't be archived
+	staged.missing = nil
+	for rel := range staged.base {
+		if !seen[rel] {
+			staged.missing = append(staged.missing, rel)
+		}
+	}
+	sort.Strings(staged.missing)
+
+	// Children come after their parents, so apply restricted permissions in reverse
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(filepath.Join(staged.Dir, filepath.FromSlash(dirs[i])), dirModes[dirs[i]]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveMissing removes the paths missing from the archive read by ReadTar from the copy. It must
+// only be called once the archive is known to be complete: tar skips the files it can't read,
+// yet still writes a valid archive, and those files mustn't be taken for removed ones.
+func (staged *Copy) RemoveMissing() error {
+	for i := len(staged.missing) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(filepath.Join(staged.Dir, filepath.FromSlash(staged.missing[i]))); err != nil {
+			return err
+		}
+	}
+
+	staged.missing = nil
+
+	return nil
+}
+
+// extractFile writes the file read from r to dst, unless it's the same as base. It sets the file's hash
+// and reports whether the file was written.
+func extractFile(dst string, r io.Reader, file *File, base File) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return false, err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), ".chamber-copy-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	hash := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	file.Hash = hex.EncodeToString(hash.Sum(nil))
+	if base.Same(*file) {
+		return false, nil
+	}
+
+	if err := os.Chmod(tmpFile.Name(), file.Mode); err != nil {
+		return false, err
+	}
+	if err := os.Chtimes(tmpFile.Name(), file.ModTime, file.ModTime); err != nil {
+		return false, err
+	}
+
+	if info, err := os.Lstat(dst); err == nil && info.IsDir() {
+		if err := os.RemoveAll(dst); err != nil {
+			return false, err
+		}
+	}
+
+	return true, os.Rename(tmpFile.Name(), dst)
+}
